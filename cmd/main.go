@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
-	"go-asset-service/internal/config"   // Чтение конфигурации из переменных окружения или .env файла
-	"go-asset-service/internal/db"       // Подключение к базе данных через pgx
-	"go-asset-service/internal/handlers" // Регистрация HTTP-обработчиков (роутов)
+	"go-asset-service/internal/config"     // Чтение конфигурации из переменных окружения или .env файла
+	"go-asset-service/internal/db"         // Подключение к базе данных через pgx
+	"go-asset-service/internal/handlers"   // Регистрация HTTP-обработчиков (роутов)
+	"go-asset-service/internal/lockout"    // Счётчики неудачных попыток входа
+	"go-asset-service/internal/notify"     // Канал уведомлений пользователям
+	"go-asset-service/internal/repository" // Репозиторий ключей подписи JWT
+	"go-asset-service/internal/service"    // Бизнес-логика и фоновые задачи
+	"go-asset-service/internal/storage"    // Хранилище содержимого файлов (локальный диск или S3)
+	"go-asset-service/internal/tlsconfig"  // Настройки TLS сервера: сертификаты с перезагрузкой, проверка клиентских сертификатов
+	"go-asset-service/pkg/utils"           // Генерация случайного ключа подписи ссылок
 	"log"
 	"net/http"
 	"os"
@@ -24,14 +31,122 @@ func main() {
 	}
 	defer pool.Close()
 
+	// Инициализируем хранилище содержимого файлов
+	store, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Cannot initialize blob storage: %v\n", err)
+	}
+	log.Printf("Using %s blob storage", cfg.StorageBackend)
+
+	// Инициализируем канал уведомлений (токены сброса пароля)
+	notifier, err := notify.NewNotifier(cfg)
+	if err != nil {
+		log.Fatalf("Cannot initialize notifier: %v\n", err)
+	}
+	log.Printf("Using %s notifier", cfg.Notifier)
+
+	// Инициализируем хранилище счётчиков неудачных попыток входа (защита от перебора паролей)
+	attempts, err := lockout.NewAttemptStore(cfg, pool)
+	if err != nil {
+		log.Fatalf("Cannot initialize login attempt store: %v\n", err)
+	}
+	log.Printf("Using %s login attempt store", cfg.LoginAttemptStore)
+
+	// Инициализируем клиент провайдера OpenID Connect (если задан OIDC_ISSUER_URL)
+	oidcProvider, err := service.NewOIDCProvider(cfg)
+	if err != nil {
+		log.Fatalf("Cannot initialize OIDC provider: %v\n", err)
+	}
+	if oidcProvider != nil {
+		log.Printf("Using OIDC provider %s", oidcProvider.Issuer())
+	}
+	if !cfg.PasswordLoginEnabled && oidcProvider == nil {
+		log.Printf("[WARN] PASSWORD_LOGIN_ENABLED=false without OIDC_ISSUER_URL: nobody can log in")
+	}
+
+	// Инициализируем выдачу токенов доступа в формате JWT (если ACCESS_TOKEN_FORMAT=jwt):
+	// до приёма запросов нужен хотя бы один ключ подписи
+	jwtSrv, err := service.NewJWTService(repository.NewJWTRepository(pool), cfg)
+	if err != nil {
+		log.Fatalf("Cannot initialize JWT service: %v\n", err)
+	}
+	if jwtSrv.Enabled() {
+		if err := jwtSrv.Sync(context.Background()); err != nil {
+			log.Fatalf("Cannot load JWT signing keys: %v\n", err)
+		}
+		log.Printf("Using %s access tokens signed with %s", service.AccessTokenJWT, cfg.JWTAlgorithm)
+	}
+
+	// Без заданного ключа подписи ссылки на файлы действуют только до перезапуска сервера
+	if cfg.PresignSecret == "" {
+		if cfg.PresignSecret, err = utils.GenerateToken(32); err != nil {
+			log.Fatalf("Cannot generate presign secret: %v\n", err)
+		}
+		log.Printf("[WARN] PRESIGN_SECRET is not set, presigned URLs will not survive a restart")
+	}
+
+	// Загружаем сертификаты сервера (при TLS_SELF_SIGNED=true и отсутствии файлов создаём самоподписанный)
+	certStore, err := tlsconfig.NewCertStore(cfg)
+	if err != nil {
+		log.Fatalf("Cannot load TLS certificates: %v\n", err)
+	}
+
+	// Настраиваем TLS: при TLS_CLIENT_AUTH=optional|require сервер проверяет клиентские сертификаты
+	tlsCfg, err := tlsconfig.NewServerConfig(cfg, certStore)
+	if err != nil {
+		log.Fatalf("Cannot initialize TLS: %v\n", err)
+	}
+	if cfg.TLSClientAuth != tlsconfig.ClientAuthNone {
+		log.Printf("Client certificates %s, CA bundle %s", cfg.TLSClientAuth, cfg.TLSClientCAPath)
+	}
+
+	// Создаем сервисы приложения: одни и те же экземпляры обслуживают запросы API
+	// и выполняют фоновые задачи
+	srv := service.NewServices(pool, store, notifier, attempts, oidcProvider, jwtSrv, cfg)
+
+	// Создаем первого администратора, если его пароль задан в ADMIN_PASSWORD
+	if err := srv.User.Bootstrap(context.Background(), cfg.AdminLogin, cfg.AdminPassword); err != nil {
+		log.Fatalf("Cannot create admin user: %v\n", err)
+	}
+
 	// Создаем HTTP-маршрутизатор и регистрируем маршруты API
 	mux := http.NewServeMux()
-	handlers.RegisterRoutes(mux, pool)
+	handlers.RegisterRoutes(mux, srv, cfg)
+
+	// Запускаем фоновые задачи: сборку мусора брошенных возобновляемых загрузок
+	// и удаление просроченных сессий, счётчиков подписанных ссылок, токенов сброса пароля,
+	// счётчиков неудачных попыток входа и незавершённых входов (со вторым фактором и через OIDC),
+	// а также смену ключей подписи JWT и обновление списка отозванных сессий
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "session-sweeper", srv.Auth.DeleteExpiredSessions)
+	go service.RunPeriodically(bgCtx, cfg.JWTSyncInterval, "jwt-sync", srv.JWT.Sync)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "revoked-session-sweeper", srv.JWT.DeleteExpired)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "mfa-challenge-sweeper", srv.MFA.DeleteExpiredChallenges)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "oidc-state-sweeper", srv.OIDC.DeleteExpiredStates)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "login-attempt-sweeper", srv.LoginGuard.DeleteExpired)
+	go service.RunPeriodically(bgCtx, cfg.UploadGCInterval, "upload-gc", srv.Upload.CollectGarbage)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "presign-sweeper", srv.Presign.DeleteExpiredUses)
+	go service.RunPeriodically(bgCtx, cfg.SessionSweepInterval, "password-reset-sweeper", srv.Account.DeleteExpiredResetTokens)
+
+	// Перечитываем сертификаты сервера при изменении файлов и по сигналу SIGHUP
+	go service.RunPeriodically(bgCtx, cfg.TLSReloadInterval, "tls-cert-reload", certStore.ReloadIfChanged)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := certStore.Reload(bgCtx); err != nil {
+				log.Printf("[ERROR] Failed to reload TLS certificates on SIGHUP: %v", err)
+			}
+		}
+	}()
 
-	// Настраиваем HTTP-сервер с таймаутами
+	// Настраиваем HTTP-сервер с таймаутами (для передачи содержимого файлов таймаут записи
+	// продлевается обработчиками, пока идут данные, — см. TRANSFER_IDLE_TIMEOUT)
 	server := &http.Server{
 		Addr:              ":" + cfg.AppPort,
 		Handler:           mux,
+		TLSConfig:         tlsCfg,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      15 * time.Second,
 		IdleTimeout:       60 * time.Second,
@@ -40,7 +155,7 @@ func main() {
 	// Запускаем HTTPS-сервер в отдельной горутине
 	go func() {
 		log.Printf("Starting HTTPS server on port %s", cfg.AppPort)
-		if err := server.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath); err != nil && err != http.ErrServerClosed {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ListenAndServeTLS error: %v", err)
 		}
 	}()
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"runtime"
+	"strconv"
+	"time"
 )
 
 // Этот файл читает настройки из переменных окружения (с дефолтными значениями) и используется для настройки подключения к базе данных, порта приложения и путей к TLS-сертификатам.
@@ -16,6 +19,121 @@ type Config struct {
 	AppPort     string
 	TLSCertPath string
 	TLSKeyPath  string
+
+	// Сертификаты сервера: дополнительные пары сертификат/ключ для выбора по SNI (пути через
+	// запятую, в одинаковом порядке), период проверки файлов на изменение (они перечитываются
+	// и по SIGHUP), минимальная версия TLS ("1.2" или "1.3") и наборы шифров TLS 1.2 (имена IANA
+	// через запятую; пусто — набор Go по умолчанию). TLSSelfSigned — режим разработки: если файлов
+	// TLSCertPath и TLSKeyPath нет, при запуске создаётся самоподписанный сертификат
+	TLSExtraCertPaths string
+	TLSExtraKeyPaths  string
+	TLSReloadInterval time.Duration
+	TLSMinVersion     string
+	TLSCipherSuites   string
+	TLSSelfSigned     bool
+
+	// Клиентские сертификаты (mTLS): "none" — не запрашиваются, "optional" — проверяются, если
+	// клиент их предъявил, "require" — обязательны для любого соединения; TLSClientCAPath —
+	// PEM-файл с сертификатами CA, которыми должны быть подписаны клиентские сертификаты
+	TLSClientAuth   string
+	TLSClientCAPath string
+
+	// Сессии: токен доступа истекает после SessionIdleTimeout без активности, сессия целиком —
+	// через SessionMaxLifetime после входа; refresh-токен действителен RefreshTokenTTL с момента выдачи
+	SessionIdleTimeout   time.Duration
+	SessionMaxLifetime   time.Duration
+	RefreshTokenTTL      time.Duration
+	SessionSweepInterval time.Duration
+
+	// Формат токенов доступа: "opaque" (случайный токен, проверяется по таблице сессий) или "jwt"
+	// (подписанный JWT, проверяется без обращения к БД). Для JWT: алгоритм подписи ("EdDSA"
+	// или "ES256"), издатель (iss), срок действия токена, период смены ключа подписи и период,
+	// с которым перечитываются ключи и список отозванных сессий
+	AccessTokenFormat      string
+	JWTAlgorithm           string
+	JWTIssuer              string
+	JWTAccessTTL           time.Duration
+	JWTKeyRotationInterval time.Duration
+	JWTSyncInterval        time.Duration
+
+	// Схема хеширования новых паролей: "argon2id" (по умолчанию) или "bcrypt"
+	PasswordHashScheme string
+
+	// Первый администратор: если задан пароль, при запуске создаётся пользователь AdminLogin
+	// с ролью admin (существующий пользователь с этим логином не меняется)
+	AdminLogin    string
+	AdminPassword string
+
+	// Хранилище содержимого файлов: "local" (каталог на диске) или "s3" (S3-совместимый сервис)
+	StorageBackend   string
+	StorageLocalPath string
+
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3UsePathStyle bool
+
+	// Возобновляемые (chunked) загрузки: время жизни незавершённой загрузки, период сборки мусора
+	// и максимум незавершённых загрузок одного пользователя (0 — без ограничения)
+	UploadTTL        time.Duration
+	UploadGCInterval time.Duration
+	UploadMaxPending int
+
+	// Передача содержимого файлов (загрузка, скачивание, подписанные и публичные ссылки) не
+	// ограничивается общим таймаутом записи сервера: соединение разрывается, только если
+	// за TransferIdleTimeout не передано ни одного байта
+	TransferIdleTimeout time.Duration
+
+	// Подписанные ссылки на файлы: ключ подписи HMAC (если не задан, генерируется при запуске,
+	// и выданные ранее ссылки перестают действовать после перезапуска) и максимальный срок действия
+	PresignSecret string
+	PresignMaxTTL time.Duration
+
+	// Учётные записи: разрешена ли самостоятельная регистрация и срок действия токена сброса пароля
+	RegistrationEnabled bool
+	PasswordResetTTL    time.Duration
+
+	// Канал уведомлений пользователям: "log" (журнал сервера) или "file" (JSON-строки в файле)
+	Notifier         string
+	NotifierFilePath string
+
+	// Защита от перебора паролей: хранилище счётчиков неудачных входов ("postgres" или "memory"),
+	// число неудач подряд до временной блокировки логина и IP-адреса, начальная задержка
+	// экспоненциального backoff, длительность блокировки, окно, после которого счёт сбрасывается,
+	// и число одновременных проверок пароля (каждая для argon2id занимает десятки МиБ памяти)
+	LoginAttemptStore      string
+	LoginMaxFailures       int
+	LoginIPMaxFailures     int
+	LoginBackoffBase       time.Duration
+	LoginLockoutDuration   time.Duration
+	LoginFailureWindow     time.Duration
+	LoginVerifyConcurrency int
+
+	// Второй фактор (TOTP): название сервиса в приложении-аутентификаторе и срок, за который
+	// нужно ввести код после проверки пароля
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+
+	// Вход по паролю: если выключен, пользователи входят только через провайдера OIDC
+	PasswordLoginEnabled bool
+
+	// Вход через провайдера OpenID Connect (включается заданием OIDCIssuerURL): параметры клиента,
+	// запрашиваемые области (через пробел), утверждение с логином пользователя, утверждение
+	// с группами и соответствие групп ролям ("группа=роль,..."), роль новых пользователей,
+	// привязка к существующим пользователям по логину и срок, за который нужно завершить вход
+	OIDCIssuerURL         string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            string
+	OIDCLoginClaim        string
+	OIDCRoleClaim         string
+	OIDCRoleMapping       string
+	OIDCDefaultRole       string
+	OIDCLinkExistingUsers bool
+	OIDCStateTTL          time.Duration
 }
 
 func NewConfig() *Config {
@@ -28,6 +146,83 @@ func NewConfig() *Config {
 		AppPort:     getEnv("APP_PORT", "8443"),
 		TLSCertPath: getEnv("TLS_CERT_PATH", "certs/cert.pem"), // например, cert.pem
 		TLSKeyPath:  getEnv("TLS_KEY_PATH", "certs/key.pem"),   // например, key.pem
+
+		TLSExtraCertPaths: getEnv("TLS_EXTRA_CERT_PATHS", ""), // например, certs/api.pem,certs/cdn.pem
+		TLSExtraKeyPaths:  getEnv("TLS_EXTRA_KEY_PATHS", ""),  // например, certs/api-key.pem,certs/cdn-key.pem
+		TLSReloadInterval: getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+		TLSMinVersion:     getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites:   getEnv("TLS_CIPHER_SUITES", ""),
+		TLSSelfSigned:     getEnvBool("TLS_SELF_SIGNED", false),
+
+		TLSClientAuth:   getEnv("TLS_CLIENT_AUTH", "none"),
+		TLSClientCAPath: getEnv("TLS_CLIENT_CA_PATH", "certs/client-ca.pem"),
+
+		SessionIdleTimeout:   getEnvDuration("SESSION_IDLE_TIMEOUT", 2*time.Hour),
+		SessionMaxLifetime:   getEnvDuration("SESSION_MAX_LIFETIME", 30*24*time.Hour),
+		RefreshTokenTTL:      getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		SessionSweepInterval: getEnvDuration("SESSION_SWEEP_INTERVAL", 10*time.Minute),
+
+		AccessTokenFormat:      getEnv("ACCESS_TOKEN_FORMAT", "opaque"),
+		JWTAlgorithm:           getEnv("JWT_ALGORITHM", "EdDSA"),
+		JWTIssuer:              getEnv("JWT_ISSUER", "go-asset-service"),
+		JWTAccessTTL:           getEnvDuration("JWT_ACCESS_TTL", 5*time.Minute),
+		JWTKeyRotationInterval: getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 24*time.Hour),
+		JWTSyncInterval:        getEnvDuration("JWT_SYNC_INTERVAL", 15*time.Second),
+
+		PasswordHashScheme: getEnv("PASSWORD_HASH_SCHEME", "argon2id"),
+
+		AdminLogin:    getEnv("ADMIN_LOGIN", "admin"),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		StorageBackend:   getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalPath: getEnv("STORAGE_LOCAL_PATH", "data/blobs"),
+
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""), // например, http://localhost:9000 для MinIO
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       getEnv("S3_BUCKET", "assets"),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle: getEnvBool("S3_USE_PATH_STYLE", true),
+
+		UploadTTL:        getEnvDuration("UPLOAD_TTL", 24*time.Hour),
+		UploadGCInterval: getEnvDuration("UPLOAD_GC_INTERVAL", time.Hour),
+		UploadMaxPending: getEnvInt("UPLOAD_MAX_PENDING", 10),
+
+		TransferIdleTimeout: getEnvDuration("TRANSFER_IDLE_TIMEOUT", time.Minute),
+
+		PresignSecret: getEnv("PRESIGN_SECRET", ""),
+		PresignMaxTTL: getEnvDuration("PRESIGN_MAX_TTL", 7*24*time.Hour),
+
+		RegistrationEnabled: getEnvBool("REGISTRATION_ENABLED", false),
+		PasswordResetTTL:    getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		Notifier:         getEnv("NOTIFIER", "log"),
+		NotifierFilePath: getEnv("NOTIFIER_FILE_PATH", "data/notifications.log"),
+
+		LoginAttemptStore:      getEnv("LOGIN_ATTEMPT_STORE", "postgres"),
+		LoginMaxFailures:       getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:     getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginBackoffBase:       getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginLockoutDuration:   getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginFailureWindow:     getEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+		LoginVerifyConcurrency: getEnvInt("LOGIN_VERIFY_CONCURRENCY", runtime.NumCPU()),
+
+		MFAIssuer:       getEnv("MFA_ISSUER", "go-asset-service"),
+		MFAChallengeTTL: getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+
+		PasswordLoginEnabled: getEnvBool("PASSWORD_LOGIN_ENABLED", true),
+
+		OIDCIssuerURL:         getEnv("OIDC_ISSUER_URL", ""), // например, https://sso.example.com/realms/main
+		OIDCClientID:          getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       getEnv("OIDC_REDIRECT_URL", "https://localhost:8443/api/auth/oidc/callback"),
+		OIDCScopes:            getEnv("OIDC_SCOPES", "openid profile email"),
+		OIDCLoginClaim:        getEnv("OIDC_LOGIN_CLAIM", "preferred_username"),
+		OIDCRoleClaim:         getEnv("OIDC_ROLE_CLAIM", ""), // например, groups
+		OIDCRoleMapping:       getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCDefaultRole:       getEnv("OIDC_DEFAULT_ROLE", "user"),
+		OIDCLinkExistingUsers: getEnvBool("OIDC_LINK_EXISTING_USERS", false),
+		OIDCStateTTL:          getEnvDuration("OIDC_STATE_TTL", 10*time.Minute),
 	}
 }
 
@@ -38,3 +233,27 @@ func getEnv(key, defVal string) string {
 	}
 	return val
 }
+
+func getEnvBool(key string, defVal bool) bool {
+	val, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defVal
+	}
+	return val
+}
+
+func getEnvInt(key string, defVal int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || val <= 0 {
+		return defVal
+	}
+	return val
+}
+
+func getEnvDuration(key string, defVal time.Duration) time.Duration {
+	val, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || val <= 0 {
+		return defVal
+	}
+	return val
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-asset-service/internal/service"
+)
+
+// weakPasswordMessage — текст ошибки для пароля, не прошедшего проверку сложности.
+const weakPasswordMessage = "password must be 10-72 bytes long, contain at least two of letters, digits and other characters, must not contain the login and must not be a common password"
+
+// AccountHandler реализует HTTP-обработчики самостоятельной регистрации, смены пароля
+// и сброса забытого пароля.
+type AccountHandler struct {
+	accountService *service.AccountService // Сервис учётных записей
+	auth           *Authenticator          // Проверка токена сессии (смена пароля)
+}
+
+// NewAccountHandler создает новый экземпляр AccountHandler.
+func NewAccountHandler(accountService *service.AccountService, auth *Authenticator) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		auth:           auth,
+	}
+}
+
+// registerRequest описывает JSON-запрос самостоятельной регистрации.
+type registerRequest struct {
+	Login    string `json:"login"`    // Логин
+	Password string `json:"password"` // Пароль
+}
+
+// changePasswordRequest описывает JSON-запрос смены пароля.
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"` // Текущий пароль
+	NewPassword     string `json:"new_password"`     // Новый пароль
+}
+
+// passwordResetRequest описывает JSON-запрос на выдачу токена сброса пароля.
+type passwordResetRequest struct {
+	Login string `json:"login"` // Логин пользователя, забывшего пароль
+}
+
+// confirmPasswordResetRequest описывает JSON-запрос сброса пароля по токену.
+type confirmPasswordResetRequest struct {
+	Token       string `json:"token"`        // Токен из уведомления
+	NewPassword string `json:"new_password"` // Новый пароль
+}
+
+// Register обрабатывает POST /api/register.
+func (h *AccountHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.accountService.Register(context.Background(), req.Login, req.Password)
+	switch {
+	case errors.Is(err, service.ErrRegistrationDisabled):
+		http.Error(w, `{"error":"registration disabled"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrInvalidUser):
+		http.Error(w, `{"error":"login must be 3-64 characters of a-z, 0-9, '.', '_', '-', '@'"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, `{"error":"`+weakPasswordMessage+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserExists):
+		http.Error(w, `{"error":"login already taken"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to register user: login=%s ip=%s err=%v", req.Login, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to register"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] User registered: id=%d login=%s ip=%s", user.ID, user.Login, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// ChangePassword обрабатывает POST /api/auth/password.
+// Меняет пароль после проверки текущего и завершает все сессии пользователя, кроме текущей.
+// Неверные текущие пароли ограничиваются так же, как попытки входа: при превышении
+// ответ — 429 с Retry-After.
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized change-password attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(r)
+	err = h.accountService.ChangePassword(context.Background(), userSession, req.CurrentPassword, req.NewPassword, ip)
+	var attemptsErr *service.AttemptsError
+	switch {
+	case errors.Is(err, service.ErrWrongPassword):
+		log.Printf("[WARN] Wrong current password on change-password: user=%d ip=%s", userSession.UID, r.RemoteAddr)
+		http.Error(w, `{"error":"wrong current password"}`, http.StatusForbidden)
+		return
+	case errors.As(err, &attemptsErr):
+		retryAfter := int64((attemptsErr.RetryAfter + time.Second - 1) / time.Second)
+		log.Printf("[WARN] Change-password attempt throttled: user=%d ip=%s retry_after=%ds", userSession.UID, ip, retryAfter)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		http.Error(w, `{"error":"too many password attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrExternalAccount):
+		http.Error(w, `{"error":"password is managed by the identity provider"}`, http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, `{"error":"`+weakPasswordMessage+`"}`, http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to change password: user=%d err=%v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to change password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Password changed: user=%d ip=%s", userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// RequestPasswordReset обрабатывает POST /api/auth/password-reset.
+// Ответ не зависит от того, существует ли пользователь: токен уходит через канал уведомлений.
+func (h *AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req passwordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Login == "" {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	auditDetail(r, "login", req.Login)
+
+	if err := h.accountService.RequestPasswordReset(context.Background(), req.Login); err != nil {
+		log.Printf("[ERROR] Failed to request password reset: login=%s ip=%s err=%v", req.Login, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to request password reset"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Password reset requested: login=%s ip=%s", req.Login, r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "ok"})
+}
+
+// ConfirmPasswordReset обрабатывает POST /api/auth/password-reset/confirm: задаёт новый пароль
+// по токену сброса и завершает все сессии пользователя.
+func (h *AccountHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req confirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.accountService.ResetPassword(context.Background(), req.Token, req.NewPassword)
+	switch {
+	case errors.Is(err, service.ErrInvalidResetToken), errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrExternalAccount):
+		log.Printf("[WARN] Invalid password reset token from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"invalid or expired token"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, `{"error":"`+weakPasswordMessage+`"}`, http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to reset password: ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Password reset completed: ip=%s", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// ACLHandler реализует HTTP-обработчики совместного доступа к файлам: выдачу и отзыв прав
+// (ACL) и управление группами пользователей. Управлять доступом можно только с токеном сессии.
+type ACLHandler struct {
+	aclService *service.ACLService // Сервис групп и списков доступа
+	auth       *Authenticator      // Проверка токена сессии
+}
+
+// NewACLHandler создает новый экземпляр ACLHandler.
+func NewACLHandler(aclService *service.ACLService, auth *Authenticator) *ACLHandler {
+	return &ACLHandler{
+		aclService: aclService,
+		auth:       auth,
+	}
+}
+
+// createGrantRequest описывает JSON-запрос на выдачу права.
+type createGrantRequest struct {
+	Name       string `json:"name"`       // Имя файла или префикс с «/» на конце
+	Permission string `json:"permission"` // read, write или delete
+	User       string `json:"user"`       // Логин пользователя, получающего доступ
+	Group      string `json:"group"`      // Или имя группы, получающей доступ
+}
+
+// CreateGrant обрабатывает POST /api/grants.
+func (h *ACLHandler) CreateGrant(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized create-grant attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req createGrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	grant, err := h.aclService.Grant(context.Background(), userSession.UID, req.Name, req.Permission, req.User, req.Group)
+	switch {
+	case errors.Is(err, service.ErrInvalidGrant):
+		http.Error(w, `{"error":"valid name, permission (read, write, delete) and exactly one of user or group are required"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrGroupNotFound):
+		http.Error(w, `{"error":"user or group not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrGrantExists):
+		http.Error(w, `{"error":"grant already exists"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to create grant: user=%d name=%s err=%v", userSession.UID, req.Name, err)
+		http.Error(w, `{"error":"failed to create grant"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Grant created: id=%d name=%s permission=%s grantee_user=%s grantee_group=%s user=%d ip=%s",
+		grant.ID, grant.Name, grant.Permission, req.User, req.Group, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, grant)
+}
+
+// ListGrants обрабатывает GET /api/grants: возвращает права, выданные пользователем на свои файлы.
+func (h *ACLHandler) ListGrants(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-grants attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	grants, err := h.aclService.ListGrants(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list grants for user=%d: %v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to list grants"}`, http.StatusInternalServerError)
+		return
+	}
+	if grants == nil {
+		grants = []models.Grant{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"grants": grants})
+}
+
+// RevokeGrant обрабатывает DELETE /api/grants/{id}.
+func (h *ACLHandler) RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized revoke-grant attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/grants/"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	err = h.aclService.Revoke(context.Background(), userSession.UID, id)
+	if errors.Is(err, service.ErrGrantNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke grant: id=%d user=%d err=%v", id, userSession.UID, err)
+		http.Error(w, `{"error":"failed to revoke grant"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Grant revoked: id=%d user=%d ip=%s", id, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// CreateGroup обрабатывает POST /api/groups с JSON {"name": "..."}.
+func (h *ACLHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized create-group attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.aclService.CreateGroup(context.Background(), userSession.UID, req.Name)
+	switch {
+	case errors.Is(err, service.ErrInvalidGroupName):
+		http.Error(w, `{"error":"invalid group name"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrGroupExists):
+		http.Error(w, `{"error":"group already exists"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to create group: name=%s user=%d err=%v", req.Name, userSession.UID, err)
+		http.Error(w, `{"error":"failed to create group"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Group created: name=%s user=%d ip=%s", group.Name, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// ListGroups обрабатывает GET /api/groups: группы, которыми пользователь владеет или в которых состоит.
+func (h *ACLHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-groups attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := h.aclService.ListGroups(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list groups for user=%d: %v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to list groups"}`, http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []models.Group{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"groups": groups})
+}
+
+// DeleteGroup обрабатывает DELETE /api/groups/{name}: удаляет группу вместе с выданными ей правами.
+func (h *ACLHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized delete-group attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/groups/")
+	err = h.aclService.DeleteGroup(context.Background(), userSession.UID, name)
+	if errors.Is(err, service.ErrGroupNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete group: name=%s user=%d err=%v", name, userSession.UID, err)
+		http.Error(w, `{"error":"failed to delete group"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Group deleted: name=%s user=%d ip=%s", name, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// UpdateMember обрабатывает PUT (добавление) и DELETE (исключение)
+// /api/groups/{name}/members/{login}. Добавлять и исключать участников может владелец группы;
+// участник может исключить из группы себя сам.
+func (h *ACLHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized group-member attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	group, login, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/groups/"), "/members/")
+	if !ok || group == "" || login == "" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		err = h.aclService.AddMember(context.Background(), userSession.UID, group, login)
+	} else {
+		err = h.aclService.RemoveMember(context.Background(), userSession.UID, group, login)
+	}
+	switch {
+	case errors.Is(err, service.ErrGroupNotFound), errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMemberNotFound):
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrNotGroupOwner):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to update group member: group=%s login=%s user=%d err=%v", group, login, userSession.UID, err)
+		http.Error(w, `{"error":"failed to update group"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Group member updated: method=%s group=%s login=%s user=%d ip=%s", r.Method, group, login, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// AdminHandler реализует API администрирования /api/admin/users: управление учётными записями
+// и просмотр файлов любого пользователя. Доступ ограничивается middleware Authenticator.RequireRole.
+type AdminHandler struct {
+	userService  *service.UserService  // Сервис управления пользователями
+	assetService *service.AssetService // Сервис для просмотра файлов пользователя
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler.
+func NewAdminHandler(userService *service.UserService, assetService *service.AssetService) *AdminHandler {
+	return &AdminHandler{
+		userService:  userService,
+		assetService: assetService,
+	}
+}
+
+// createUserRequest описывает JSON-запрос на создание пользователя.
+type createUserRequest struct {
+	Login    string `json:"login"`    // Логин
+	Password string `json:"password"` // Пароль (см. требования к сложности)
+	Role     string `json:"role"`     // Роль (по умолчанию user)
+}
+
+// updateUserRequest описывает JSON-запрос на изменение пользователя; отсутствующие поля не меняются.
+type updateUserRequest struct {
+	Role     *string `json:"role"`     // Новая роль
+	Disabled *bool   `json:"disabled"` // Заблокировать (true) или разблокировать (false)
+}
+
+// resetPasswordRequest описывает JSON-запрос на сброс пароля пользователя.
+type resetPasswordRequest struct {
+	Password string `json:"password"` // Новый пароль
+}
+
+// ListUsers обрабатывает GET /api/admin/users.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	users, err := h.userService.List(context.Background())
+	if err != nil {
+		log.Printf("[ERROR] Failed to list users: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to list users"}`, http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"users": users})
+}
+
+// CreateUser обрабатывает POST /api/admin/users.
+func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	var req createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.Create(context.Background(), req.Login, req.Password, req.Role)
+	if !h.handleError(w, err, "create user", admin, 0) {
+		return
+	}
+
+	log.Printf("[INFO] User created: id=%d login=%s role=%s admin=%d ip=%s", user.ID, user.Login, user.Role, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// GetUser обрабатывает GET /api/admin/users/{id}.
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.userService.Get(context.Background(), id)
+	if !h.handleError(w, err, "get user", admin, id) {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateUser обрабатывает PATCH /api/admin/users/{id}: смена роли, блокировка и разблокировка.
+func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req updateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.Update(context.Background(), admin.UID, id, req.Role, req.Disabled)
+	if !h.handleError(w, err, "update user", admin, id) {
+		return
+	}
+
+	log.Printf("[INFO] User updated: id=%d role=%s disabled=%t admin=%d ip=%s", id, user.Role, user.DisabledAt != nil, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser обрабатывает DELETE /api/admin/users/{id}: удаляет пользователя вместе с его файлами.
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := h.userService.Delete(context.Background(), admin.UID, id)
+	if !h.handleError(w, err, "delete user", admin, id) {
+		return
+	}
+
+	log.Printf("[INFO] User deleted by admin: id=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ResetPassword обрабатывает POST /api/admin/users/{id}/password: задаёт новый пароль
+// и завершает все сессии пользователя.
+func (h *AdminHandler) ResetPassword(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.userService.ResetPassword(context.Background(), id, req.Password)
+	if !h.handleError(w, err, "reset password", admin, id) {
+		return
+	}
+
+	log.Printf("[INFO] Password reset by admin: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// RevokeSessions обрабатывает DELETE /api/admin/users/{id}/sessions: завершает все сессии пользователя.
+func (h *AdminHandler) RevokeSessions(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := h.userService.RevokeSessions(context.Background(), id)
+	if !h.handleError(w, err, "revoke sessions", admin, id) {
+		return
+	}
+
+	log.Printf("[INFO] Sessions revoked by admin: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// UnlockUser обрабатывает POST /api/admin/users/{id}/unlock: снимает временную блокировку
+// входа после неудачных попыток.
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := h.userService.Unlock(context.Background(), id)
+	if !h.handleError(w, err, "unlock user", admin, id) {
+		return
+	}
+
+	log.Printf("[INFO] Login lockout cleared by admin: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ListUserAssets обрабатывает GET /api/admin/users/{id}/assets: список файлов пользователя
+// с теми же параметрами, что и GET /api/assets.
+func (h *AdminHandler) ListUserAssets(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.userService.Get(context.Background(), id); !h.handleError(w, err, "get user", admin, id) {
+		return
+	}
+
+	opts, err := parseAssetListOptions(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid query parameter: `+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	// Администратор видит только файлы самого пользователя, без доступных ему по ACL
+	opts.Owner = id
+
+	page, err := h.assetService.List(context.Background(), id, opts)
+	if errors.Is(err, service.ErrInvalidCursor) {
+		http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidListOptions) {
+		http.Error(w, `{"error":"sort must be one of name, size, created_at (only name with delimiter)"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to list assets: user=%d admin=%d err=%v", id, admin.UID, err)
+		http.Error(w, `{"error":"failed to list assets"}`, http.StatusInternalServerError)
+		return
+	}
+	if page.Assets == nil {
+		page.Assets = []models.Asset{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleError отвечает ошибкой сервиса пользователей с подходящим статусом.
+// Возвращает true, если ошибки нет.
+func (h *AdminHandler) handleError(w http.ResponseWriter, err error, action string, admin *models.Principal, id int64) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidUser):
+		http.Error(w, `{"error":"login must be 3-64 characters of a-z, 0-9, '.', '_', '-', '@'; role one of admin, user, readonly"}`, http.StatusBadRequest)
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, `{"error":"`+weakPasswordMessage+`"}`, http.StatusBadRequest)
+	case errors.Is(err, service.ErrUserExists):
+		http.Error(w, `{"error":"login already taken"}`, http.StatusConflict)
+	case errors.Is(err, service.ErrSelfModification):
+		http.Error(w, `{"error":"cannot delete, disable or demote own account"}`, http.StatusConflict)
+	default:
+		log.Printf("[ERROR] Failed to %s: user=%d admin=%d err=%v", action, id, admin.UID, err)
+		http.Error(w, `{"error":"failed to `+action+`"}`, http.StatusInternalServerError)
+	}
+	return false
+}
+
+// userIDFromPath извлекает идентификатор пользователя из пути /api/admin/users/{id}[/...].
+// При ошибке отвечает 404 и возвращает false.
+func userIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	v, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
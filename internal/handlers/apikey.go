@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// APIKeyHandler реализует HTTP-обработчики выпуска, просмотра и отзыва API-ключей.
+// Управлять ключами можно только с токеном сессии: API-ключ не может выпустить другой ключ.
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService // Сервис API-ключей
+	auth          *Authenticator         // Проверка токена сессии
+}
+
+// NewAPIKeyHandler создает новый экземпляр APIKeyHandler.
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService, auth *Authenticator) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		auth:          auth,
+	}
+}
+
+// createAPIKeyRequest описывает JSON-запрос на выпуск API-ключа.
+type createAPIKeyRequest struct {
+	Name         string     `json:"name"`          // Название ключа
+	Scopes       []string   `json:"scopes"`        // Области действия
+	NamePrefixes []string   `json:"name_prefixes"` // Разрешённые префиксы имён файлов
+	ExpiresAt    *time.Time `json:"expires_at"`    // Срок действия (необязательно)
+}
+
+// createAPIKeyResponse содержит выпущенный ключ; поле key возвращается только один раз.
+type createAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey обрабатывает POST /api/keys.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized create-api-key attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	key, apiKey, err := h.apiKeyService.Create(context.Background(), userSession.UID, req.Name, req.Scopes, req.NamePrefixes, req.ExpiresAt)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		http.Error(w, `{"error":"name and valid scopes are required, expires_at must be in the future"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create api key: user=%d err=%v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to create api key"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] API key created: id=%d prefix=%s user=%d ip=%s", apiKey.ID, apiKey.Prefix, userSession.UID, r.RemoteAddr)
+	auditDetail(r, "api_key", apiKey.Prefix)
+	writeJSON(w, http.StatusCreated, createAPIKeyResponse{Key: key, APIKey: apiKey})
+}
+
+// ListAPIKeys обрабатывает GET /api/keys: возвращает ключи пользователя без самих секретов.
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-api-keys attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.apiKeyService.List(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list api keys for user=%d: %v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to list api keys"}`, http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
+}
+
+// RevokeAPIKey обрабатывает DELETE /api/keys/{id}.
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized revoke-api-key attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/keys/"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	err = h.apiKeyService.Revoke(context.Background(), userSession.UID, id)
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke api key: id=%d user=%d err=%v", id, userSession.UID, err)
+		http.Error(w, `{"error":"failed to revoke api key"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] API key revoked: id=%d user=%d ip=%s", id, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
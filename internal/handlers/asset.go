@@ -3,139 +3,230 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"go-asset-service/internal/models"
-	"go-asset-service/internal/repository"
 	"go-asset-service/internal/service"
 )
 
+// Пользовательские метаданные файла передаются в заголовках X-Asset-Meta-<ключ>: <значение>.
+const (
+	assetMetaHeaderPrefix = "X-Asset-Meta-"
+	maxAssetMetadataSize  = 2048 // Суммарный размер ключей и значений в байтах
+)
+
+// errInvalidMetadata возвращается, если пользовательские метаданные превышают maxAssetMetadataSize
+// или содержат ключ, недопустимый в имени HTTP-заголовка.
+var errInvalidMetadata = errors.New("invalid asset metadata")
+
 // AssetHandler реализует HTTP-обработчики для работы с файлами (assets)
 type AssetHandler struct {
-	assetRepo   *repository.AssetRepository // Репозиторий для работы с данными файлов
-	authService *service.AuthService        // Сервис авторизации для проверки токена
+	assetService   *service.AssetService   // Сервис для работы с файлами и их содержимым
+	aclService     *service.ACLService     // Проверка доступа к чужим файлам по спискам доступа
+	presignService *service.PresignService // Выпуск и проверка подписанных ссылок
+	auth           *Authenticator          // Проверка токена сессии или API-ключа
 }
 
 // NewAssetHandler создает новый экземпляр AssetHandler
-func NewAssetHandler(assetRepo *repository.AssetRepository, auth *service.AuthService) *AssetHandler {
+func NewAssetHandler(assetService *service.AssetService, aclService *service.ACLService, presignService *service.PresignService, auth *Authenticator) *AssetHandler {
 	return &AssetHandler{
-		assetRepo:   assetRepo,
-		authService: auth,
+		assetService:   assetService,
+		aclService:     aclService,
+		presignService: presignService,
+		auth:           auth,
 	}
 }
 
 // UploadAsset обрабатывает запрос POST /api/upload-asset/{assetName}.
-// Он проверяет авторизацию, извлекает имя файла из URL, читает тело запроса
-// и сохраняет данные в базе данных.
+// Он проверяет авторизацию, извлекает имя файла из URL и потоково
+// записывает тело запроса в хранилище, сохраняя в базе данных только метаданные.
+// Если файл с таким именем уже есть, создаётся его новая версия.
+// Тип содержимого берётся из Content-Type (если он не указан — определяется по содержимому),
+// пользовательские метаданные — из заголовков X-Asset-Meta-*.
+// С параметром ?owner=<uid> загружается новая версия чужого файла, если владелец выдал право write.
 func (h *AssetHandler) UploadAsset(w http.ResponseWriter, r *http.Request) {
 	// Проверка авторизации через заголовок Authorization: Bearer <token>
-	userSession, err := h.checkAuth(r)
+	principal, err := h.checkAuth(r)
 	if err != nil {
 		log.Printf("[WARN] Unauthorized upload attempt from ip=%s err=%v", r.RemoteAddr, err)
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Извлечение имени файла из URL (последний сегмент пути)
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		log.Printf("[ERROR] Bad request (missing asset name), user=%d ip=%s", userSession.UID, r.RemoteAddr)
-		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+	// Извлечение имени файла из URL: всё, что после /api/upload-asset/, включая «папки»
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/upload-asset/")
+	auditTarget(r, assetName)
+	if !service.ValidAssetName(assetName) {
+		log.Printf("[ERROR] Bad request (invalid asset name %q), user=%d ip=%s", assetName, principal.UID, r.RemoteAddr)
+		http.Error(w, `{"error":"invalid asset name"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkScope(w, r, principal, models.ScopeAssetsWrite, assetName) {
 		return
 	}
-	assetName := parts[len(parts)-1]
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
 
-	// Чтение данных из тела запроса
-	data, err := io.ReadAll(r.Body)
+	contentType, err := requestContentType(r.Header.Get("Content-Type"))
 	if err != nil {
-		log.Printf("[ERROR] Failed to read upload body: user=%d ip=%s err=%v", userSession.UID, r.RemoteAddr, err)
-		http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+		http.Error(w, `{"error":"invalid Content-Type"}`, http.StatusBadRequest)
 		return
 	}
-
-	// Формирование объекта Asset для сохранения в БД
-	asset := &models.Asset{
-		Name:      assetName,
-		UID:       userSession.UID,
-		Data:      data,
-		CreatedAt: time.Now(),
+	metadata, err := metadataFromHeaders(r.Header)
+	if err != nil {
+		http.Error(w, `{"error":"invalid asset metadata"}`, http.StatusBadRequest)
+		return
 	}
 
-	// Сохранение файла (assets) в базе данных через репозиторий
-	err = h.assetRepo.CreateAsset(context.Background(), asset)
+	// Потоковая запись тела запроса в хранилище и сохранение метаданных
+	// Квота владельца проверяется до чтения тела: не помещающийся файл отклоняется сразу
+	asset, err := h.assetService.Upload(context.Background(), owner, assetName, r.Body, r.ContentLength, contentType, metadata)
+	if writeQuotaError(w, err) {
+		log.Printf("[WARN] Upload rejected by quota: user=%d owner=%d name=%s size=%d ip=%s err=%v", principal.UID, owner, assetName, r.ContentLength, r.RemoteAddr, err)
+		return
+	}
 	if err != nil {
-		log.Printf("[ERROR] DB error in CreateAsset: user=%d name=%s ip=%s err=%v", userSession.UID, assetName, r.RemoteAddr, err)
+		log.Printf("[ERROR] Failed to save asset: user=%d owner=%d name=%s ip=%s err=%v", principal.UID, owner, assetName, r.RemoteAddr, err)
 		http.Error(w, `{"error":"failed to save asset"}`, http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Asset uploaded successfully: name=%s user=%d ip=%s", assetName, userSession.UID, r.RemoteAddr)
-	// Возвращаем успешный ответ в формате JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"status":"ok"}`))
+	log.Printf("[INFO] Asset uploaded successfully: name=%s version=%d size=%d user=%d owner=%d ip=%s", assetName, asset.Version, asset.Size, principal.UID, owner, r.RemoteAddr)
+	auditDetail(r, "version", strconv.Itoa(asset.Version))
+	auditDetail(r, "size", strconv.FormatInt(asset.Size, 10))
+	// Возвращаем успешный ответ в формате JSON с номером созданной версии
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "version": asset.Version})
 }
 
-// GetAsset обрабатывает запрос GET /api/asset/{assetName}.
+// GetAsset обрабатывает запросы GET и HEAD /api/asset/{assetName}.
+// Метаданные файла (тип, размер, SHA-256, время изменения, X-Asset-Meta-*) возвращаются
+// в заголовках ответа; HEAD возвращает только их, без содержимого.
 // Проверяет авторизацию, извлекает имя файла из URL и возвращает содержимое файла.
+// Поддерживаются запросы диапазонов (Range, в том числе несколько диапазонов — ответы 206 и 416)
+// и условные запросы (If-None-Match, If-Modified-Since, If-Range): ETag строится из SHA-256
+// содержимого, а Last-Modified — из времени загрузки файла.
+// Параметр ?version=N позволяет скачать одну из прежних версий файла, а ?owner=<uid> —
+// чужой файл, если владелец выдал право read.
 func (h *AssetHandler) GetAsset(w http.ResponseWriter, r *http.Request) {
 	// Проверяем авторизацию
-	userSession, err := h.checkAuth(r)
+	principal, err := h.checkAuth(r)
 	if err != nil {
 		log.Printf("[WARN] Unauthorized get-asset attempt from ip=%s err=%v", r.RemoteAddr, err)
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Извлекаем имя файла из URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		log.Printf("[ERROR] Bad request (missing asset name), user=%d ip=%s", userSession.UID, r.RemoteAddr)
+	// Извлекаем имя файла из URL (имя может содержать «/»)
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/asset/")
+	auditTarget(r, assetName)
+	if assetName == "" {
+		log.Printf("[ERROR] Bad request (missing asset name), user=%d ip=%s", principal.UID, r.RemoteAddr)
 		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
 		return
 	}
-	assetName := parts[len(parts)-1]
+	if !h.checkScope(w, r, principal, models.ScopeAssetsRead, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionRead)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
 
-	// Получаем файл из базы данных
-	asset, err := h.assetRepo.GetAsset(context.Background(), assetName, userSession.UID)
-	if err != nil {
-		log.Printf("[WARN] Asset not found: name=%s user=%d ip=%s err=%v", assetName, userSession.UID, r.RemoteAddr, err)
+	// Получаем метаданные файла (текущей или запрошенной версии) и открываем его содержимое в хранилище
+	var (
+		asset   *models.Asset
+		content io.ReadSeekCloser
+	)
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, convErr := strconv.Atoi(v)
+		if convErr != nil || version <= 0 {
+			http.Error(w, `{"error":"invalid version"}`, http.StatusBadRequest)
+			return
+		}
+		asset, content, err = h.assetService.OpenVersion(context.Background(), owner, assetName, version)
+	} else {
+		asset, content, err = h.assetService.Open(context.Background(), owner, assetName)
+	}
+	if errors.Is(err, service.ErrAssetNotFound) || errors.Is(err, service.ErrVersionNotFound) {
+		log.Printf("[WARN] Asset not found: name=%s user=%d owner=%d ip=%s", assetName, principal.UID, owner, r.RemoteAddr)
 		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to open asset: name=%s user=%d ip=%s err=%v", assetName, principal.UID, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to read asset"}`, http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
 
-	log.Printf("[INFO] Asset retrieved: name=%s user=%d ip=%s", assetName, userSession.UID, r.RemoteAddr)
-	// Отдаем содержимое файла (raw data)
-	w.WriteHeader(http.StatusOK)
-	w.Write(asset.Data)
+	log.Printf("[INFO] Asset retrieved: name=%s version=%d user=%d owner=%d ip=%s", assetName, asset.Version, principal.UID, owner, r.RemoteAddr)
+	auditDetail(r, "version", strconv.Itoa(asset.Version))
+	// Отдаем содержимое файла потоком из хранилища. http.ServeContent сам обрабатывает
+	// Range/If-Range и условные заголовки, используя выставленный ETag и время изменения.
+	setAssetHeaders(w, asset)
+	http.ServeContent(w, r, "", asset.UpdatedAt, content)
 }
 
 // ListAssets обрабатывает запрос GET /api/assets.
-// Возвращает список файлов, загруженных текущим пользователем.
+// Возвращает страницу списка файлов, загруженных текущим пользователем. Параметры запроса:
+// limit, cursor (next_cursor из предыдущего ответа), prefix, delimiter, glob, content_type,
+// created_after, created_before (RFC 3339), min_size, max_size, sort (name, size, created_at)
+// order (asc, desc) и owner (только файлы этого владельца). Кроме своих файлов, в список попадают
+// чужие, право на чтение которых выдано пользователю. С delimiter (обычно «/») вложенные «папки»
+// возвращаются в common_prefixes.
 func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
 	// Проверка авторизации
-	userSession, err := h.checkAuth(r)
+	principal, err := h.checkAuth(r)
 	if err != nil {
 		log.Printf("[WARN] Unauthorized list-assets attempt from ip=%s err=%v", r.RemoteAddr, err)
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Получаем список файлов из базы
-	assets, err := h.assetRepo.ListAssets(context.Background(), userSession.UID)
+	if !h.checkScope(w, r, principal, models.ScopeAssetsRead, "") {
+		return
+	}
+
+	opts, err := parseAssetListOptions(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid query parameter: `+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	// API-ключ или сертификат с ограничением по префиксам видит только разрешённые ему файлы
+	opts.AllowedPrefixes = principal.NamePrefixes()
+
+	// Получаем страницу списка файлов из базы
+	page, err := h.assetService.List(context.Background(), principal.UID, opts)
+	if errors.Is(err, service.ErrInvalidCursor) {
+		http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidListOptions) {
+		http.Error(w, `{"error":"sort must be one of name, size, created_at (only name with delimiter)"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		log.Printf("[ERROR] Failed to list assets for user=%d: %v", userSession.UID, err)
+		log.Printf("[ERROR] Failed to list assets for user=%d: %v", principal.UID, err)
 		http.Error(w, `{"error":"failed to list assets"}`, http.StatusInternalServerError)
 		return
 	}
+	if page.Assets == nil {
+		page.Assets = []models.Asset{}
+	}
 
 	// Сериализуем список в JSON
-	resp, err := json.Marshal(map[string]interface{}{
-		"assets": assets,
-	})
+	resp, err := json.Marshal(page)
 	if err != nil {
 		log.Printf("[ERROR] Failed to marshal assets: %v", err)
 		http.Error(w, `{"error":"failed to marshal response"}`, http.StatusInternalServerError)
@@ -147,8 +238,165 @@ func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
+// ListVersions обрабатывает запрос GET /api/asset-versions/{assetName}.
+// Возвращает историю версий файла (от новой к старой) и номер текущей версии.
+// С параметром ?owner=<uid> — историю чужого файла, если владелец выдал право read.
+func (h *AssetHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-versions attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/asset-versions/")
+	if assetName == "" {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkScope(w, r, principal, models.ScopeAssetsRead, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	versions, current, err := h.assetService.ListVersions(context.Background(), owner, assetName)
+	if errors.Is(err, service.ErrAssetNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to list versions: name=%s user=%d owner=%d err=%v", assetName, principal.UID, owner, err)
+		http.Error(w, `{"error":"failed to list versions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":            assetName,
+		"current_version": current,
+		"versions":        versions,
+	})
+}
+
+// RestoreVersion обрабатывает запрос POST /api/restore-asset/{assetName}?version=N.
+// Делает указанную прежнюю версию файла текущей (откат).
+// С параметром ?owner=<uid> откатывается чужой файл, если владелец выдал право write.
+func (h *AssetHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized restore-asset attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/restore-asset/")
+	auditTarget(r, assetName)
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if assetName == "" || err != nil || version <= 0 {
+		http.Error(w, `{"error":"asset name and positive version are required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkScope(w, r, principal, models.ScopeAssetsWrite, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
+	auditDetail(r, "restored_version", strconv.Itoa(version))
+
+	asset, err := h.assetService.Restore(context.Background(), owner, assetName, version)
+	if errors.Is(err, service.ErrAssetNotFound) || errors.Is(err, service.ErrVersionNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to restore asset: name=%s version=%d user=%d owner=%d err=%v", assetName, version, principal.UID, owner, err)
+		http.Error(w, `{"error":"failed to restore asset"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Asset restored: name=%s version=%d user=%d owner=%d ip=%s", assetName, version, principal.UID, owner, r.RemoteAddr)
+	auditDetail(r, "version", strconv.Itoa(asset.Version))
+	writeJSON(w, http.StatusOK, asset)
+}
+
+// PruneVersions обрабатывает запрос DELETE /api/asset-versions/{assetName}?keep=N&older_than=D.
+// Удаляет прежние версии файла: все, кроме keep самых новых, и/или старше older_than
+// (длительность в формате Go, например 720h). Текущая версия не удаляется.
+// С параметром ?owner=<uid> удаляются версии чужого файла, если владелец выдал право delete.
+func (h *AssetHandler) PruneVersions(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized prune-versions attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/asset-versions/")
+	auditTarget(r, assetName)
+	if assetName == "" {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	var (
+		keep   int
+		maxAge time.Duration
+	)
+	if v := r.URL.Query().Get("keep"); v != "" {
+		if keep, err = strconv.Atoi(v); err != nil || keep <= 0 {
+			http.Error(w, `{"error":"invalid keep"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		if maxAge, err = time.ParseDuration(v); err != nil || maxAge <= 0 {
+			http.Error(w, `{"error":"invalid older_than"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if keep == 0 && maxAge == 0 {
+		http.Error(w, `{"error":"keep or older_than is required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkScope(w, r, principal, models.ScopeAssetsDelete, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionDelete)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
+
+	n, err := h.assetService.PruneVersions(context.Background(), owner, assetName, keep, maxAge)
+	if errors.Is(err, service.ErrAssetNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to prune versions: name=%s user=%d owner=%d err=%v", assetName, principal.UID, owner, err)
+		http.Error(w, `{"error":"failed to prune versions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Asset versions pruned: name=%s count=%d user=%d owner=%d ip=%s", assetName, n, principal.UID, owner, r.RemoteAddr)
+	auditDetail(r, "deleted", strconv.FormatInt(int64(n), 10))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "deleted": n})
+}
+
 // DeleteAsset обрабатывает запрос DELETE /api/asset/{assetName}.
-// Удаляет файл, принадлежащий текущему пользователю.
+// Удаляет файл со всеми его версиями, принадлежащий текущему пользователю.
+// Запрос DELETE /api/asset/{prefix}/?recursive=true рекурсивно удаляет все файлы «папки».
+// С параметром ?owner=<uid> удаляется чужой файл (или «папка»), если владелец выдал право delete.
 func (h *AssetHandler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
 	// Допустим, данный обработчик вызывается только для DELETE-запросов
 	if r.Method != http.MethodDelete {
@@ -157,42 +405,325 @@ func (h *AssetHandler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверка авторизации
-	userSession, err := h.checkAuth(r)
+	principal, err := h.checkAuth(r)
 	if err != nil {
 		log.Printf("[WARN] Unauthorized delete-asset attempt from ip=%s err=%v", r.RemoteAddr, err)
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Извлечение имени файла из URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
+	// Извлечение имени файла из URL (имя может содержать «/»)
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/asset/")
+	auditTarget(r, assetName)
+	if assetName == "" {
 		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
 		return
 	}
-	assetName := parts[len(parts)-1]
+	if !h.checkScope(w, r, principal, models.ScopeAssetsDelete, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, models.PermissionDelete)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
+
+	// Имя с «/» на конце — это «папка»: её можно удалить только явно, с recursive=true
+	if strings.HasSuffix(assetName, "/") {
+		if r.URL.Query().Get("recursive") != "true" {
+			http.Error(w, `{"error":"deleting a prefix requires recursive=true"}`, http.StatusBadRequest)
+			return
+		}
+		n, err := h.assetService.DeletePrefix(context.Background(), owner, assetName)
+		if errors.Is(err, service.ErrInvalidAssetName) {
+			http.Error(w, `{"error":"invalid prefix"}`, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrAssetNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to delete prefix: prefix=%s user=%d owner=%d ip=%s err=%v", assetName, principal.UID, owner, r.RemoteAddr, err)
+			http.Error(w, `{"error":"failed to delete assets"}`, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[INFO] Prefix deleted: prefix=%s count=%d user=%d owner=%d ip=%s", assetName, n, principal.UID, owner, r.RemoteAddr)
+		auditDetail(r, "deleted", strconv.FormatInt(int64(n), 10))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "deleted": n})
+		return
+	}
 
-	// Удаляем файл из базы данных
-	err = h.assetRepo.DeleteAsset(context.Background(), assetName, userSession.UID)
+	// Удаляем метаданные файла из базы данных и его содержимое из хранилища
+	err = h.assetService.Delete(context.Background(), owner, assetName)
+	if errors.Is(err, service.ErrAssetNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("[ERROR] Failed to delete asset: name=%s user=%d ip=%s err=%v", assetName, userSession.UID, r.RemoteAddr, err)
+		log.Printf("[ERROR] Failed to delete asset: name=%s user=%d owner=%d ip=%s err=%v", assetName, principal.UID, owner, r.RemoteAddr, err)
 		http.Error(w, `{"error":"failed to delete asset"}`, http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Asset deleted: name=%s user=%d ip=%s", assetName, userSession.UID, r.RemoteAddr)
+	log.Printf("[INFO] Asset deleted: name=%s user=%d owner=%d ip=%s", assetName, principal.UID, owner, r.RemoteAddr)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
-// checkAuth проверяет наличие и валидность Bearer-токена в заголовке Authorization.
-// Если токен отсутствует или недействителен, возвращает ошибку.
-func (h *AssetHandler) checkAuth(r *http.Request) (*models.Session, error) {
-	auth := r.Header.Get("Authorization")
-	prefix := "Bearer "
-	if !strings.HasPrefix(auth, prefix) {
-		return nil, http.ErrNoCookie
+// moveAssetRequest описывает JSON-запрос на перенос (переименование) файла или «папки».
+type moveAssetRequest struct {
+	From string `json:"from"` // Текущее имя файла или префикс «папки» с «/» на конце
+	To   string `json:"to"`   // Новое имя файла или новый префикс «папки» с «/» на конце
+}
+
+// MoveAsset обрабатывает запрос POST /api/move-asset.
+// Переименовывает файл вместе с историей версий, а если from и to заканчиваются на «/», —
+// переносит все файлы «папки» под новый префикс. Нужны права на запись и удаление в источнике
+// и на запись в назначении. С параметром ?owner=<uid> переносятся чужие файлы (источник
+// и назначение принадлежат одному владельцу), если он выдал эти права.
+func (h *AssetHandler) MoveAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized move-asset attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req moveAssetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.From == "" || req.To == "" {
+		http.Error(w, `{"error":"from and to are required"}`, http.StatusBadRequest)
+		return
+	}
+	auditTarget(r, req.From)
+	auditDetail(r, "to", req.To)
+	if !h.checkScope(w, r, principal, models.ScopeAssetsWrite, req.From) ||
+		!h.checkScope(w, r, principal, models.ScopeAssetsDelete, req.From) ||
+		!h.checkScope(w, r, principal, models.ScopeAssetsWrite, req.To) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, req.From, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	if _, ok := h.assetOwner(w, r, principal, req.From, models.PermissionDelete); !ok {
+		return
+	}
+	if _, ok := h.assetOwner(w, r, principal, req.To, models.PermissionWrite); !ok {
+		return
+	}
+	auditOwner(r, owner)
+
+	n, err := h.assetService.Move(context.Background(), owner, req.From, req.To)
+	switch {
+	case errors.Is(err, service.ErrInvalidAssetName):
+		http.Error(w, `{"error":"invalid source or destination name"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrAssetNotFound):
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrAssetExists):
+		http.Error(w, `{"error":"destination already exists"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to move asset: from=%s to=%s user=%d owner=%d err=%v", req.From, req.To, principal.UID, owner, err)
+		http.Error(w, `{"error":"failed to move asset"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Asset moved: from=%s to=%s count=%d user=%d owner=%d ip=%s", req.From, req.To, n, principal.UID, owner, r.RemoteAddr)
+	auditDetail(r, "moved", strconv.FormatInt(n, 10))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "moved": n})
+}
+
+// parseAssetListOptions разбирает параметры запроса списка файлов.
+// Имя параметра с некорректным значением возвращается в тексте ошибки.
+func parseAssetListOptions(r *http.Request) (models.AssetListOptions, error) {
+	q := r.URL.Query()
+	opts := models.AssetListOptions{
+		Prefix:      q.Get("prefix"),
+		Delimiter:   q.Get("delimiter"),
+		Glob:        q.Get("glob"),
+		ContentType: q.Get("content_type"),
+		Sort:        q.Get("sort"),
+		Cursor:      q.Get("cursor"),
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errors.New("order")
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return opts, errors.New("limit")
+		}
+		opts.Limit = limit
+	}
+	if v := q.Get("owner"); v != "" {
+		owner, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || owner <= 0 {
+			return opts, errors.New("owner")
+		}
+		opts.Owner = owner
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"created_after", &opts.CreatedAfter}, {"created_before", &opts.CreatedBefore}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opts, errors.New(p.name)
+			}
+			*p.dst = &t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **int64
+	}{{"min_size", &opts.MinSize}, {"max_size", &opts.MaxSize}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return opts, errors.New(p.name)
+			}
+			*p.dst = &n
+		}
+	}
+	return opts, nil
+}
+
+// writeQuotaError отвечает на ошибку квоты при загрузке: 413 для слишком большого файла
+// и 507 при исчерпании квоты на объём или число файлов. Возвращает false для прочих ошибок.
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrFileTooLarge):
+		http.Error(w, `{"error":"file exceeds maximum file size"}`, http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrQuotaExceeded):
+		http.Error(w, `{"error":"storage quota exceeded"}`, http.StatusInsufficientStorage)
+	default:
+		return false
+	}
+	return true
+}
+
+// setAssetHeaders выставляет заголовки с метаданными файла.
+func setAssetHeaders(w http.ResponseWriter, asset *models.Asset) {
+	contentType := asset.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+asset.SHA256+`"`)
+	w.Header().Set("X-Asset-Version", strconv.Itoa(asset.Version))
+	w.Header().Set("X-Asset-Sha256", asset.SHA256)
+	w.Header().Set("X-Asset-Created-At", asset.CreatedAt.UTC().Format(time.RFC3339))
+	for k, v := range asset.Metadata {
+		w.Header().Set(assetMetaHeaderPrefix+k, v)
+	}
+}
+
+// requestContentType проверяет значение заголовка Content-Type загрузки.
+// Пустое значение означает, что тип нужно определить по содержимому.
+func requestContentType(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, _, err := mime.ParseMediaType(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// metadataFromHeaders собирает пользовательские метаданные из заголовков X-Asset-Meta-*.
+// Ключи приводятся к нижнему регистру.
+func metadataFromHeaders(header http.Header) (map[string]string, error) {
+	metadata := map[string]string{}
+	for name, values := range header {
+		if len(name) <= len(assetMetaHeaderPrefix) || !strings.EqualFold(name[:len(assetMetaHeaderPrefix)], assetMetaHeaderPrefix) {
+			continue
+		}
+		metadata[strings.ToLower(name[len(assetMetaHeaderPrefix):])] = strings.Join(values, ",")
+	}
+	return metadata, validateMetadata(metadata)
+}
+
+// validateMetadata проверяет ключи и суммарный размер пользовательских метаданных.
+// Ключи могут состоять из латинских букв, цифр, '-', '_' и '.', чтобы их можно было
+// вернуть в заголовках X-Asset-Meta-*.
+func validateMetadata(metadata map[string]string) error {
+	size := 0
+	for k, v := range metadata {
+		if k == "" || strings.IndexFunc(k, func(c rune) bool {
+			return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.')
+		}) >= 0 {
+			return errInvalidMetadata
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return errInvalidMetadata
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxAssetMetadataSize {
+		return errInvalidMetadata
+	}
+	return nil
+}
+
+// checkAuth проверяет наличие и валидность Bearer-токена (токена сессии или API-ключа)
+// в заголовке Authorization. Если токен отсутствует или недействителен, возвращает ошибку.
+func (h *AssetHandler) checkAuth(r *http.Request) (*models.Principal, error) {
+	return h.auth.Principal(r)
+}
+
+// assetOwner определяет владельца файла (или префикса) name, к которому обращается запрос:
+// по умолчанию это сам вызывающий, а параметр ?owner=<uid> позволяет обратиться к чужому файлу,
+// если владелец выдал вызывающему право permission — лично или одной из его групп.
+// При отказе отвечает 400 или 403 и возвращает false.
+func (h *AssetHandler) assetOwner(w http.ResponseWriter, r *http.Request, principal *models.Principal, name, permission string) (int64, bool) {
+	v := r.URL.Query().Get("owner")
+	if v == "" {
+		return principal.UID, true
+	}
+	owner, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || owner <= 0 {
+		http.Error(w, `{"error":"invalid owner"}`, http.StatusBadRequest)
+		return 0, false
+	}
+
+	allowed, err := h.aclService.Allowed(context.Background(), principal.UID, owner, name, permission)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check asset acl: name=%s owner=%d user=%d err=%v", name, owner, principal.UID, err)
+		http.Error(w, `{"error":"failed to check access"}`, http.StatusInternalServerError)
+		return 0, false
+	}
+	if !allowed {
+		log.Printf("[WARN] Forbidden by acl: permission=%s name=%s owner=%d user=%d ip=%s", permission, name, owner, principal.UID, r.RemoteAddr)
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return 0, false
+	}
+	return owner, true
+}
+
+// checkScope проверяет, что вызывающему разрешена область действия scope для файла name
+// (пустое name — проверка только области действия). При отказе отвечает 403 и возвращает false.
+func (h *AssetHandler) checkScope(w http.ResponseWriter, r *http.Request, principal *models.Principal, scope, name string) bool {
+	allowed := principal.HasScope(scope)
+	if name != "" {
+		allowed = principal.CanAccess(scope, name)
+	}
+	if !allowed {
+		log.Printf("[WARN] Forbidden: scope=%s name=%s user=%d ip=%s", scope, name, principal.UID, r.RemoteAddr)
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return false
 	}
-	token := strings.TrimPrefix(auth, prefix)
-	return h.authService.ValidateToken(context.Background(), token)
+	return true
 }
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// auditContextKey — ключ контекста запроса, под которым лежит заполняемая запись журнала аудита.
+type auditContextKey struct{}
+
+// Auditor записывает в журнал аудита запросы к отмеченным маршрутам. Запись создаётся до вызова
+// обработчика и кладётся в контекст запроса: Authenticator дописывает в неё вызывающего,
+// обработчики — объект (auditTarget, auditOwner) и подробности (auditDetail). Результат
+// определяется по HTTP-статусу ответа.
+type Auditor struct {
+	auditService *service.AuditService // Журнал аудита
+}
+
+// NewAuditor создает новый экземпляр Auditor.
+func NewAuditor(auditService *service.AuditService) *Auditor {
+	return &Auditor{auditService: auditService}
+}
+
+// Audit оборачивает обработчик next: после ответа записывает в журнал действие action с адресом
+// клиента, статусом ответа и тем, что обработчик дописал в запись. Объектом действия по умолчанию
+// считается путь запроса; обработчики файлов заменяют его именем файла. Ошибка записи в журнал
+// не влияет на ответ и только пишется в лог.
+func (a *Auditor) Audit(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := &auditEntry{event: models.AuditEvent{Action: action, Method: r.Method, Target: r.URL.Path, IP: clientIP(r)}}
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, e)))
+		if e.skip {
+			return
+		}
+
+		e.event.Status = rec.status
+		if e.event.Status == 0 {
+			e.event.Status = http.StatusOK
+		}
+		e.event.Result = auditResult(e.event.Status)
+		if err := a.auditService.Record(context.Background(), &e.event); err != nil {
+			log.Printf("[ERROR] Failed to record audit event: action=%s method=%s target=%s ip=%s err=%v",
+				action, r.Method, e.event.Target, e.event.IP, err)
+		}
+	}
+}
+
+// auditEntry — запись журнала, заполняемая во время обработки запроса.
+type auditEntry struct {
+	event models.AuditEvent
+	skip  bool // Запрос не является действием, которое нужно записывать
+}
+
+// auditFrom возвращает запись журнала запроса или nil, если маршрут не записывается в журнал.
+func auditFrom(r *http.Request) *auditEntry {
+	e, _ := r.Context().Value(auditContextKey{}).(*auditEntry)
+	return e
+}
+
+// auditPrincipal записывает в журнал вызывающего и способ, которым он аутентифицирован.
+func auditPrincipal(r *http.Request, p *models.Principal) {
+	credential := "session"
+	switch {
+	case p.APIKey != nil:
+		credential = "api_key:" + p.APIKey.Prefix
+	case p.ClientCert != nil:
+		credential = "client_cert:" + strconv.FormatInt(p.ClientCert.ID, 10)
+	}
+	auditActor(r, p.UID)
+	auditDetail(r, "credential", credential)
+}
+
+// auditActor записывает в журнал пользователя, выполняющего действие.
+func auditActor(r *http.Request, uid int64) {
+	if e := auditFrom(r); e != nil {
+		e.event.UID = &uid
+	}
+}
+
+// auditTarget записывает в журнал объект действия вместо пути запроса (например, имя файла).
+func auditTarget(r *http.Request, target string) {
+	if e := auditFrom(r); e != nil {
+		e.event.Target = target
+	}
+}
+
+// auditOwner записывает в журнал владельца файла, с которым выполняется действие.
+func auditOwner(r *http.Request, owner int64) {
+	if e := auditFrom(r); e != nil {
+		e.event.Owner = &owner
+	}
+}
+
+// auditDetail добавляет в запись журнала подробность key=value.
+func auditDetail(r *http.Request, key, value string) {
+	if e := auditFrom(r); e != nil {
+		if e.event.Details == nil {
+			e.event.Details = map[string]string{}
+		}
+		e.event.Details[key] = value
+	}
+}
+
+// auditSkip отменяет запись запроса в журнал (например, промежуточная часть возобновляемой загрузки).
+func auditSkip(r *http.Request) {
+	if e := auditFrom(r); e != nil {
+		e.skip = true
+	}
+}
+
+// auditResult определяет результат действия по статусу ответа.
+func auditResult(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return models.AuditSuccess
+	case status == http.StatusUnauthorized, status == http.StatusForbidden, status == http.StatusTooManyRequests:
+		return models.AuditDenied
+	}
+	return models.AuditFailure
+}
+
+// clientIP возвращает IP-адрес клиента без порта.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// statusRecorder запоминает HTTP-статус ответа для журнала аудита.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController обращаться к исходному ResponseWriter.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// AuditHandler реализует API журнала аудита /api/admin/audit. Доступ ограничивается
+// middleware Authenticator.RequireRole.
+type AuditHandler struct {
+	auditService *service.AuditService // Журнал аудита
+}
+
+// NewAuditHandler создает новый экземпляр AuditHandler.
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListEvents обрабатывает GET /api/admin/audit: страница журнала от новых записей к старым.
+// Параметры запроса: uid, action, since и until (RFC 3339), limit и cursor (next_cursor
+// из предыдущего ответа).
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid query parameter: `+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.auditService.List(context.Background(), f)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list audit events: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to list audit events"}`, http.StatusInternalServerError)
+		return
+	}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// VerifyChain обрабатывает GET /api/admin/audit/verify: проверяет цепочку хешей журнала.
+func (h *AuditHandler) VerifyChain(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	result, err := h.auditService.Verify(context.Background())
+	if err != nil {
+		log.Printf("[ERROR] Failed to verify audit log: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to verify audit log"}`, http.StatusInternalServerError)
+		return
+	}
+	if !result.Valid {
+		log.Printf("[WARN] Audit log hash chain broken: id=%d checked=%d admin=%d", result.BrokenAt, result.Checked, admin.UID)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseAuditFilter разбирает параметры выборки журнала аудита. Ошибка содержит имя
+// неверного параметра.
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	f := models.AuditFilter{Action: q.Get("action")}
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"uid", &f.UID}, {"cursor", &f.BeforeID}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return f, errors.New(p.name)
+			}
+			*p.dst = n
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return f, errors.New("limit")
+		}
+		f.Limit = limit
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, errors.New(p.name)
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
@@ -3,40 +3,89 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"go-asset-service/internal/repository"
 	"go-asset-service/internal/service"
 )
 
 // AuthHandler отвечает за обработку запросов к эндпоинту аутентификации (/api/auth)
 type AuthHandler struct {
 	authService *service.AuthService // Сервис для авторизации пользователей
+	auth        *Authenticator       // Проверка токена сессии (для logout)
 }
 
-// NewAuthHandler создаёт новый экземпляр AuthHandler, инициализируя сервис авторизации.
-func NewAuthHandler(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository) *AuthHandler {
+// NewAuthHandler создаёт новый экземпляр AuthHandler.
+func NewAuthHandler(authService *service.AuthService, auth *Authenticator) *AuthHandler {
 	return &AuthHandler{
-		authService: service.NewAuthService(userRepo, sessionRepo),
+		authService: authService,
+		auth:        auth,
 	}
 }
 
+// maxDeviceLabelLen — максимальная длина метки устройства, сохраняемой в сессии.
+const maxDeviceLabelLen = 200
+
 // loginRequest описывает структуру JSON-запроса для аутентификации.
 type loginRequest struct {
 	Login    string `json:"login"`    // Логин пользователя
 	Password string `json:"password"` // Пароль пользователя
+	Device   string `json:"device"`   // Необязательная метка устройства (например, "laptop" или "ci-runner")
+	MFACode  string `json:"mfa_code"` // Код второго фактора, если он подключён (можно передать вторым шагом)
 }
 
-// loginResponse описывает структуру JSON-ответа при успешной аутентификации.
+// loginResponse описывает структуру JSON-ответа при успешной аутентификации и обмене refresh-токена.
 type loginResponse struct {
-	Token string `json:"token"` // Авторизационный токен (session-id)
+	Token            string    `json:"token"`              // Авторизационный токен (session-id)
+	RefreshToken     string    `json:"refresh_token"`      // Одноразовый токен для получения новой пары токенов
+	ExpiresAt        time.Time `json:"expires_at"`         // Срок действия токена без активности (продлевается при использовании)
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"` // Срок действия refresh-токена
+
+	// Роль требует второй фактор, а он не подключён: до подключения доступно только чтение
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+}
+
+// mfaChallengeResponse описывает JSON-ответ на вход по паролю, когда нужен код второго фактора.
+type mfaChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"` // Всегда true
+	MFAToken    string    `json:"mfa_token"`    // Токен для POST /api/auth/mfa
+	ExpiresAt   time.Time `json:"expires_at"`   // Срок действия токена
+}
+
+// loginMFARequest описывает JSON-запрос второго шага входа.
+type loginMFARequest struct {
+	MFAToken string `json:"mfa_token"` // Токен из ответа POST /api/auth
+	Code     string `json:"code"`      // Код TOTP или код восстановления
+}
+
+// refreshRequest описывает JSON-запрос обмена refresh-токена.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newLoginResponse формирует ответ из выданных сервисом токенов.
+func newLoginResponse(t *service.Tokens) loginResponse {
+	return loginResponse{
+		Token:            t.AccessToken,
+		RefreshToken:     t.RefreshToken,
+		ExpiresAt:        t.AccessExpiresAt,
+		RefreshExpiresAt: t.RefreshExpiresAt,
+
+		MFAEnrollmentRequired: t.MFAEnrollmentRequired,
+	}
 }
 
 // Login обрабатывает POST /api/auth.
 // Он читает JSON-запрос с логином и паролем, получает IP-адрес клиента,
 // вызывает сервис авторизации и возвращает токен, либо ошибку.
+// После неудачных попыток по логину или с IP-адреса отвечает 429 с заголовком Retry-After.
+// Если у пользователя подключён второй фактор, а код не передан в mfa_code, вместо токенов
+// возвращает mfa_token для второго шага POST /api/auth/mfa.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Логирование входящего запроса для отладки
 	log.Printf("[INFO] /api/auth called from %s", r.RemoteAddr)
@@ -52,23 +101,157 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Получаем IP-адрес клиента (используется для записи в сессию)
 	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	auditDetail(r, "method", "password")
+	auditDetail(r, "login", req.Login)
 
-	// Вызываем сервис авторизации: передаём логин, пароль и IP-адрес
-	token, err := h.authService.Login(context.Background(), req.Login, req.Password, ip)
-	if err != nil {
-		// Логирование ошибки авторизации (например, неверный логин/пароль)
-		log.Printf("[WARN] Failed login for user=%s ip=%s err=%v", req.Login, ip, err)
-		http.Error(w, `{"error":"invalid login/password"}`, http.StatusUnauthorized)
+	// Вызываем сервис авторизации: передаём логин, пароль, код второго фактора, IP-адрес и метку устройства
+	tokens, pending, err := h.authService.Login(context.Background(), req.Login, req.Password, req.MFACode, ip, deviceLabel(r, req.Device))
+	if !h.handleLoginError(w, err, req.Login, ip) {
+		return
+	}
+	if pending != nil {
+		log.Printf("[INFO] Password accepted, second factor required: login=%s ip=%s", req.Login, ip)
+		auditDetail(r, "mfa_required", "true")
+		writeJSON(w, http.StatusOK, mfaChallengeResponse{MFARequired: true, MFAToken: pending.Token, ExpiresAt: pending.ExpiresAt})
 		return
 	}
 
 	// Логирование успешной авторизации
-	log.Printf("[INFO] User logged in: login=%s ip=%s token=%s", req.Login, ip, token)
+	log.Printf("[INFO] User logged in: login=%s user=%d ip=%s", req.Login, tokens.UID, ip)
+	auditActor(r, tokens.UID)
 
-	// Формирование ответа с токеном в формате JSON
-	resp := loginResponse{Token: token}
+	// Формирование ответа с токенами в формате JSON
+	resp := newLoginResponse(tokens)
 	jsonData, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonData)
 }
+
+// LoginMFA обрабатывает POST /api/auth/mfa — второй шаг входа: код TOTP или код
+// восстановления для mfa_token, выданного POST /api/auth. Возвращает токены так же, как Login.
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req loginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	auditDetail(r, "method", "mfa")
+	tokens, err := h.authService.LoginMFA(context.Background(), req.MFAToken, req.Code, ip)
+	if errors.Is(err, service.ErrInvalidMFAToken) {
+		log.Printf("[WARN] Invalid MFA token from ip=%s", ip)
+		http.Error(w, `{"error":"invalid or expired mfa token"}`, http.StatusUnauthorized)
+		return
+	}
+	if !h.handleLoginError(w, err, "", ip) {
+		return
+	}
+
+	log.Printf("[INFO] User logged in with second factor: user=%d ip=%s", tokens.UID, ip)
+	auditActor(r, tokens.UID)
+	writeJSON(w, http.StatusOK, newLoginResponse(tokens))
+}
+
+// handleLoginError отвечает ошибкой входа с подходящим статусом. Возвращает true, если ошибки нет.
+func (h *AuthHandler) handleLoginError(w http.ResponseWriter, err error, login, ip string) bool {
+	var attemptsErr *service.AttemptsError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &attemptsErr):
+		// Округляем вверх: клиент, подождавший Retry-After секунд, не должен получить отказ снова
+		retryAfter := int64((attemptsErr.RetryAfter + time.Second - 1) / time.Second)
+		log.Printf("[WARN] Login attempt throttled: user=%s ip=%s retry_after=%ds", login, ip, retryAfter)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		http.Error(w, `{"error":"too many login attempts"}`, http.StatusTooManyRequests)
+	case errors.Is(err, service.ErrAccountDisabled):
+		log.Printf("[WARN] Login attempt to disabled account: user=%s ip=%s", login, ip)
+		http.Error(w, `{"error":"account disabled"}`, http.StatusForbidden)
+	case errors.Is(err, service.ErrPasswordLoginDisabled):
+		http.Error(w, `{"error":"password login disabled, use /api/auth/oidc/login"}`, http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidMFACode):
+		log.Printf("[WARN] Invalid second factor code: user=%s ip=%s", login, ip)
+		http.Error(w, `{"error":"invalid mfa code"}`, http.StatusUnauthorized)
+	default:
+		// Логирование ошибки авторизации (например, неверный логин/пароль)
+		log.Printf("[WARN] Failed login for user=%s ip=%s err=%v", login, ip, err)
+		http.Error(w, `{"error":"invalid login/password"}`, http.StatusUnauthorized)
+	}
+	return false
+}
+
+// deviceLabel возвращает метку устройства для новой сессии: указанную клиентом, либо User-Agent,
+// обрезанную до maxDeviceLabelLen байт.
+func deviceLabel(r *http.Request, requested string) string {
+	device := requested
+	if device == "" {
+		device = r.UserAgent()
+	}
+	if len(device) > maxDeviceLabelLen {
+		device = strings.ToValidUTF8(device[:maxDeviceLabelLen], "")
+	}
+	return device
+}
+
+// Refresh обрабатывает POST /api/auth/refresh.
+// Обменивает refresh-токен на новую пару токенов; старые токены сессии перестают действовать.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(context.Background(), req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenReused) {
+		log.Printf("[WARN] Refresh token reuse detected, session revoked: ip=%s", r.RemoteAddr)
+		http.Error(w, `{"error":"invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrSessionExpired) {
+		http.Error(w, `{"error":"invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to refresh session: ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to refresh session"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newLoginResponse(tokens))
+}
+
+// Logout обрабатывает POST /api/auth/logout: завершает сессию, с которой выполнен запрос.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.Logout(context.Background(), userSession); err != nil {
+		log.Printf("[ERROR] Failed to logout: user=%d err=%v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to logout"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] User logged out: user=%d ip=%s", userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+var (
+	// errNoCredentials возвращается, если в запросе нет ни заголовка Authorization: Bearer,
+	// ни проверенного клиентского сертификата.
+	errNoCredentials = errors.New("missing bearer token")
+	// errSessionRequired возвращается, если эндпоинт доступен только с токеном сессии, а не с API-ключом.
+	errSessionRequired = errors.New("session token required")
+	// errRoleRequired возвращается, если у пользователя нет роли, необходимой для эндпоинта.
+	errRoleRequired = errors.New("role required")
+	// errMFARequired возвращается, если роль требует второй фактор, а запрос выполнен без него.
+	errMFARequired = errors.New("second factor required")
+)
+
+// Authenticator определяет, от чьего имени выполняется запрос: по токену сессии
+// или по API-ключу из заголовка Authorization: Bearer <token>, а без заголовка — по клиентскому
+// сертификату mTLS, проверенному сервером. Запросы заблокированных пользователей отклоняются,
+// а роль пользователя записывается в Principal.
+// Токен доступа в формате JWT проверяется без обращения к БД: роль и состояние второго фактора
+// берутся из его утверждений, а блокировка пользователя действует через отзыв его сессий.
+type Authenticator struct {
+	authService   *service.AuthService       // Проверка токенов сессий
+	jwtService    *service.JWTService        // Проверка токенов доступа в формате JWT
+	apiKeyService *service.APIKeyService     // Проверка API-ключей
+	certService   *service.ClientCertService // Сопоставление клиентских сертификатов пользователям
+	userService   *service.UserService       // Роль и блокировка учётной записи
+	mfaService    *service.MFAService        // Обязательность второго фактора для роли
+}
+
+// NewAuthenticator создает новый экземпляр Authenticator.
+func NewAuthenticator(auth *service.AuthService, jwt *service.JWTService, apiKeys *service.APIKeyService, certs *service.ClientCertService, users *service.UserService, mfa *service.MFAService) *Authenticator {
+	return &Authenticator{
+		authService:   auth,
+		jwtService:    jwt,
+		apiKeyService: apiKeys,
+		certService:   certs,
+		userService:   users,
+		mfaService:    mfa,
+	}
+}
+
+// Principal проверяет токен сессии, API-ключ или клиентский сертификат и возвращает описание
+// вызывающего. Если удостоверения нет или оно недействительно, возвращает ошибку.
+// Токен в заголовке имеет приоритет над сертификатом.
+// Если роль пользователя требует второй фактор, а запрос выполнен без него, Principal
+// отмечается как MFAPending и получает права только на чтение.
+// Вызывающий записывается в журнал аудита, если запрос в него записывается.
+func (a *Authenticator) Principal(r *http.Request) (*models.Principal, error) {
+	principal, err := a.principal(r)
+	if err != nil {
+		return nil, err
+	}
+	auditPrincipal(r, principal)
+	return principal, nil
+}
+
+// principal определяет вызывающего для Principal.
+func (a *Authenticator) principal(r *http.Request) (*models.Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		if cert := clientCertificate(r); cert != nil {
+			return a.certPrincipal(cert)
+		}
+		return nil, errNoCredentials
+	}
+
+	if service.IsAPIKey(token) {
+		key, err := a.apiKeyService.Authenticate(context.Background(), token)
+		if err != nil {
+			return nil, err
+		}
+		user, err := a.userService.Active(context.Background(), key.UID)
+		if err != nil {
+			return nil, err
+		}
+		pending, err := a.mfaService.Pending(context.Background(), user, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &models.Principal{UID: key.UID, Role: user.Role, APIKey: key, MFAPending: pending}, nil
+	}
+
+	if service.IsJWT(token) {
+		claims, err := a.jwtService.Verify(context.Background(), token)
+		if err != nil {
+			return nil, err
+		}
+		uid, _ := claims.UID()
+		sess := &models.Session{PublicID: claims.SessionID, UID: uid}
+		return &models.Principal{UID: uid, Role: claims.Role, Session: sess, MFAPending: claims.MFAPending}, nil
+	}
+
+	sess, err := a.authService.ValidateToken(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.userService.Active(context.Background(), sess.UID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := a.mfaService.Pending(context.Background(), user, sess)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Principal{UID: sess.UID, Role: user.Role, Session: sess, MFAPending: pending}, nil
+}
+
+// Session проверяет токен сессии. Используется эндпоинтами управления учётной записью
+// (сессии, API-ключи), которые недоступны по API-ключу. Для токена доступа в формате JWT
+// сессия загружается из БД по её публичному идентификатору.
+func (a *Authenticator) Session(r *http.Request) (*models.Session, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		if clientCertificate(r) != nil {
+			return nil, errSessionRequired
+		}
+		return nil, errNoCredentials
+	}
+	if service.IsAPIKey(token) {
+		return nil, errSessionRequired
+	}
+	var sess *models.Session
+	var err error
+	if service.IsJWT(token) {
+		var claims *service.AccessClaims
+		if claims, err = a.jwtService.Verify(context.Background(), token); err != nil {
+			return nil, err
+		}
+		sess, err = a.authService.FindSession(context.Background(), claims.SessionID)
+	} else {
+		sess, err = a.authService.ValidateToken(context.Background(), token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.userService.Active(context.Background(), sess.UID); err != nil {
+		return nil, err
+	}
+	auditActor(r, sess.UID)
+	return sess, nil
+}
+
+// RequireRole — middleware для эндпоинтов, доступных только с токеном сессии пользователя
+// с ролью role (например, API администрирования). Без действующей сессии отвечает 401,
+// без нужной роли или без второго фактора, обязательного для роли, — 403; иначе вызывает next
+// с описанием вызывающего.
+func (a *Authenticator) RequireRole(role string, next func(http.ResponseWriter, *http.Request, *models.Principal)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Principal(r)
+		if err == nil && principal.Session == nil {
+			err = errSessionRequired
+		}
+		if err != nil {
+			log.Printf("[WARN] Unauthorized %s %s from ip=%s err=%v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if principal.Role != role {
+			log.Printf("[WARN] Forbidden %s %s: user=%d role=%s ip=%s err=%v", r.Method, r.URL.Path, principal.UID, principal.Role, r.RemoteAddr, errRoleRequired)
+			http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+		if principal.MFAPending {
+			log.Printf("[WARN] Forbidden %s %s: user=%d role=%s ip=%s err=%v", r.Method, r.URL.Path, principal.UID, principal.Role, r.RemoteAddr, errMFARequired)
+			http.Error(w, `{"error":"second factor required"}`, http.StatusForbidden)
+			return
+		}
+		next(w, r, principal)
+	}
+}
+
+// certPrincipal возвращает описание вызывающего по клиентскому сертификату: права определяются
+// сопоставлением сертификата так же, как у API-ключа.
+func (a *Authenticator) certPrincipal(cert *x509.Certificate) (*models.Principal, error) {
+	mapping, err := a.certService.Authenticate(context.Background(), cert)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.userService.Active(context.Background(), mapping.UID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := a.mfaService.Pending(context.Background(), user, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Principal{UID: mapping.UID, Role: user.Role, ClientCert: mapping, MFAPending: pending}, nil
+}
+
+// clientCertificate возвращает клиентский сертификат mTLS, если сервер его запросил и проверил
+// по CA клиентов (TLS_CLIENT_AUTH); иначе nil.
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// bearerToken извлекает токен из заголовка Authorization: Bearer <token>.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	prefix := "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(auth, prefix), true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// ClientCertHandler реализует API администрирования сопоставлений клиентских сертификатов mTLS
+// пользователям /api/admin/client-certs. Доступ ограничивается middleware Authenticator.RequireRole.
+type ClientCertHandler struct {
+	certService *service.ClientCertService // Сервис сопоставлений сертификатов
+}
+
+// NewClientCertHandler создает новый экземпляр ClientCertHandler.
+func NewClientCertHandler(certService *service.ClientCertService) *ClientCertHandler {
+	return &ClientCertHandler{certService: certService}
+}
+
+// createClientCertRequest описывает JSON-запрос на сопоставление сертификата пользователю.
+type createClientCertRequest struct {
+	Match        string   `json:"match"`         // Поле сертификата: subject, dns, email или uri
+	Value        string   `json:"value"`         // Значение поля
+	UID          int64    `json:"uid"`           // Пользователь
+	Scopes       []string `json:"scopes"`        // Разрешённые области действия
+	NamePrefixes []string `json:"name_prefixes"` // Разрешённые префиксы имён файлов
+}
+
+// ListClientCerts обрабатывает GET /api/admin/client-certs.
+func (h *ClientCertHandler) ListClientCerts(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	mappings, err := h.certService.List(context.Background())
+	if err != nil {
+		log.Printf("[ERROR] Failed to list client certificate mappings: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to list client certificate mappings"}`, http.StatusInternalServerError)
+		return
+	}
+	if mappings == nil {
+		mappings = []models.ClientCertMapping{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"client_certs": mappings})
+}
+
+// CreateClientCert обрабатывает POST /api/admin/client-certs: запросы с сертификатом,
+// у которого поле match равно value, будут выполняться от имени пользователя uid.
+func (h *ClientCertHandler) CreateClientCert(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	var req createClientCertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.certService.Create(context.Background(), req.Match, req.Value, req.UID, req.Scopes, req.NamePrefixes)
+	switch {
+	case errors.Is(err, service.ErrInvalidClientCertMapping):
+		http.Error(w, `{"error":"match must be one of subject, dns, email, uri; value and scopes are required"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrClientCertMappingExists):
+		http.Error(w, `{"error":"certificate field already mapped"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to create client certificate mapping: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to create client certificate mapping"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Client certificate mapped: id=%d %s=%q user=%d admin=%d ip=%s", m.ID, m.Match, m.Value, m.UID, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// DeleteClientCert обрабатывает DELETE /api/admin/client-certs/{id}.
+func (h *ClientCertHandler) DeleteClientCert(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/client-certs/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	err = h.certService.Delete(context.Background(), id)
+	if errors.Is(err, service.ErrClientCertMappingNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete client certificate mapping: id=%d admin=%d err=%v", id, admin.UID, err)
+		http.Error(w, `{"error":"failed to delete client certificate mapping"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Client certificate mapping deleted: id=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
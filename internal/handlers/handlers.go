@@ -1,44 +1,402 @@
 package handlers
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"go-asset-service/internal/repository"
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
 	"go-asset-service/internal/service"
 )
 
 // RegisterRoutes регистрирует все HTTP-маршруты API.
-func RegisterRoutes(mux *http.ServeMux, pool *pgxpool.Pool) {
-	// Создаем репозитории для работы с пользователями, сессиями и файлами.
-	userRepo := repository.NewUserRepository(pool)
-	sessionRepo := repository.NewSessionRepository(pool)
-	assetRepo := repository.NewAssetRepository(pool)
+// srv — сервисы приложения (общие с фоновыми задачами обслуживания).
+func RegisterRoutes(mux *http.ServeMux, srv *service.Services, cfg *config.Config) {
+	jwtSrv := srv.JWT
+	mfaSrv := srv.MFA
+	authSrv := srv.Auth
+	assetSrv := srv.Asset
+	uploadSrv := srv.Upload
+	apiKeySrv := srv.APIKey
+	aclSrv := srv.ACL
+	presignSrv := srv.Presign
+	shareSrv := srv.Share
+	userSrv := srv.User
+	accountSrv := srv.Account
+	quotaSrv := srv.Quota
+	oidcSrv := srv.OIDC
+	clientCertSrv := srv.ClientCert
+	auditSrv := srv.Audit
 
-	// Инициализируем сервис авторизации.
-	authSrv := service.NewAuthService(userRepo, sessionRepo)
+	// Аутентификация запросов по токену сессии (случайному или JWT), API-ключу или клиентскому
+	// сертификату mTLS с учётом роли, блокировки пользователя и обязательности второго фактора.
+	authn := NewAuthenticator(authSrv, jwtSrv, apiKeySrv, clientCertSrv, userSrv, mfaSrv)
 
 	// Создаем хендлеры для авторизации и работы с файлами.
-	authHandler := NewAuthHandler(userRepo, sessionRepo)
-	assetHandler := NewAssetHandler(assetRepo, authSrv)
+	authHandler := NewAuthHandler(authSrv, authn)
+	assetHandler := NewAssetHandler(assetSrv, aclSrv, presignSrv, authn)
+	uploadHandler := NewUploadHandler(uploadSrv, authn)
+	sessionHandler := NewSessionHandler(authSrv, authn)
+	apiKeyHandler := NewAPIKeyHandler(apiKeySrv, authn)
+	aclHandler := NewACLHandler(aclSrv, authn)
+	shareHandler := NewShareHandler(shareSrv, assetSrv, authn)
+	adminHandler := NewAdminHandler(userSrv, assetSrv)
+	accountHandler := NewAccountHandler(accountSrv, authn)
+	quotaHandler := NewQuotaHandler(quotaSrv, authn)
+	mfaHandler := NewMFAHandler(mfaSrv, userSrv, authn)
+	oidcHandler := NewOIDCHandler(oidcSrv)
+	jwksHandler := NewJWKSHandler(jwtSrv)
+	clientCertHandler := NewClientCertHandler(clientCertSrv)
+	auditHandler := NewAuditHandler(auditSrv)
+
+	// Журнал аудита: вход и выход, смена и сброс пароля, отключение второго фактора, выпуск
+	// API-ключей и ссылок, операции с файлами и их версиями и действия администраторов
+	// записываются обёрткой Auditor.Audit с результатом по статусу ответа.
+	// Передача содержимого файлов не ограничивается таймаутом записи сервера: соединение
+	// разрывается только после TRANSFER_IDLE_TIMEOUT без переданных данных.
+	auditor := NewAuditor(auditSrv)
+	idle := cfg.TransferIdleTimeout
+	uploadAsset := auditor.Audit(models.AuditAssetUpload, withTransferDeadlines(idle, assetHandler.UploadAsset))
+	downloadAsset := auditor.Audit(models.AuditAssetDownload, withTransferDeadlines(idle, assetHandler.GetAsset))
+	deleteAsset := auditor.Audit(models.AuditAssetDelete, assetHandler.DeleteAsset)
+	downloadPresigned := auditor.Audit(models.AuditAssetDownload, withTransferDeadlines(idle, assetHandler.ServePresigned))
+	uploadPresigned := auditor.Audit(models.AuditAssetUpload, withTransferDeadlines(idle, assetHandler.ServePresigned))
+	openShare := auditor.Audit(models.AuditAssetDownload, withTransferDeadlines(idle, shareHandler.OpenShare))
+	patchUpload := auditor.Audit(models.AuditAssetUpload, withTransferDeadlines(idle, uploadHandler.PatchUpload))
+	pruneVersions := auditor.Audit(models.AuditAssetPrune, assetHandler.PruneVersions)
+	createAPIKey := auditor.Audit(models.AuditAPIKeyCreate, apiKeyHandler.CreateAPIKey)
+	createShare := auditor.Audit(models.AuditShareCreate, shareHandler.CreateShare)
+	revokeShare := auditor.Audit(models.AuditShareRevoke, shareHandler.RevokeShare)
 
 	// Эндпоинт авторизации: POST /api/auth.
-	mux.HandleFunc("/api/auth", authHandler.Login)
+	mux.HandleFunc("/api/auth", auditor.Audit(models.AuditLogin, authHandler.Login))
+
+	// Второй шаг входа с подключённым вторым фактором: POST /api/auth/mfa с mfa_token и кодом.
+	mux.HandleFunc("/api/auth/mfa", auditor.Audit(models.AuditLogin, authHandler.LoginMFA))
+
+	// Вход через провайдера OpenID Connect: перенаправление на страницу входа
+	// GET /api/auth/oidc/login и обратный вызов провайдера GET /api/auth/oidc/callback.
+	mux.HandleFunc("/api/auth/oidc/login", oidcHandler.Login)
+	mux.HandleFunc("/api/auth/oidc/callback", auditor.Audit(models.AuditLogin, oidcHandler.Callback))
+
+	// Проверка токенов доступа в формате JWT без обращения к сервису: открытые ключи подписи
+	// GET /.well-known/jwks.json и список отозванных сессий GET /.well-known/revoked-sessions.json.
+	mux.HandleFunc("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	mux.HandleFunc("/.well-known/revoked-sessions.json", jwksHandler.GetRevoked)
+
+	// Обмен refresh-токена на новую пару токенов и завершение сессии.
+	mux.HandleFunc("/api/auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("/api/auth/logout", auditor.Audit(models.AuditLogout, authHandler.Logout))
+
+	// Самостоятельная регистрация POST /api/register (если включена REGISTRATION_ENABLED),
+	// смена пароля POST /api/auth/password и сброс забытого пароля: выдача токена
+	// POST /api/auth/password-reset и новый пароль по токену POST /api/auth/password-reset/confirm.
+	mux.HandleFunc("/api/register", accountHandler.Register)
+	mux.HandleFunc("/api/auth/password", auditor.Audit(models.AuditPasswordChange, accountHandler.ChangePassword))
+	mux.HandleFunc("/api/auth/password-reset", auditor.Audit(models.AuditPasswordResetRequest, accountHandler.RequestPasswordReset))
+	mux.HandleFunc("/api/auth/password-reset/confirm", auditor.Audit(models.AuditPasswordReset, accountHandler.ConfirmPasswordReset))
+
+	// Второй фактор (TOTP): состояние GET /api/mfa, начало подключения POST /api/mfa/enroll,
+	// подтверждение первым кодом POST /api/mfa/confirm, новые коды восстановления
+	// POST /api/mfa/recovery-codes и отключение POST /api/mfa/disable.
+	mux.HandleFunc("/api/mfa", mfaHandler.GetStatus)
+	mux.HandleFunc("/api/mfa/enroll", mfaHandler.Enroll)
+	mux.HandleFunc("/api/mfa/confirm", mfaHandler.Confirm)
+	mux.HandleFunc("/api/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+	mux.HandleFunc("/api/mfa/disable", auditor.Audit(models.AuditMFADisable, mfaHandler.Disable))
+
+	// Сессии пользователя: список GET /api/sessions, отзыв одной сессии DELETE /api/sessions/{id}
+	// и отзыв всех сессий, кроме текущей, POST /api/sessions/revoke-others.
+	mux.HandleFunc("/api/sessions", sessionHandler.ListSessions)
+	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/sessions/revoke-others":
+			sessionHandler.RevokeOtherSessions(w, r)
+		case r.Method == http.MethodDelete:
+			sessionHandler.RevokeSession(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// API-ключи машинных клиентов: выпуск POST /api/keys, список GET /api/keys,
+	// отзыв DELETE /api/keys/{id}.
+	mux.HandleFunc("/api/keys", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			apiKeyHandler.ListAPIKeys(w, r)
+		case http.MethodPost:
+			createAPIKey(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/keys/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		apiKeyHandler.RevokeAPIKey(w, r)
+	})
+
+	// Совместный доступ к файлам: выдача POST /api/grants, список GET /api/grants,
+	// отзыв DELETE /api/grants/{id}.
+	mux.HandleFunc("/api/grants", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			aclHandler.ListGrants(w, r)
+		case http.MethodPost:
+			aclHandler.CreateGrant(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/grants/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		aclHandler.RevokeGrant(w, r)
+	})
+
+	// Группы пользователей: создание POST /api/groups, список GET /api/groups, удаление
+	// DELETE /api/groups/{name}, добавление и исключение участников
+	// PUT и DELETE /api/groups/{name}/members/{login}.
+	mux.HandleFunc("/api/groups", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			aclHandler.ListGroups(w, r)
+		case http.MethodPost:
+			aclHandler.CreateGroup(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/groups/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/members/") && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+			aclHandler.UpdateMember(w, r)
+		case r.Method == http.MethodDelete:
+			aclHandler.DeleteGroup(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// API администрирования (только сессия пользователя с ролью admin): список GET и создание POST
+	// /api/admin/users; просмотр GET, изменение роли и блокировка PATCH и удаление DELETE
+	// /api/admin/users/{id}; сброс пароля POST /api/admin/users/{id}/password, принудительный
+	// выход DELETE /api/admin/users/{id}/sessions, снятие блокировки после неудачных попыток входа
+	// POST /api/admin/users/{id}/unlock, отключение второго фактора DELETE /api/admin/users/{id}/mfa,
+	// список файлов GET /api/admin/users/{id}/assets,
+	// потребление GET /api/admin/users/{id}/usage и персональная квота PUT и DELETE
+	// /api/admin/users/{id}/quota.
+	mux.HandleFunc("/api/admin/users", auditor.Audit(models.AuditAdminUser, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListUsers(w, r, admin)
+		case http.MethodPost:
+			adminHandler.CreateUser(w, r, admin)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.HandleFunc("/api/admin/users/", auditor.Audit(models.AuditAdminUser, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/password") && r.Method == http.MethodPost:
+			adminHandler.ResetPassword(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/sessions") && r.Method == http.MethodDelete:
+			adminHandler.RevokeSessions(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/unlock") && r.Method == http.MethodPost:
+			adminHandler.UnlockUser(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/mfa") && r.Method == http.MethodDelete:
+			mfaHandler.ResetUserMFA(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/assets") && r.Method == http.MethodGet:
+			adminHandler.ListUserAssets(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/usage") && r.Method == http.MethodGet:
+			quotaHandler.GetUserUsage(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/quota") && r.Method == http.MethodPut:
+			quotaHandler.SetUserQuota(w, r, admin)
+		case strings.HasSuffix(r.URL.Path, "/quota") && r.Method == http.MethodDelete:
+			quotaHandler.ResetUserQuota(w, r, admin)
+		case strings.Count(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/") > 0:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			adminHandler.GetUser(w, r, admin)
+		case r.Method == http.MethodPatch:
+			adminHandler.UpdateUser(w, r, admin)
+		case r.Method == http.MethodDelete:
+			adminHandler.DeleteUser(w, r, admin)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Квоты ролей (только администратор): список GET /api/admin/quotas и изменение
+	// PUT /api/admin/quotas/{role}.
+	mux.HandleFunc("/api/admin/quotas", auditor.Audit(models.AuditAdminQuota, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		quotaHandler.ListRoleQuotas(w, r, admin)
+	})))
+	mux.HandleFunc("/api/admin/quotas/", auditor.Audit(models.AuditAdminQuota, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		if r.Method != http.MethodPut {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		quotaHandler.SetRoleQuota(w, r, admin)
+	})))
+
+	// Политика второго фактора (только администратор): роли, для которых он обязателен,
+	// GET и PUT /api/admin/mfa-policy.
+	mux.HandleFunc("/api/admin/mfa-policy", auditor.Audit(models.AuditAdminMFAPolicy, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		switch r.Method {
+		case http.MethodGet:
+			mfaHandler.GetPolicy(w, r, admin)
+		case http.MethodPut:
+			mfaHandler.SetPolicy(w, r, admin)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Сопоставления клиентских сертификатов mTLS пользователям (только администратор):
+	// список GET и создание POST /api/admin/client-certs, удаление DELETE /api/admin/client-certs/{id}.
+	mux.HandleFunc("/api/admin/client-certs", auditor.Audit(models.AuditAdminCert, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		switch r.Method {
+		case http.MethodGet:
+			clientCertHandler.ListClientCerts(w, r, admin)
+		case http.MethodPost:
+			clientCertHandler.CreateClientCert(w, r, admin)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.HandleFunc("/api/admin/client-certs/", auditor.Audit(models.AuditAdminCert, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		clientCertHandler.DeleteClientCert(w, r, admin)
+	})))
+
+	// Журнал аудита (только администратор): выборка GET /api/admin/audit с фильтрами
+	// и проверка цепочки хешей GET /api/admin/audit/verify.
+	mux.HandleFunc("/api/admin/audit", auditor.Audit(models.AuditAdminAudit, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		auditHandler.ListEvents(w, r, admin)
+	})))
+	mux.HandleFunc("/api/admin/audit/verify", auditor.Audit(models.AuditAdminAudit, authn.RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		auditHandler.VerifyChain(w, r, admin)
+	})))
+
+	// Потребление хранилища текущим пользователем и действующая квота: GET /api/usage.
+	mux.HandleFunc("/api/usage", quotaHandler.GetUsage)
 
 	// Эндпоинт загрузки файла: POST /api/upload-asset/{assetName}.
-	mux.HandleFunc("/api/upload-asset/", assetHandler.UploadAsset)
+	// Имя может быть иерархическим, например builds/v1/app.tar.
+	mux.HandleFunc("/api/upload-asset/", uploadAsset)
 
-	// Эндпоинт для получения (GET) и удаления (DELETE) файла: /api/asset/{assetName}.
+	// Эндпоинт для получения (GET), получения только метаданных (HEAD) и удаления (DELETE)
+	// файла: /api/asset/{assetName}.
 	mux.HandleFunc("/api/asset/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			downloadAsset(w, r)
+		case http.MethodDelete:
+			deleteAsset(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Подписанные ссылки: выпуск POST /api/presign-asset/{assetName}, использование без токена —
+	// GET/HEAD (скачивание) или PUT (загрузка) /api/presigned/{assetName}?...&sig=...
+	mux.HandleFunc("/api/presign-asset/", auditor.Audit(models.AuditPresignCreate, assetHandler.PresignAsset))
+	mux.HandleFunc("/api/presigned/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			downloadPresigned(w, r)
+		case http.MethodPut:
+			uploadPresigned(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Публичные ссылки: выпуск POST /api/shares, список со счётчиками GET /api/shares,
+	// отзыв DELETE /api/shares/{slug}.
+	mux.HandleFunc("/api/shares", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			assetHandler.GetAsset(w, r)
+			shareHandler.ListShares(w, r)
+		case http.MethodPost:
+			createShare(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/shares/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		revokeShare(w, r)
+	})
+
+	// Открытие публичной ссылки без авторизации: GET/HEAD /s/{slug} и /s/{slug}/{path}.
+	mux.HandleFunc("/s/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			openShare(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Возобновляемая загрузка: создание загрузки POST /api/uploads,
+	// затем HEAD (текущее смещение), PATCH (дозапись части) и DELETE (отмена) /api/uploads/{id}.
+	mux.HandleFunc("/api/uploads", uploadHandler.CreateUpload)
+	mux.HandleFunc("/api/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			uploadHandler.GetUploadOffset(w, r)
+		case http.MethodPatch:
+			patchUpload(w, r)
+		case http.MethodDelete:
+			uploadHandler.AbortUpload(w, r)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// История версий файла: список GET и очистка DELETE /api/asset-versions/{assetName},
+	// откат к прежней версии POST /api/restore-asset/{assetName}?version=N.
+	mux.HandleFunc("/api/asset-versions/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			assetHandler.ListVersions(w, r)
 		case http.MethodDelete:
-			assetHandler.DeleteAsset(w, r)
+			pruneVersions(w, r)
 		default:
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
 	})
+	mux.HandleFunc("/api/restore-asset/", auditor.Audit(models.AuditAssetRestore, assetHandler.RestoreVersion))
+
+	// Перенос (переименование) файла или «папки»: POST /api/move-asset.
+	mux.HandleFunc("/api/move-asset", auditor.Audit(models.AuditAssetMove, assetHandler.MoveAsset))
 
 	// Эндпоинт для получения списка файлов: GET /api/assets.
 	mux.HandleFunc("/api/assets", assetHandler.ListAssets)
@@ -49,3 +407,16 @@ func RegisterRoutes(mux *http.ServeMux, pool *pgxpool.Pool) {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 }
+
+// writeJSON сериализует v в JSON и отправляет его с указанным статусом.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal response: %v", err)
+		http.Error(w, `{"error":"failed to marshal response"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// JWKSHandler публикует данные для проверки токенов доступа в формате JWT без обращения
+// к сервису: открытые ключи подписи и список отозванных сессий.
+type JWKSHandler struct {
+	jwtService *service.JWTService // Ключи подписи и отозванные сессии
+}
+
+// NewJWKSHandler создает новый экземпляр JWKSHandler.
+func NewJWKSHandler(jwtService *service.JWTService) *JWKSHandler {
+	return &JWKSHandler{jwtService: jwtService}
+}
+
+// revokedSessionsResponse — ответ со списком отозванных сессий.
+type revokedSessionsResponse struct {
+	Revoked []models.RevokedSession `json:"revoked"`
+}
+
+// GetJWKS обрабатывает GET /.well-known/jwks.json: открытые ключи, которыми подписаны
+// действующие токены доступа. Новый ключ публикуется заранее, до того как им начнут подписывать.
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	writeJSON(w, http.StatusOK, h.jwtService.JWKS())
+}
+
+// GetRevoked обрабатывает GET /.well-known/revoked-sessions.json: сессии, отозванные до истечения
+// выданных им токенов доступа. Токены с этими значениями sid нужно отклонять.
+func (h *JWKSHandler) GetRevoked(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, revokedSessionsResponse{Revoked: h.jwtService.Revoked()})
+}
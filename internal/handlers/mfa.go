@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// MFAHandler реализует подключение и отключение второго фактора (TOTP) пользователем
+// и API администрирования политики второго фактора. Эндпоинты пользователя доступны
+// только с токеном сессии, но не с API-ключом.
+type MFAHandler struct {
+	mfaService  *service.MFAService  // Сервис второго фактора
+	userService *service.UserService // Логин, роль и хеш пароля пользователя
+	auth        *Authenticator       // Проверка токена сессии
+}
+
+// NewMFAHandler создает новый экземпляр MFAHandler.
+func NewMFAHandler(mfaService *service.MFAService, userService *service.UserService, auth *Authenticator) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		userService: userService,
+		auth:        auth,
+	}
+}
+
+// mfaCodeRequest описывает JSON-запрос с кодом второго фактора.
+type mfaCodeRequest struct {
+	Code string `json:"code"` // Код TOTP (или код восстановления, где он допустим)
+}
+
+// disableMFARequest описывает JSON-запрос отключения второго фактора.
+type disableMFARequest struct {
+	Password string `json:"password"` // Текущий пароль
+	Code     string `json:"code"`     // Код TOTP или код восстановления
+}
+
+// mfaPolicyRequest описывает JSON-запрос изменения политики второго фактора.
+type mfaPolicyRequest struct {
+	RequiredRoles []string `json:"required_roles"` // Роли, для которых второй фактор обязателен
+}
+
+// GetStatus обрабатывает GET /api/mfa: состояние второго фактора текущего пользователя.
+func (h *MFAHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := h.sessionUser(w, r, http.MethodGet, "get-mfa")
+	if !ok {
+		return
+	}
+	status, err := h.mfaService.Status(context.Background(), user)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get mfa status: user=%d err=%v", user.ID, err)
+		http.Error(w, `{"error":"failed to get mfa status"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// Enroll обрабатывает POST /api/mfa/enroll: выдаёт новый секрет TOTP и URI otpauth://
+// для приложения-аутентификатора. Второй фактор начинает действовать после POST /api/mfa/confirm.
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := h.sessionUser(w, r, http.MethodPost, "enroll-mfa")
+	if !ok {
+		return
+	}
+	enrollment, err := h.mfaService.Enroll(context.Background(), user)
+	if !h.handleError(w, err, "enroll mfa", user.ID) {
+		return
+	}
+
+	log.Printf("[INFO] MFA enrollment started: user=%d ip=%s", user.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+// Confirm обрабатывает POST /api/mfa/confirm: завершает подключение первым кодом из приложения
+// и возвращает коды восстановления (показываются один раз). Остальные сессии пользователя завершаются.
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	user, userSession, ok := h.sessionUser(w, r, http.MethodPost, "confirm-mfa")
+	if !ok {
+		return
+	}
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.mfaService.Confirm(context.Background(), userSession, req.Code)
+	if !h.handleError(w, err, "confirm mfa", user.ID) {
+		return
+	}
+
+	log.Printf("[INFO] MFA enabled: user=%d ip=%s", user.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes обрабатывает POST /api/mfa/recovery-codes: выдаёт новые коды
+// восстановления вместо прежних после проверки кода второго фактора.
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := h.sessionUser(w, r, http.MethodPost, "regenerate-recovery-codes")
+	if !ok {
+		return
+	}
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(context.Background(), user.ID, req.Code)
+	if !h.handleError(w, err, "regenerate recovery codes", user.ID) {
+		return
+	}
+
+	log.Printf("[INFO] MFA recovery codes regenerated: user=%d ip=%s", user.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+// Disable обрабатывает POST /api/mfa/disable: отключает второй фактор после проверки пароля
+// и кода. Если второй фактор обязателен для роли пользователя, отвечает 409.
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := h.sessionUser(w, r, http.MethodPost, "disable-mfa")
+	if !ok {
+		return
+	}
+	var req disableMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.mfaService.Disable(context.Background(), user, req.Password, req.Code)
+	if !h.handleError(w, err, "disable mfa", user.ID) {
+		return
+	}
+
+	log.Printf("[INFO] MFA disabled: user=%d ip=%s", user.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ResetUserMFA обрабатывает DELETE /api/admin/users/{id}/mfa: отключает второй фактор
+// пользователя (например, при потере устройства и кодов восстановления) и завершает его сессии.
+func (h *MFAHandler) ResetUserMFA(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.userService.Get(context.Background(), id); errors.Is(err, service.ErrUserNotFound) {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[ERROR] Failed to get user: user=%d admin=%d err=%v", id, admin.UID, err)
+		http.Error(w, `{"error":"failed to reset mfa"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err := h.mfaService.Reset(context.Background(), id)
+	if !h.handleError(w, err, "reset mfa", id) {
+		return
+	}
+
+	log.Printf("[INFO] MFA reset by admin: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GetPolicy обрабатывает GET /api/admin/mfa-policy: роли, для которых второй фактор обязателен.
+func (h *MFAHandler) GetPolicy(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	roles, err := h.mfaService.RequiredRoles(context.Background())
+	if err != nil {
+		log.Printf("[ERROR] Failed to get mfa policy: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to get mfa policy"}`, http.StatusInternalServerError)
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	writeJSON(w, http.StatusOK, mfaPolicyRequest{RequiredRoles: roles})
+}
+
+// SetPolicy обрабатывает PUT /api/admin/mfa-policy: задаёт роли, для которых второй фактор
+// обязателен. Пользователям этих ролей без второго фактора остаётся только чтение.
+func (h *MFAHandler) SetPolicy(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	var req mfaPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	roles, err := h.mfaService.SetRequiredRoles(context.Background(), req.RequiredRoles)
+	if errors.Is(err, service.ErrInvalidUser) {
+		http.Error(w, `{"error":"role must be one of admin, user, readonly"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to set mfa policy: admin=%d err=%v", admin.UID, err)
+		http.Error(w, `{"error":"failed to set mfa policy"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+	log.Printf("[INFO] MFA policy changed: required_roles=%v admin=%d ip=%s", roles, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, mfaPolicyRequest{RequiredRoles: roles})
+}
+
+// sessionUser проверяет метод запроса и токен сессии и возвращает пользователя и его сессию.
+// При ошибке отвечает 405 или 401 и возвращает false.
+func (h *MFAHandler) sessionUser(w http.ResponseWriter, r *http.Request, method, action string) (*models.User, *models.Session, bool) {
+	if r.Method != method {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized %s attempt from ip=%s err=%v", action, r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	user, err := h.userService.Active(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized %s attempt from ip=%s err=%v", action, r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	return user, userSession, true
+}
+
+// handleError отвечает ошибкой сервиса второго фактора с подходящим статусом.
+// Возвращает true, если ошибки нет.
+func (h *MFAHandler) handleError(w http.ResponseWriter, err error, action string, uid int64) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrInvalidMFACode):
+		log.Printf("[WARN] Invalid mfa code on %s: user=%d", action, uid)
+		http.Error(w, `{"error":"invalid mfa code"}`, http.StatusForbidden)
+	case errors.Is(err, service.ErrWrongPassword):
+		log.Printf("[WARN] Wrong password on %s: user=%d", action, uid)
+		http.Error(w, `{"error":"wrong password"}`, http.StatusForbidden)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		http.Error(w, `{"error":"mfa already enabled"}`, http.StatusConflict)
+	case errors.Is(err, service.ErrMFANotEnabled):
+		http.Error(w, `{"error":"mfa not enabled"}`, http.StatusConflict)
+	case errors.Is(err, service.ErrMFARequired):
+		http.Error(w, `{"error":"mfa is required for this role"}`, http.StatusConflict)
+	default:
+		log.Printf("[ERROR] Failed to %s: user=%d err=%v", action, uid, err)
+		http.Error(w, `{"error":"failed to `+action+`"}`, http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+
+	"go-asset-service/internal/oidc"
+	"go-asset-service/internal/service"
+)
+
+// OIDCHandler реализует вход через провайдера OpenID Connect: перенаправление на страницу
+// входа провайдера и обработку обратного вызова с кодом авторизации.
+type OIDCHandler struct {
+	oidcService *service.OIDCService // Сервис входа через OIDC
+}
+
+// NewOIDCHandler создает новый экземпляр OIDCHandler.
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService}
+}
+
+// Login обрабатывает GET /api/auth/oidc/login: перенаправляет на страницу входа провайдера.
+// Необязательный параметр ?device= задаёт метку устройства для создаваемой сессии.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	authURL, err := h.oidcService.Start(context.Background(), deviceLabel(r, r.URL.Query().Get("device")))
+	if errors.Is(err, service.ErrOIDCDisabled) {
+		http.Error(w, `{"error":"oidc login not configured"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to start OIDC login: ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"identity provider unavailable"}`, http.StatusBadGateway)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback обрабатывает GET /api/auth/oidc/callback?code=...&state=...: завершает вход
+// и возвращает токены так же, как POST /api/auth (или mfa_token, если нужен второй фактор).
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	auditDetail(r, "method", "oidc")
+	if e := q.Get("error"); e != "" {
+		// Провайдер отказал во входе (например, пользователь отменил вход): state при этом
+		// не гасится и истечёт сам
+		log.Printf("[WARN] OIDC login rejected by provider: ip=%s error=%s", ip, e)
+		http.Error(w, `{"error":"oidc login failed"}`, http.StatusUnauthorized)
+		return
+	}
+	if q.Get("state") == "" || q.Get("code") == "" {
+		http.Error(w, `{"error":"missing code or state"}`, http.StatusBadRequest)
+		return
+	}
+
+	tokens, pending, err := h.oidcService.Callback(context.Background(), q.Get("state"), q.Get("code"), ip)
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled):
+		http.Error(w, `{"error":"oidc login not configured"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidOIDCState):
+		log.Printf("[WARN] Invalid OIDC state from ip=%s", ip)
+		http.Error(w, `{"error":"invalid or expired state"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken):
+		log.Printf("[WARN] OIDC login failed: ip=%s err=%v", ip, err)
+		http.Error(w, `{"error":"oidc login failed"}`, http.StatusUnauthorized)
+		return
+	case errors.Is(err, service.ErrOIDCIdentity):
+		log.Printf("[WARN] OIDC login failed: ip=%s err=%v", ip, err)
+		http.Error(w, `{"error":"cannot map identity to a user"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrAccountDisabled):
+		log.Printf("[WARN] OIDC login attempt to disabled account: ip=%s", ip)
+		http.Error(w, `{"error":"account disabled"}`, http.StatusForbidden)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to complete OIDC login: ip=%s err=%v", ip, err)
+		http.Error(w, `{"error":"identity provider unavailable"}`, http.StatusBadGateway)
+		return
+	}
+
+	if pending != nil {
+		log.Printf("[INFO] OIDC login accepted, second factor required: ip=%s", ip)
+		auditDetail(r, "mfa_required", "true")
+		writeJSON(w, http.StatusOK, mfaChallengeResponse{MFARequired: true, MFAToken: pending.Token, ExpiresAt: pending.ExpiresAt})
+		return
+	}
+	log.Printf("[INFO] User logged in via OIDC: user=%d ip=%s", tokens.UID, ip)
+	auditActor(r, tokens.UID)
+	writeJSON(w, http.StatusOK, newLoginResponse(tokens))
+}
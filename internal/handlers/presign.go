@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// presignRequest описывает JSON-запрос на выпуск подписанной ссылки.
+type presignRequest struct {
+	Method    string `json:"method"`     // GET (скачивание, по умолчанию) или PUT (загрузка)
+	ExpiresIn string `json:"expires_in"` // Срок действия в формате Go, например 1h (по умолчанию 1h)
+	MaxUses   int    `json:"max_uses"`   // Максимальное число использований (0 — без ограничения)
+	IP        string `json:"ip"`         // Разрешённый IP-адрес клиента (необязательно)
+}
+
+// PresignAsset обрабатывает запрос POST /api/presign-asset/{assetName}.
+// Выпускает подписанную ссылку, по которой без токена можно скачать файл (method GET)
+// или загрузить его новую версию (method PUT) — например, для внешних партнёров или браузера,
+// который не может передать заголовок Authorization. Для выпуска нужны те же права, что и для
+// самой операции; с параметром ?owner=<uid> ссылка выпускается на чужой файл, доступный по ACL.
+func (h *AssetHandler) PresignAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, err := h.checkAuth(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized presign attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/presign-asset/")
+	auditTarget(r, assetName)
+	var req presignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		if ttl, err = time.ParseDuration(req.ExpiresIn); err != nil || ttl <= 0 {
+			http.Error(w, `{"error":"invalid expires_in"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	scope, permission := models.ScopeAssetsRead, models.PermissionRead
+	if req.Method == http.MethodPut {
+		scope, permission = models.ScopeAssetsWrite, models.PermissionWrite
+	}
+	if !h.checkScope(w, r, principal, scope, assetName) {
+		return
+	}
+	owner, ok := h.assetOwner(w, r, principal, assetName, permission)
+	if !ok {
+		return
+	}
+	auditOwner(r, owner)
+	auditDetail(r, "method", req.Method)
+
+	presigned, query, err := h.presignService.Create(principal.UID, owner, assetName, req.Method, ttl, req.MaxUses, req.IP)
+	if errors.Is(err, service.ErrInvalidPresign) {
+		http.Error(w, `{"error":"valid asset name, method (GET or PUT), expires_in within the allowed maximum, non-negative max_uses and ip are required"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to presign asset: name=%s user=%d err=%v", assetName, principal.UID, err)
+		http.Error(w, `{"error":"failed to presign asset"}`, http.StatusInternalServerError)
+		return
+	}
+
+	link := url.URL{Scheme: "https", Host: r.Host, Path: "/api/presigned/" + assetName, RawQuery: query.Encode()}
+	log.Printf("[INFO] Presigned URL issued: id=%s method=%s name=%s owner=%d expires_at=%s max_uses=%d ip_bound=%t user=%d ip=%s",
+		presigned.ID, presigned.Method, assetName, owner, presigned.ExpiresAt.UTC().Format(time.RFC3339), presigned.MaxUses, presigned.IP != "", principal.UID, r.RemoteAddr)
+	auditDetail(r, "presign_id", presigned.ID)
+	auditDetail(r, "expires_at", presigned.ExpiresAt.UTC().Format(time.RFC3339))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"url":           link.String(),
+		"presigned_url": presigned,
+	})
+}
+
+// ServePresigned обрабатывает запросы GET, HEAD и PUT /api/presigned/{assetName}?...&sig=...
+// по подписанной ссылке, без заголовка Authorization: проверяет подпись, срок действия,
+// привязку к IP и число использований, после чего отдаёт файл (GET/HEAD, с поддержкой Range
+// и условных запросов) или сохраняет тело запроса как его новую версию (PUT).
+func (h *AssetHandler) ServePresigned(w http.ResponseWriter, r *http.Request) {
+	assetName := strings.TrimPrefix(r.URL.Path, "/api/presigned/")
+	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	auditTarget(r, assetName)
+	auditDetail(r, "presign_id", r.URL.Query().Get("id"))
+
+	presigned, err := h.presignService.Verify(context.Background(), r.Method, assetName, r.URL.Query(), clientIP)
+	if err != nil {
+		msg := "invalid signature"
+		switch {
+		case errors.Is(err, service.ErrPresignExpired):
+			msg = "link expired"
+		case errors.Is(err, service.ErrPresignIPMismatch):
+			msg = "link is bound to another ip"
+		case errors.Is(err, service.ErrPresignExhausted):
+			msg = "link use limit reached"
+		case errors.Is(err, service.ErrPresignRevoked):
+			msg = "access revoked"
+		case !errors.Is(err, service.ErrInvalidSignature):
+			log.Printf("[ERROR] Failed to verify presigned URL: name=%s ip=%s err=%v", assetName, r.RemoteAddr, err)
+			http.Error(w, `{"error":"failed to verify link"}`, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[WARN] Rejected presigned URL: name=%s id=%s ip=%s err=%v", assetName, r.URL.Query().Get("id"), r.RemoteAddr, err)
+		http.Error(w, `{"error":"`+msg+`"}`, http.StatusForbidden)
+		return
+	}
+	auditOwner(r, presigned.Owner)
+
+	if r.Method == http.MethodPut {
+		contentType, err := requestContentType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, `{"error":"invalid Content-Type"}`, http.StatusBadRequest)
+			return
+		}
+		metadata, err := metadataFromHeaders(r.Header)
+		if err != nil {
+			http.Error(w, `{"error":"invalid asset metadata"}`, http.StatusBadRequest)
+			return
+		}
+		asset, err := h.assetService.Upload(context.Background(), presigned.Owner, assetName, r.Body, r.ContentLength, contentType, metadata)
+		if writeQuotaError(w, err) {
+			log.Printf("[WARN] Presigned upload rejected by quota: id=%s name=%s owner=%d ip=%s err=%v", presigned.ID, assetName, presigned.Owner, r.RemoteAddr, err)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to save asset via presigned URL: id=%s name=%s owner=%d ip=%s err=%v", presigned.ID, assetName, presigned.Owner, r.RemoteAddr, err)
+			http.Error(w, `{"error":"failed to save asset"}`, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[INFO] Asset uploaded via presigned URL: id=%s name=%s version=%d size=%d owner=%d ip=%s", presigned.ID, assetName, asset.Version, asset.Size, presigned.Owner, r.RemoteAddr)
+		auditDetail(r, "version", strconv.Itoa(asset.Version))
+		auditDetail(r, "size", strconv.FormatInt(asset.Size, 10))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "version": asset.Version})
+		return
+	}
+
+	asset, content, err := h.assetService.Open(context.Background(), presigned.Owner, assetName)
+	if errors.Is(err, service.ErrAssetNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to open asset via presigned URL: id=%s name=%s owner=%d err=%v", presigned.ID, assetName, presigned.Owner, err)
+		http.Error(w, `{"error":"failed to read asset"}`, http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	log.Printf("[INFO] Asset retrieved via presigned URL: id=%s name=%s version=%d owner=%d ip=%s", presigned.ID, assetName, asset.Version, presigned.Owner, r.RemoteAddr)
+	auditDetail(r, "version", strconv.Itoa(asset.Version))
+	setAssetHeaders(w, asset)
+	http.ServeContent(w, r, "", asset.UpdatedAt, content)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// QuotaHandler реализует просмотр пользователем своего потребления хранилища и API
+// администрирования квот ролей и отдельных пользователей.
+type QuotaHandler struct {
+	quotaService *service.QuotaService // Сервис квот
+	auth         *Authenticator        // Проверка токена сессии или API-ключа
+}
+
+// NewQuotaHandler создает новый экземпляр QuotaHandler.
+func NewQuotaHandler(quotaService *service.QuotaService, auth *Authenticator) *QuotaHandler {
+	return &QuotaHandler{
+		quotaService: quotaService,
+		auth:         auth,
+	}
+}
+
+// GetUsage обрабатывает GET /api/usage: потребление хранилища вызывающим и действующая квота.
+func (h *QuotaHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, err := h.auth.Principal(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized get-usage attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if !principal.HasScope(models.ScopeAssetsRead) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+
+	usage, err := h.quotaService.Usage(context.Background(), principal.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get usage: user=%d err=%v", principal.UID, err)
+		http.Error(w, `{"error":"failed to get usage"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// GetUserUsage обрабатывает GET /api/admin/users/{id}/usage.
+func (h *QuotaHandler) GetUserUsage(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	usage, err := h.quotaService.Usage(context.Background(), id)
+	if !h.handleError(w, err, "get usage", admin) {
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// SetUserQuota обрабатывает PUT /api/admin/users/{id}/quota: задаёт персональную квоту.
+// Поля, равные null или отсутствующие, берутся из квоты роли пользователя.
+func (h *QuotaHandler) SetUserQuota(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req models.Quota
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.quotaService.SetUserQuota(context.Background(), id, req)
+	if !h.handleError(w, err, "set quota", admin) {
+		return
+	}
+
+	log.Printf("[INFO] User quota set: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// ResetUserQuota обрабатывает DELETE /api/admin/users/{id}/quota: удаляет персональную квоту,
+// после чего для пользователя действует квота его роли.
+func (h *QuotaHandler) ResetUserQuota(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	id, ok := userIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	usage, err := h.quotaService.ResetUserQuota(context.Background(), id)
+	if !h.handleError(w, err, "reset quota", admin) {
+		return
+	}
+
+	log.Printf("[INFO] User quota reset: user=%d admin=%d ip=%s", id, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// ListRoleQuotas обрабатывает GET /api/admin/quotas.
+func (h *QuotaHandler) ListRoleQuotas(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	quotas, err := h.quotaService.ListRoleQuotas(context.Background())
+	if !h.handleError(w, err, "list quotas", admin) {
+		return
+	}
+	if quotas == nil {
+		quotas = []models.RoleQuota{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"quotas": quotas})
+}
+
+// SetRoleQuota обрабатывает PUT /api/admin/quotas/{role}. Поля, равные null или
+// отсутствующие, означают отсутствие ограничения.
+func (h *QuotaHandler) SetRoleQuota(w http.ResponseWriter, r *http.Request, admin *models.Principal) {
+	role := strings.TrimPrefix(r.URL.Path, "/api/admin/quotas/")
+	var req models.Quota
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	quota, err := h.quotaService.SetRoleQuota(context.Background(), role, req)
+	if !h.handleError(w, err, "set quota", admin) {
+		return
+	}
+
+	log.Printf("[INFO] Role quota set: role=%s admin=%d ip=%s", role, admin.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, quota)
+}
+
+// handleError отвечает ошибкой сервиса квот с подходящим статусом.
+// Возвращает true, если ошибки нет.
+func (h *QuotaHandler) handleError(w http.ResponseWriter, err error, action string, admin *models.Principal) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidQuota):
+		http.Error(w, `{"error":"quota limits must be non-negative or null; role one of admin, user, readonly"}`, http.StatusBadRequest)
+	default:
+		log.Printf("[ERROR] Failed to %s: admin=%d err=%v", action, admin.UID, err)
+		http.Error(w, `{"error":"failed to `+action+`"}`, http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// SessionHandler реализует HTTP-обработчики для просмотра и отзыва сессий пользователя.
+// Эндпоинты доступны только с токеном сессии, но не с API-ключом.
+type SessionHandler struct {
+	authService *service.AuthService // Сервис авторизации и управления сессиями
+	auth        *Authenticator       // Проверка токена сессии
+}
+
+// NewSessionHandler создает новый экземпляр SessionHandler.
+func NewSessionHandler(authService *service.AuthService, auth *Authenticator) *SessionHandler {
+	return &SessionHandler{authService: authService, auth: auth}
+}
+
+// sessionView — представление сессии в ответе API с отметкой текущей сессии.
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"` // Сессия, с которой выполнен запрос
+}
+
+// ListSessions обрабатывает GET /api/sessions.
+// Возвращает все сессии текущего пользователя (без токенов).
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-sessions attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list sessions for user=%d: %v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to list sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]sessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, sessionView{Session: s, Current: s.ID == userSession.ID})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": views})
+}
+
+// RevokeSession обрабатывает DELETE /api/sessions/{id}.
+// Завершает одну из сессий текущего пользователя (в том числе текущую).
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized revoke-session attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	publicID := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	err = h.authService.RevokeSession(context.Background(), userSession.UID, publicID)
+	if errors.Is(err, service.ErrSessionNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke session: id=%s user=%d err=%v", publicID, userSession.UID, err)
+		http.Error(w, `{"error":"failed to revoke session"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Session revoked: id=%s user=%d ip=%s", publicID, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// RevokeOtherSessions обрабатывает POST /api/sessions/revoke-others.
+// Завершает все сессии пользователя, кроме той, с которой выполнен запрос.
+func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized revoke-sessions attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	n, err := h.authService.RevokeOtherSessions(context.Background(), userSession)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke other sessions: user=%d err=%v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Other sessions revoked: count=%d user=%d ip=%s", n, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "revoked": n})
+}
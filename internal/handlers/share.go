@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// ShareHandler реализует HTTP-обработчики публичных ссылок: выпуск, просмотр и отзыв
+// (только с токеном сессии) и открытие ссылки /s/{slug} без авторизации.
+type ShareHandler struct {
+	shareService *service.ShareService // Сервис публичных ссылок
+	assetService *service.AssetService // Сервис для чтения файлов по ссылке
+	auth         *Authenticator        // Проверка токена сессии
+}
+
+// NewShareHandler создает новый экземпляр ShareHandler.
+func NewShareHandler(shareService *service.ShareService, assetService *service.AssetService, auth *Authenticator) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+		assetService: assetService,
+		auth:         auth,
+	}
+}
+
+// createShareRequest описывает JSON-запрос на выпуск публичной ссылки.
+type createShareRequest struct {
+	Name         string     `json:"name"`          // Имя файла или префикс с «/» на конце
+	Owner        int64      `json:"owner"`         // Владелец чужого файла, доступного по ACL (необязательно)
+	Slug         string     `json:"slug"`          // Желаемый идентификатор ссылки (необязательно)
+	Password     string     `json:"password"`      // Пароль (необязательно)
+	ExpiresAt    *time.Time `json:"expires_at"`    // Срок действия (необязательно)
+	MaxDownloads *int       `json:"max_downloads"` // Ограничение числа скачиваний (необязательно)
+}
+
+// shareEntry — файл в списке содержимого «папки», открытой по публичной ссылке.
+// Имя указывается относительно префикса ссылки.
+type shareEntry struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"content_type"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateShare обрабатывает POST /api/shares.
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized create-share attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req createShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	owner := req.Owner
+	if owner == 0 {
+		owner = userSession.UID
+	}
+	auditTarget(r, req.Name)
+	auditOwner(r, owner)
+
+	link, err := h.shareService.Create(context.Background(), userSession.UID, owner, req.Name, req.Slug, req.Password, req.ExpiresAt, req.MaxDownloads)
+	switch {
+	case errors.Is(err, service.ErrInvalidShare):
+		http.Error(w, `{"error":"valid name and slug are required, expires_at must be in the future, max_downloads must be positive"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrAccessDenied):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrShareExists):
+		http.Error(w, `{"error":"slug already taken"}`, http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to create share link: name=%s user=%d err=%v", req.Name, userSession.UID, err)
+		http.Error(w, `{"error":"failed to create share link"}`, http.StatusInternalServerError)
+		return
+	}
+
+	shareURL := url.URL{Scheme: "https", Host: r.Host, Path: "/s/" + link.Slug}
+	log.Printf("[INFO] Share link created: slug=%s name=%s owner=%d password=%t user=%d ip=%s", link.Slug, link.Name, owner, link.HasPassword, userSession.UID, r.RemoteAddr)
+	auditDetail(r, "share", link.Slug)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"url": shareURL.String(), "share": link})
+}
+
+// ListShares обрабатывает GET /api/shares: ссылки пользователя со счётчиками обращений и скачиваний.
+func (h *ShareHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized list-shares attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	links, err := h.shareService.List(context.Background(), userSession.UID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list share links for user=%d: %v", userSession.UID, err)
+		http.Error(w, `{"error":"failed to list share links"}`, http.StatusInternalServerError)
+		return
+	}
+	if links == nil {
+		links = []models.ShareLink{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": links})
+}
+
+// RevokeShare обрабатывает DELETE /api/shares/{slug}.
+func (h *ShareHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	userSession, err := h.auth.Session(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized revoke-share attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	slug := strings.TrimPrefix(r.URL.Path, "/api/shares/")
+	auditDetail(r, "share", slug)
+	err = h.shareService.Revoke(context.Background(), userSession.UID, slug)
+	if errors.Is(err, service.ErrShareNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke share link: slug=%s user=%d err=%v", slug, userSession.UID, err)
+		http.Error(w, `{"error":"failed to revoke share link"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Share link revoked: slug=%s user=%d ip=%s", slug, userSession.UID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// OpenShare обрабатывает запросы GET и HEAD /s/{slug} и /s/{slug}/{path} без авторизации.
+// Ссылка на файл отдаёт его содержимое; ссылка на «папку» по /s/{slug} возвращает JSON-список
+// её файлов (параметры limit и cursor), а по /s/{slug}/{path} — файл «папки».
+// Пароль передаётся через HTTP Basic (имя пользователя любое) или заголовок X-Share-Password;
+// без него ответ — 401 с WWW-Authenticate, чтобы браузер показал окно ввода пароля.
+// Скачиванием считается каждый GET содержимого, в том числе запрос диапазона: иначе лимит
+// скачиваний можно было бы обойти, забирая файл по частям. Неверные пароли ограничиваются
+// так же, как попытки входа: при превышении ответ — 429 с Retry-After.
+func (h *ShareHandler) OpenShare(w http.ResponseWriter, r *http.Request) {
+	slug, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/s/"), "/")
+	password := r.Header.Get("X-Share-Password")
+	if _, p, ok := r.BasicAuth(); ok {
+		password = p
+	}
+	auditDetail(r, "share", slug)
+
+	ip := clientIP(r)
+	link, err := h.shareService.Open(context.Background(), slug, password, ip)
+	var attemptsErr *service.AttemptsError
+	switch {
+	case errors.Is(err, service.ErrShareNotFound):
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrShareExpired):
+		http.Error(w, `{"error":"link expired"}`, http.StatusGone)
+		return
+	case errors.Is(err, service.ErrSharePassword):
+		log.Printf("[WARN] Share link password required or wrong: slug=%s ip=%s", slug, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="share", charset="UTF-8"`)
+		http.Error(w, `{"error":"password required"}`, http.StatusUnauthorized)
+		return
+	case errors.As(err, &attemptsErr):
+		retryAfter := int64((attemptsErr.RetryAfter + time.Second - 1) / time.Second)
+		log.Printf("[WARN] Share link password attempt throttled: slug=%s ip=%s retry_after=%ds", slug, ip, retryAfter)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		http.Error(w, `{"error":"too many password attempts"}`, http.StatusTooManyRequests)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to open share link: slug=%s ip=%s err=%v", slug, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to open share link"}`, http.StatusInternalServerError)
+		return
+	}
+
+	isFolder := strings.HasSuffix(link.Name, "/")
+	if isFolder && rest == "" {
+		// Список файлов «папки» — не скачивание
+		auditSkip(r)
+		h.listShare(w, r, link)
+		return
+	}
+	assetName := link.Name
+	if isFolder {
+		assetName = link.Name + rest
+	}
+	auditTarget(r, assetName)
+	auditOwner(r, link.Owner)
+	if (!isFolder && rest != "") || !service.ValidAssetName(assetName) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+
+	asset, content, err := h.assetService.Open(context.Background(), link.Owner, assetName)
+	if errors.Is(err, service.ErrAssetNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to open shared asset: slug=%s name=%s err=%v", slug, assetName, err)
+		http.Error(w, `{"error":"failed to read asset"}`, http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	download := r.Method == http.MethodGet
+	if !h.recordAccess(w, r, link, download) {
+		return
+	}
+
+	log.Printf("[INFO] Shared asset retrieved: slug=%s name=%s version=%d download=%t ip=%s", slug, assetName, asset.Version, download, r.RemoteAddr)
+	auditDetail(r, "version", strconv.Itoa(asset.Version))
+	setAssetHeaders(w, asset)
+	http.ServeContent(w, r, "", asset.UpdatedAt, content)
+}
+
+// listShare отвечает списком файлов «папки», открытой по ссылке link.
+func (h *ShareHandler) listShare(w http.ResponseWriter, r *http.Request, link *models.ShareLink) {
+	opts := models.AssetListOptions{Owner: link.Owner, Prefix: link.Name, Cursor: r.URL.Query().Get("cursor")}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, `{"error":"invalid query parameter: limit"}`, http.StatusBadRequest)
+			return
+		}
+		opts.Limit = limit
+	}
+
+	page, err := h.assetService.List(context.Background(), link.Owner, opts)
+	if errors.Is(err, service.ErrInvalidCursor) {
+		http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to list shared assets: slug=%s err=%v", link.Slug, err)
+		http.Error(w, `{"error":"failed to list assets"}`, http.StatusInternalServerError)
+		return
+	}
+	if !h.recordAccess(w, r, link, false) {
+		return
+	}
+
+	entries := make([]shareEntry, 0, len(page.Assets))
+	for _, a := range page.Assets {
+		entries = append(entries, shareEntry{
+			Name:        strings.TrimPrefix(a.Name, link.Name),
+			Size:        a.Size,
+			SHA256:      a.SHA256,
+			ContentType: a.ContentType,
+			UpdatedAt:   a.UpdatedAt,
+		})
+	}
+	resp := map[string]interface{}{"name": link.Name, "assets": entries}
+	if page.NextCursor != "" {
+		resp["next_cursor"] = page.NextCursor
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// recordAccess засчитывает обращение по ссылке. Если лимит скачиваний исчерпан, отвечает 410
+// и возвращает false.
+func (h *ShareHandler) recordAccess(w http.ResponseWriter, r *http.Request, link *models.ShareLink, download bool) bool {
+	err := h.shareService.RecordAccess(context.Background(), link.Slug, download)
+	if errors.Is(err, service.ErrShareLimitReached) {
+		log.Printf("[WARN] Share link download limit reached: slug=%s ip=%s", link.Slug, r.RemoteAddr)
+		http.Error(w, `{"error":"download limit reached"}`, http.StatusGone)
+		return false
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to record share link access: slug=%s err=%v", link.Slug, err)
+		http.Error(w, `{"error":"failed to open share link"}`, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
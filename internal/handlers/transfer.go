@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// transferExtendStep — насколько должен сдвинуться срок, чтобы его стоило продлить:
+// не трогаем соединение на каждом прочитанном или записанном блоке.
+const transferExtendStep = time.Second
+
+// withTransferDeadlines снимает с запросов, передающих содержимое файлов, общий таймаут записи
+// сервера (WriteTimeout): загрузка и скачивание больших файлов длятся сколько угодно, пока идут данные.
+// Сроки чтения и записи соединения продлеваются через http.ResponseController на idle
+// после каждого переданного блока, поэтому зависший клиент отключается через idle без активности.
+func withTransferDeadlines(idle time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := &transferDeadline{rc: http.NewResponseController(w), idle: idle, r: r}
+		d.extend()
+		if r.Body != nil {
+			r.Body = &transferBody{ReadCloser: r.Body, deadline: d}
+		}
+		next(&transferWriter{ResponseWriter: w, deadline: d}, r)
+	}
+}
+
+// transferDeadline продлевает сроки чтения и записи соединения одного запроса.
+type transferDeadline struct {
+	rc       *http.ResponseController
+	idle     time.Duration
+	r        *http.Request
+	until    time.Time // Текущий срок
+	disabled bool      // Соединение не поддерживает сроки (или их не удалось продлить)
+}
+
+// extend сдвигает сроки на idle от текущего момента.
+func (d *transferDeadline) extend() {
+	if d.disabled {
+		return
+	}
+	until := time.Now().Add(d.idle)
+	if until.Sub(d.until) < transferExtendStep {
+		return
+	}
+	err := d.rc.SetWriteDeadline(until)
+	if err == nil {
+		err = d.rc.SetReadDeadline(until)
+	}
+	if err != nil {
+		d.disabled = true
+		if !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("[WARN] Failed to extend connection deadline: path=%s ip=%s err=%v", d.r.URL.Path, d.r.RemoteAddr, err)
+		}
+		return
+	}
+	d.until = until
+}
+
+// transferBody продлевает сроки соединения при чтении тела запроса.
+type transferBody struct {
+	io.ReadCloser
+	deadline *transferDeadline
+}
+
+func (b *transferBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.deadline.extend()
+	}
+	return n, err
+}
+
+// transferWriter продлевает сроки соединения перед записью ответа.
+type transferWriter struct {
+	http.ResponseWriter
+	deadline *transferDeadline
+}
+
+func (tw *transferWriter) Write(b []byte) (int, error) {
+	tw.deadline.extend()
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController обращаться к исходному ResponseWriter.
+func (tw *transferWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
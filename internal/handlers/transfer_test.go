@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowBody отвечает блоками с паузами; в сумме ответ пишется дольше WriteTimeout сервера.
+func slowBody(w http.ResponseWriter, r *http.Request) {
+	for i := 0; i < 6; i++ {
+		io.WriteString(w, "chunk\n")
+		http.NewResponseController(w).Flush()
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func newTimeoutServer(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.WriteTimeout = 250 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTransferDeadlinesOutliveWriteTimeout(t *testing.T) {
+	srv := newTimeoutServer(t, withTransferDeadlines(time.Second, slowBody))
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if got := strings.Count(string(body), "chunk"); got != 6 {
+		t.Fatalf("got %d chunks, want 6", got)
+	}
+}
+
+func TestWriteTimeoutWithoutTransferDeadlines(t *testing.T) {
+	srv := newTimeoutServer(t, slowBody)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && strings.Count(string(body), "chunk") == 6 {
+		t.Fatal("response outlived WriteTimeout without transfer deadlines")
+	}
+}
+
+func TestTransferDeadlinesIdleClient(t *testing.T) {
+	// Обработчик ждёт тело запроса, которое клиент не отправляет: соединение закрывается через idle
+	done := make(chan error, 1)
+	srv := newTimeoutServer(t, withTransferDeadlines(200*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		done <- err
+	}))
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		pw.Write([]byte("start"))
+	}()
+	go http.Post(srv.URL, "application/octet-stream", pr)
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("body read succeeded, want deadline error")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle client was not disconnected")
+	}
+}
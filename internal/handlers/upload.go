@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/service"
+)
+
+// uploadContentType — обязательный Content-Type для частей возобновляемой загрузки (как в протоколе tus).
+const uploadContentType = "application/offset+octet-stream"
+
+// UploadHandler реализует HTTP-обработчики возобновляемой загрузки больших файлов.
+type UploadHandler struct {
+	uploadService *service.UploadService // Сервис возобновляемых загрузок
+	auth          *Authenticator         // Проверка токена сессии или API-ключа
+}
+
+// NewUploadHandler создает новый экземпляр UploadHandler.
+func NewUploadHandler(uploadService *service.UploadService, auth *Authenticator) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+		auth:          auth,
+	}
+}
+
+// createUploadRequest описывает JSON-запрос на создание загрузки.
+type createUploadRequest struct {
+	Name        string            `json:"name"`         // Имя итогового файла
+	Length      int64             `json:"length"`       // Итоговый размер файла в байтах
+	ContentType string            `json:"content_type"` // MIME-тип файла (необязательно)
+	Metadata    map[string]string `json:"metadata"`     // Пользовательские метаданные (необязательно)
+}
+
+// CreateUpload обрабатывает POST /api/uploads.
+// Создаёт новую загрузку и возвращает её идентификатор; адрес для дозаписи частей
+// передаётся в заголовке Location.
+func (h *UploadHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, err := h.auth.Principal(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized create-upload attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req createUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !principal.CanAccess(models.ScopeAssetsWrite, req.Name) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+	if _, err := requestContentType(req.ContentType); err != nil {
+		http.Error(w, `{"error":"invalid content_type"}`, http.StatusBadRequest)
+		return
+	}
+	if err := validateMetadata(req.Metadata); err != nil {
+		http.Error(w, `{"error":"invalid asset metadata"}`, http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.uploadService.Create(context.Background(), principal.UID, req.Name, req.Length, req.ContentType, req.Metadata)
+	if errors.Is(err, service.ErrInvalidUpload) {
+		http.Error(w, `{"error":"valid name and non-negative length are required"}`, http.StatusBadRequest)
+		return
+	}
+	if writeQuotaError(w, err) {
+		log.Printf("[WARN] Upload rejected by quota: user=%d name=%s length=%d ip=%s err=%v", principal.UID, req.Name, req.Length, r.RemoteAddr, err)
+		return
+	}
+	if errors.Is(err, service.ErrTooManyUploads) {
+		log.Printf("[WARN] Upload rejected: user=%d name=%s ip=%s err=%v", principal.UID, req.Name, r.RemoteAddr, err)
+		http.Error(w, `{"error":"too many pending uploads"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create upload: user=%d name=%s ip=%s err=%v", principal.UID, req.Name, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to create upload"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Upload created: id=%s name=%s length=%d user=%d ip=%s", upload.ID, upload.Name, upload.Length, principal.UID, r.RemoteAddr)
+	w.Header().Set("Location", "/api/uploads/"+upload.ID)
+	setUploadHeaders(w, upload)
+	writeJSON(w, http.StatusCreated, upload)
+}
+
+// GetUploadOffset обрабатывает HEAD /api/uploads/{id}.
+// Возвращает текущее смещение загрузки в заголовке Upload-Offset, чтобы клиент
+// мог продолжить передачу после обрыва соединения.
+func (h *UploadHandler) GetUploadOffset(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.auth.Principal(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	upload, err := h.uploadService.Get(context.Background(), principal.UID, uploadIDFromPath(r))
+	if errors.Is(err, service.ErrUploadNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get upload: user=%d ip=%s err=%v", principal.UID, r.RemoteAddr, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !principal.CanAccess(models.ScopeAssetsWrite, upload.Name) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	setUploadHeaders(w, upload)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchUpload обрабатывает PATCH /api/uploads/{id}.
+// Тело запроса дописывается к загрузке начиная со смещения из заголовка Upload-Offset,
+// которое должно совпадать с текущим. Когда получены все байты, загрузка собирается
+// в файл и возвращается 201 с его описанием; иначе — 204 с новым смещением.
+func (h *UploadHandler) PatchUpload(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.auth.Principal(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized patch-upload attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != uploadContentType {
+		http.Error(w, `{"error":"content type must be `+uploadContentType+`"}`, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, `{"error":"invalid Upload-Offset header"}`, http.StatusBadRequest)
+		return
+	}
+
+	id := uploadIDFromPath(r)
+	auditDetail(r, "upload_id", id)
+	if !h.checkUploadAccess(w, principal, id) {
+		return
+	}
+	upload, asset, err := h.uploadService.WriteChunk(context.Background(), principal.UID, id, offset, r.Body, r.ContentLength)
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrOffsetMismatch):
+		if upload != nil {
+			setUploadHeaders(w, upload)
+		}
+		http.Error(w, `{"error":"offset mismatch"}`, http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrChunkTooLarge):
+		http.Error(w, `{"error":"chunk exceeds upload length"}`, http.StatusRequestEntityTooLarge)
+		return
+	case writeQuotaError(w, err):
+		log.Printf("[WARN] Upload finalization rejected by quota: id=%s user=%d ip=%s err=%v", id, principal.UID, r.RemoteAddr, err)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to write upload chunk: id=%s user=%d ip=%s err=%v", id, principal.UID, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to write chunk"}`, http.StatusInternalServerError)
+		return
+	}
+
+	setUploadHeaders(w, upload)
+	if asset != nil {
+		log.Printf("[INFO] Upload finalized: id=%s name=%s size=%d user=%d ip=%s", id, asset.Name, asset.Size, principal.UID, r.RemoteAddr)
+		auditTarget(r, asset.Name)
+		auditOwner(r, asset.UID)
+		auditDetail(r, "version", strconv.Itoa(asset.Version))
+		auditDetail(r, "size", strconv.FormatInt(asset.Size, 10))
+		w.Header().Set("Location", "/api/asset/"+asset.Name)
+		writeJSON(w, http.StatusCreated, asset)
+		return
+	}
+	// Промежуточная часть загрузки в журнал не пишется: записывается только сборка файла
+	auditSkip(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AbortUpload обрабатывает DELETE /api/uploads/{id}: отменяет загрузку и удаляет принятые части.
+func (h *UploadHandler) AbortUpload(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.auth.Principal(r)
+	if err != nil {
+		log.Printf("[WARN] Unauthorized abort-upload attempt from ip=%s err=%v", r.RemoteAddr, err)
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	id := uploadIDFromPath(r)
+	if !h.checkUploadAccess(w, principal, id) {
+		return
+	}
+	err = h.uploadService.Abort(context.Background(), principal.UID, id)
+	if errors.Is(err, service.ErrUploadNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to abort upload: id=%s user=%d ip=%s err=%v", id, principal.UID, r.RemoteAddr, err)
+		http.Error(w, `{"error":"failed to abort upload"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] Upload aborted: id=%s user=%d ip=%s", id, principal.UID, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkUploadAccess проверяет, что вызывающему разрешена запись в файл, в который собирается загрузка
+// (API-ключ или сертификат может быть ограничен префиксами имён). При отказе отвечает ошибкой
+// и возвращает false.
+func (h *UploadHandler) checkUploadAccess(w http.ResponseWriter, principal *models.Principal, id string) bool {
+	if principal.APIKey == nil && principal.ClientCert == nil {
+		return true
+	}
+	upload, err := h.uploadService.Get(context.Background(), principal.UID, id)
+	if errors.Is(err, service.ErrUploadNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to get upload"}`, http.StatusInternalServerError)
+		return false
+	}
+	if !principal.CanAccess(models.ScopeAssetsWrite, upload.Name) {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// uploadIDFromPath извлекает идентификатор загрузки из пути /api/uploads/{id}.
+func uploadIDFromPath(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/api/uploads/")
+}
+
+// setUploadHeaders выставляет заголовки состояния загрузки.
+func setUploadHeaders(w http.ResponseWriter, u *models.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(time.RFC1123))
+}
@@ -0,0 +1,53 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/config"
+)
+
+// Attempts — состояние неудачных попыток входа по одному ключу (логину или IP-адресу).
+type Attempts struct {
+	Failures    int       // Число неудачных попыток подряд
+	LastFailure time.Time // Время последней неудачной попытки
+	LockedUntil time.Time // До этого времени попытки входа отклоняются
+}
+
+// AttemptStore хранит счётчики неудачных попыток входа. Ключ — строка вида "login:<логин>"
+// или "ip:<адрес>". Хранение в памяти подходит для одного экземпляра сервиса; при нескольких
+// экземплярах нужно общее хранилище в Postgres.
+type AttemptStore interface {
+	// Get возвращает состояние ключа key; для неизвестного ключа — нулевое значение.
+	Get(ctx context.Context, key string) (Attempts, error)
+	// Reserve атомарно проверяет и засчитывает попытку по ключу key в момент now. Если ключ
+	// заблокирован, ничего не меняется и возвращается false. Иначе попытка заранее считается
+	// неудачной (если предыдущая неудача была раньше, чем window назад, счёт начинается заново),
+	// ключ блокируется на delay(новое число неудач) и возвращаются состояния до и после резерва:
+	// параллельные попытки не могут вместе проскочить проверку.
+	Reserve(ctx context.Context, key string, now time.Time, window time.Duration, delay func(failures int) time.Duration) (prev, reserved Attempts, ok bool, err error)
+	// Release отменяет резерв Reserve после успешной попытки: уменьшает счётчик на единицу
+	// (удаляя ключ, если неудач не осталось) и, если блокировка ключа всё ещё установлена
+	// этим резервом (reserved.LockedUntil), возвращает прежнюю (prev.LockedUntil).
+	Release(ctx context.Context, key string, prev, reserved Attempts) error
+	// Reset удаляет счётчик ключа key (успешный вход или разблокировка администратором).
+	Reset(ctx context.Context, key string) error
+	// DeleteExpired удаляет ключи без блокировки, последняя неудача по которым была раньше,
+	// чем window до now, и возвращает их число.
+	DeleteExpired(ctx context.Context, now time.Time, window time.Duration) (int64, error)
+}
+
+// NewAttemptStore создаёт хранилище попыток входа в соответствии с параметром
+// LOGIN_ATTEMPT_STORE из конфигурации.
+func NewAttemptStore(cfg *config.Config, pool *pgxpool.Pool) (AttemptStore, error) {
+	switch cfg.LoginAttemptStore {
+	case "postgres", "":
+		return NewPostgresStore(pool), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown login attempt store %q", cfg.LoginAttemptStore)
+	}
+}
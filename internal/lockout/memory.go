@@ -0,0 +1,92 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит счётчики попыток входа в памяти процесса. Счётчики не переживают
+// перезапуск и не разделяются между экземплярами сервиса.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]*Attempts
+}
+
+// NewMemoryStore создаёт пустое MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]*Attempts)}
+}
+
+// Get возвращает состояние ключа key.
+func (s *MemoryStore) Get(ctx context.Context, key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.attempts[key]; ok {
+		return *a, nil
+	}
+	return Attempts{}, nil
+}
+
+// Reserve атомарно проверяет и засчитывает попытку по ключу key.
+func (s *MemoryStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration, delay func(failures int) time.Duration) (Attempts, Attempts, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &Attempts{}
+		s.attempts[key] = a
+	}
+	prev := *a
+	if a.LockedUntil.After(now) {
+		return prev, prev, false, nil
+	}
+	if a.LastFailure.Before(now.Add(-window)) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailure = now
+	a.LockedUntil = now.Add(delay(a.Failures))
+	return prev, *a, true, nil
+}
+
+// Release отменяет резерв попытки по ключу key.
+func (s *MemoryStore) Release(ctx context.Context, key string, prev, reserved Attempts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	if a.Failures <= 1 {
+		delete(s.attempts, key)
+		return nil
+	}
+	a.Failures--
+	if a.LockedUntil.Equal(reserved.LockedUntil) {
+		a.LockedUntil = prev.LockedUntil
+	}
+	return nil
+}
+
+// Reset удаляет счётчик ключа key.
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// DeleteExpired удаляет устаревшие счётчики.
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key, a := range s.attempts {
+		if a.LastFailure.Before(now.Add(-window)) && !a.LockedUntil.After(now) {
+			delete(s.attempts, key)
+			n++
+		}
+	}
+	return n, nil
+}
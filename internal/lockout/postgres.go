@@ -0,0 +1,115 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore хранит счётчики попыток входа в таблице login_attempts: они переживают
+// перезапуск и общие для всех экземпляров сервиса.
+type PostgresStore struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewPostgresStore создаёт PostgresStore.
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Get возвращает состояние ключа key.
+func (s *PostgresStore) Get(ctx context.Context, key string) (Attempts, error) {
+	var a Attempts
+	err := s.db.QueryRow(ctx,
+		`SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1`,
+		key,
+	).Scan(&a.Failures, &a.LastFailure, &a.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Attempts{}, nil
+	}
+	return a, err
+}
+
+// Reserve атомарно проверяет и засчитывает попытку по ключу key: строка ключа блокируется
+// до конца транзакции, поэтому параллельные попытки выполняются по очереди.
+func (s *PostgresStore) Reserve(ctx context.Context, key string, now time.Time, window time.Duration, delay func(failures int) time.Duration) (Attempts, Attempts, bool, error) {
+	var prev, a Attempts
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return prev, a, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO login_attempts (key, failures, last_failure_at, locked_until)
+		 VALUES ($1, 0, $2, $2)
+		 ON CONFLICT (key) DO NOTHING`,
+		key, time.Unix(0, 0),
+	)
+	if err != nil {
+		return prev, a, false, err
+	}
+	err = tx.QueryRow(ctx,
+		`SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE`,
+		key,
+	).Scan(&prev.Failures, &prev.LastFailure, &prev.LockedUntil)
+	if err != nil {
+		return prev, a, false, err
+	}
+	if prev.LockedUntil.After(now) {
+		return prev, prev, false, tx.Commit(ctx)
+	}
+
+	a = prev
+	if a.LastFailure.Before(now.Add(-window)) {
+		a.Failures = 0
+	}
+	a.Failures++
+	err = tx.QueryRow(ctx,
+		`UPDATE login_attempts SET failures = $2, last_failure_at = $3, locked_until = $4
+		 WHERE key = $1
+		 RETURNING last_failure_at, locked_until`,
+		key, a.Failures, now, now.Add(delay(a.Failures)),
+	).Scan(&a.LastFailure, &a.LockedUntil)
+	if err != nil {
+		return prev, a, false, err
+	}
+	return prev, a, true, tx.Commit(ctx)
+}
+
+// Release отменяет резерв попытки по ключу key.
+func (s *PostgresStore) Release(ctx context.Context, key string, prev, reserved Attempts) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE login_attempts
+		 SET failures = failures - 1,
+		     locked_until = CASE WHEN locked_until = $2 THEN $3 ELSE locked_until END
+		 WHERE key = $1`,
+		key, reserved.LockedUntil, prev.LockedUntil,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1 AND failures <= 0`, key)
+	return err
+}
+
+// Reset удаляет счётчик ключа key.
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+// DeleteExpired удаляет устаревшие счётчики.
+func (s *PostgresStore) DeleteExpired(ctx context.Context, now time.Time, window time.Duration) (int64, error) {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM login_attempts WHERE last_failure_at < $1 AND locked_until <= $2`,
+		now.Add(-window), now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Права доступа, которые владелец может выдать на свои файлы другим пользователям и группам.
+const (
+	PermissionRead   = "read"   // Скачивание и просмотр в списке файлов
+	PermissionWrite  = "write"  // Загрузка новых версий
+	PermissionDelete = "delete" // Удаление
+)
+
+// AllPermissions — все допустимые права доступа.
+var AllPermissions = []string{PermissionRead, PermissionWrite, PermissionDelete}
+
+// Group — именованная группа пользователей, которой можно выдавать доступ к файлам.
+// Составом группы управляет её владелец (OwnerUID); Members — логины участников.
+type Group struct {
+	ID        int64     `json:"id"`         // Идентификатор группы
+	Name      string    `json:"name"`       // Уникальное имя группы
+	OwnerUID  int64     `json:"owner_uid"`  // Владелец группы
+	Members   []string  `json:"members"`    // Логины участников
+	CreatedAt time.Time `json:"created_at"` // Время создания
+}
+
+// Grant — запись списка доступа (ACL): владелец OwnerUID выдаёт право Permission на файл Name
+// (или на все файлы с префиксом, если Name заканчивается на «/») пользователю или группе.
+// Задано ровно одно из GranteeUID и GroupID.
+type Grant struct {
+	ID           int64     `json:"id"`                    // Идентификатор записи
+	OwnerUID     int64     `json:"owner_uid"`             // Владелец файлов
+	Name         string    `json:"name"`                  // Имя файла или префикс с «/» на конце
+	GranteeUID   *int64    `json:"grantee_uid,omitempty"` // Пользователь, получивший доступ
+	GranteeLogin string    `json:"user,omitempty"`        // Логин пользователя, получившего доступ
+	GroupID      *int64    `json:"group_id,omitempty"`    // Группа, получившая доступ
+	GroupName    string    `json:"group,omitempty"`       // Имя группы, получившей доступ
+	Permission   string    `json:"permission"`            // Право: read, write или delete
+	CreatedAt    time.Time `json:"created_at"`            // Время выдачи
+}
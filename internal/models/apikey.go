@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Области действия (scopes) API-ключей.
+const (
+	ScopeAssetsRead   = "assets:read"   // Скачивание и просмотр списка файлов
+	ScopeAssetsWrite  = "assets:write"  // Загрузка файлов
+	ScopeAssetsDelete = "assets:delete" // Удаление файлов
+)
+
+// AllScopes — все допустимые области действия API-ключей.
+var AllScopes = []string{ScopeAssetsRead, ScopeAssetsWrite, ScopeAssetsDelete}
+
+// APIKey представляет долгоживущий ключ доступа для машинных клиентов (CI, сборочные агенты).
+// Сам ключ показывается только при создании; в БД хранится его хеш (KeyHash).
+// Prefix — начало ключа, по которому владелец может узнать его в списке.
+// Scopes ограничивают разрешённые операции, NamePrefixes (если заданы) — имена доступных файлов.
+type APIKey struct {
+	ID           int64      `json:"id"`                     // Идентификатор ключа
+	UID          int64      `json:"uid"`                    // Владелец ключа
+	Name         string     `json:"name"`                   // Название ключа
+	Prefix       string     `json:"prefix"`                 // Начало ключа для опознания
+	KeyHash      string     `json:"-"`                      // SHA-256 ключа (не выводится в JSON)
+	Scopes       []string   `json:"scopes"`                 // Разрешённые области действия
+	NamePrefixes []string   `json:"name_prefixes"`          // Разрешённые префиксы имён файлов (пусто — любые)
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`   // Срок действия (nil — бессрочный)
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"` // Время последнего использования
+	CreatedAt    time.Time  `json:"created_at"`             // Время создания
+}
+
+// HasScope проверяет, что ключу разрешена область действия scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsName проверяет, что имя файла подпадает под ограничения ключа по префиксам.
+func (k *APIKey) AllowsName(name string) bool {
+	if len(k.NamePrefixes) == 0 {
+		return true
+	}
+	for _, p := range k.NamePrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
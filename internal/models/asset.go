@@ -4,12 +4,59 @@ import "time"
 
 // Asset представляет файл или данные, загруженные пользователем.
 // Поле Name хранит имя файла (или идентификатор ресурса).
-// Поле UID — идентификатор пользователя, загрузившего файл.
-// Поле Data содержит сырые данные файла в виде среза байт (не сериализуется в JSON).
-// Поле CreatedAt указывает дату и время создания записи.
+// Поле UID — идентификатор владельца файла (в списке могут быть и чужие файлы, доступные по ACL).
+// Поле Version — номер версии: каждая загрузка под тем же именем создаёт новую версию.
+// Поле StorageKey — ключ объекта в хранилище BlobStore, где лежит содержимое файла (не сериализуется в JSON).
+// Поле Size — размер содержимого в байтах.
+// Поле SHA256 — хеш содержимого (hex): по нему содержимое дедуплицируется в хранилище,
+// он же используется как ETag при скачивании.
+// Поле ContentType — MIME-тип содержимого (из заголовка Content-Type или определённый по содержимому).
+// Поле Metadata — произвольные пары ключ/значение, переданные в заголовках X-Asset-Meta-*.
+// Поле CreatedAt указывает дату и время создания версии.
+// Поле UpdatedAt — время, когда версия стала текущей (загрузка или откат).
 type Asset struct {
-	Name      string    `json:"name"`       // Имя файла или ресурса
-	UID       int64     `json:"uid"`        // Идентификатор пользователя
-	Data      []byte    `json:"-"`          // Сырые данные файла (не выводится в JSON)
-	CreatedAt time.Time `json:"created_at"` // Дата и время загрузки
+	Name        string            `json:"name"`         // Имя файла или ресурса
+	UID         int64             `json:"uid"`          // Идентификатор пользователя
+	Version     int               `json:"version"`      // Номер версии
+	StorageKey  string            `json:"-"`            // Ключ содержимого в BlobStore (не выводится в JSON)
+	Size        int64             `json:"size"`         // Размер файла в байтах
+	SHA256      string            `json:"sha256"`       // SHA-256 содержимого в hex
+	ContentType string            `json:"content_type"` // MIME-тип содержимого
+	Metadata    map[string]string `json:"metadata"`     // Пользовательские метаданные
+	CreatedAt   time.Time         `json:"created_at"`   // Дата и время загрузки
+	UpdatedAt   time.Time         `json:"updated_at"`   // Дата и время последнего изменения
+}
+
+// Поля сортировки списка файлов.
+const (
+	AssetSortName      = "name"
+	AssetSortSize      = "size"
+	AssetSortCreatedAt = "created_at"
+)
+
+// AssetListOptions описывает фильтры, сортировку и страницу при получении списка файлов.
+// Пустые поля не ограничивают выборку.
+type AssetListOptions struct {
+	Owner           int64      // Только файлы этого владельца (0 — свои и доступные по ACL)
+	Prefix          string     // Имя начинается с префикса
+	Delimiter       string     // Разделитель уровней имени: вложенные «папки» сворачиваются в общие префиксы
+	Glob            string     // Имя соответствует шаблону (* — любая последовательность, ? — один символ)
+	ContentType     string     // Точный MIME-тип или семейство вида "image/*"
+	CreatedAfter    *time.Time // Версия создана не раньше
+	CreatedBefore   *time.Time // Версия создана раньше
+	MinSize         *int64     // Размер не меньше, байт
+	MaxSize         *int64     // Размер не больше, байт
+	AllowedPrefixes []string   // Ограничение API-ключа: имя начинается с одного из префиксов
+	Sort            string     // Поле сортировки: name, size или created_at
+	Desc            bool       // Сортировка по убыванию
+	Limit           int        // Размер страницы
+	Cursor          string     // Непрозрачный курсор следующей страницы из предыдущего ответа
+}
+
+// AssetPage — страница списка файлов. При листинге с разделителем CommonPrefixes содержит
+// префиксы вложенных «папок» (как в S3), а Assets — только файлы текущего уровня.
+type AssetPage struct {
+	Assets         []Asset  `json:"assets"`                    // Файлы страницы
+	CommonPrefixes []string `json:"common_prefixes,omitempty"` // Общие префиксы вложенных уровней
+	NextCursor     string   `json:"next_cursor,omitempty"`     // Курсор следующей страницы
 }
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Действия, записываемые в журнал аудита.
+const (
+	AuditLogin                = "auth.login"                  // Вход (по паролю, второй шаг с кодом, через OIDC)
+	AuditLogout               = "auth.logout"                 // Выход
+	AuditPasswordChange       = "auth.password_change"        // Смена пароля пользователем
+	AuditPasswordResetRequest = "auth.password_reset_request" // Запрос токена сброса пароля
+	AuditPasswordReset        = "auth.password_reset"         // Новый пароль по токену сброса
+	AuditMFADisable           = "mfa.disable"                 // Отключение второго фактора пользователем
+	AuditAPIKeyCreate         = "api_key.create"              // Выпуск API-ключа
+	AuditAssetUpload          = "asset.upload"                // Загрузка файла (в том числе возобновляемая и по подписанной ссылке)
+	AuditAssetDownload        = "asset.download"              // Скачивание файла (в том числе по подписанной и публичной ссылке)
+	AuditAssetDelete          = "asset.delete"                // Удаление файла или «папки»
+	AuditAssetRestore         = "asset.restore"               // Откат файла к прежней версии
+	AuditAssetPrune           = "asset.prune"                 // Удаление прежних версий файла
+	AuditAssetMove            = "asset.move"                  // Перенос (переименование) файла или «папки»
+	AuditPresignCreate        = "presign.create"              // Выпуск подписанной ссылки
+	AuditShareCreate          = "share.create"                // Выпуск публичной ссылки
+	AuditShareRevoke          = "share.revoke"                // Отзыв публичной ссылки
+	AuditAdminUser            = "admin.user"                  // Управление пользователями /api/admin/users
+	AuditAdminQuota           = "admin.quota"                 // Квоты ролей /api/admin/quotas
+	AuditAdminMFAPolicy       = "admin.mfa_policy"            // Политика второго фактора /api/admin/mfa-policy
+	AuditAdminCert            = "admin.client_cert"           // Сопоставления клиентских сертификатов /api/admin/client-certs
+	AuditAdminAudit           = "admin.audit"                 // Просмотр и проверка журнала аудита
+)
+
+// Результаты действий в журнале аудита.
+const (
+	AuditSuccess = "success" // Действие выполнено
+	AuditDenied  = "denied"  // Отказ в доступе: нет или неверны учётные данные, нет прав, слишком много попыток
+	AuditFailure = "failure" // Прочие ошибки: неверный запрос, файл не найден, сбой сервиса
+)
+
+// AuditEvent — запись журнала аудита: кто (UID), что (Action и Method), с каким объектом
+// (Target и его владелец Owner), откуда (IP) и с каким результатом (Result и HTTP-статус).
+// Записи связаны в цепочку: Hash вычисляется от содержимого записи и хеша предыдущей (PrevHash).
+type AuditEvent struct {
+	ID       int64             `json:"id"`
+	Time     time.Time         `json:"time"`
+	UID      *int64            `json:"uid,omitempty"`     // Вызывающий; nil, если он не установлен (неудачный вход, подписанная ссылка)
+	Action   string            `json:"action"`            // Одно из Audit*
+	Method   string            `json:"method"`            // HTTP-метод запроса
+	Target   string            `json:"target,omitempty"`  // Имя файла или путь запроса
+	Owner    *int64            `json:"owner,omitempty"`   // Владелец файла
+	IP       string            `json:"ip"`                // Адрес клиента
+	Result   string            `json:"result"`            // success, denied или failure
+	Status   int               `json:"status"`            // HTTP-статус ответа
+	Details  map[string]string `json:"details,omitempty"` // Подробности: способ входа, логин, версия файла и т. п.
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+// AuditFilter — условия выборки журнала аудита; нулевые поля не ограничивают выборку.
+type AuditFilter struct {
+	UID      int64      // Вызывающий
+	Action   string     // Действие
+	Since    *time.Time // Не раньше (включительно)
+	Until    *time.Time // Раньше (не включительно)
+	BeforeID int64      // Записи с меньшим идентификатором (курсор следующей страницы)
+	Limit    int        // Размер страницы
+}
+
+// AuditPage — страница журнала аудита, от новых записей к старым.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"` // Курсор следующей страницы; пусто — страница последняя
+}
+
+// AuditVerification — результат проверки цепочки хешей журнала аудита.
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`             // Число проверенных записей
+	BrokenAt int64 `json:"broken_at,omitempty"` // Первая запись, хеш которой не сходится
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Поля клиентского сертификата mTLS, по которым он сопоставляется пользователю.
+const (
+	CertMatchSubject = "subject" // Distinguished Name субъекта (RFC 2253), например CN=ci,O=Example
+	CertMatchDNS     = "dns"     // DNS-имя из Subject Alternative Name
+	CertMatchEmail   = "email"   // Адрес e-mail из Subject Alternative Name
+	CertMatchURI     = "uri"     // URI из Subject Alternative Name (например, SPIFFE ID)
+)
+
+// ClientCertMapping сопоставляет клиентский сертификат mTLS пользователю: запросы
+// с сертификатом, у которого поле Match равно Value, выполняются от имени UID.
+// Как и у API-ключа, Scopes ограничивают разрешённые операции, NamePrefixes (если заданы) —
+// имена доступных файлов.
+type ClientCertMapping struct {
+	ID           int64      `json:"id"`                     // Идентификатор сопоставления
+	Match        string     `json:"match"`                  // Поле сертификата: subject, dns, email или uri
+	Value        string     `json:"value"`                  // Значение поля
+	UID          int64      `json:"uid"`                    // Пользователь
+	Scopes       []string   `json:"scopes"`                 // Разрешённые области действия
+	NamePrefixes []string   `json:"name_prefixes"`          // Разрешённые префиксы имён файлов (пусто — любые)
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"` // Время последнего использования
+	CreatedAt    time.Time  `json:"created_at"`             // Время создания
+}
+
+// HasScope проверяет, что сопоставлению разрешена область действия scope.
+func (m *ClientCertMapping) HasScope(scope string) bool {
+	for _, s := range m.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsName проверяет, что имя файла подпадает под ограничения сопоставления по префиксам.
+func (m *ClientCertMapping) AllowsName(name string) bool {
+	if len(m.NamePrefixes) == 0 {
+		return true
+	}
+	for _, p := range m.NamePrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// JWTSigningKey — ключ подписи токенов доступа в формате JWT.
+// Поле PrivateKey — закрытый ключ в PKCS#8 DER; он секретен и не выводится в JSON.
+type JWTSigningKey struct {
+	Kid        string    // Идентификатор ключа (заголовок kid токена)
+	Alg        string    // Алгоритм подписи: EdDSA или ES256
+	PrivateKey []byte    // Закрытый ключ (PKCS#8 DER)
+	CreatedAt  time.Time // Время создания ключа
+}
+
+// RevokedSession — запись списка отозванных сессий: токены доступа в формате JWT
+// с этим идентификатором сессии (утверждение sid) больше не принимаются.
+type RevokedSession struct {
+	SessionID string    `json:"sid"`        // Публичный идентификатор сессии
+	RevokedAt time.Time `json:"revoked_at"` // Время отзыва
+}
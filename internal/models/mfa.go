@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// UserMFA — второй фактор пользователя (TOTP, RFC 6238).
+// Пока ConfirmedAt == nil, подключение не завершено и при входе код не запрашивается.
+type UserMFA struct {
+	UID          int64      // Идентификатор пользователя
+	Secret       string     // Секрет TOTP в base32
+	ConfirmedAt  *time.Time // Время подтверждения первым кодом
+	LastUsedStep int64      // Шаг последнего принятого кода (защита от повторного использования)
+	CreatedAt    time.Time  // Время начала подключения
+}
+
+// MFAStatus — состояние второго фактора пользователя для API.
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`                // Второй фактор подключён и подтверждён
+	ConfirmedAt       *time.Time `json:"confirmed_at,omitempty"` // Время подключения
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`    // Неиспользованных кодов восстановления
+	Required          bool       `json:"required"`               // Роль пользователя требует второй фактор
+}
+
+// MFAEnrollment — данные для подключения TOTP в приложении-аутентификаторе.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`           // Секрет в base32 (для ручного ввода)
+	ProvisioningURI string `json:"provisioning_uri"` // URI otpauth:// (для QR-кода)
+}
+
+// MFAChallenge — незавершённый вход: пароль проверен, ожидается код второго фактора.
+type MFAChallenge struct {
+	UID         int64     // Идентификатор пользователя
+	DeviceLabel string    // Метка устройства, указанная при входе
+	Attempts    int       // Число неверных кодов
+	ExpiresAt   time.Time // Срок действия
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// OIDCLoginState — начатый вход через провайдера OpenID Connect: пользователь перенаправлен
+// на страницу входа провайдера, ожидается обратный вызов с кодом авторизации.
+type OIDCLoginState struct {
+	CodeVerifier string    // Секретный code verifier PKCE
+	Nonce        string    // Значение, которое провайдер должен вернуть в ID-токене
+	DeviceLabel  string    // Метка устройства для создаваемой сессии
+	ExpiresAt    time.Time // Срок, до которого нужно завершить вход
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PresignedURL описывает подписанную ссылку на файл: она разрешает без токена выполнить
+// один метод (GET — скачивание, PUT — загрузка новой версии) над файлом Name владельца Owner
+// до ExpiresAt. Все поля входят в параметры ссылки и защищены подписью HMAC.
+// UID — пользователь, выпустивший ссылку; если он не владелец, при каждом использовании
+// проверяется, что право доступа к файлу у него всё ещё есть.
+// MaxUses (если больше нуля) ограничивает число использований, IP (если задан) — адрес клиента.
+type PresignedURL struct {
+	ID        string    `json:"id"`                 // Случайный идентификатор ссылки
+	Method    string    `json:"method"`             // GET или PUT
+	Name      string    `json:"name"`               // Имя файла
+	UID       int64     `json:"uid"`                // Пользователь, выпустивший ссылку
+	Owner     int64     `json:"owner"`              // Владелец файла
+	ExpiresAt time.Time `json:"expires_at"`         // Срок действия
+	MaxUses   int       `json:"max_uses,omitempty"` // Максимальное число использований (0 — без ограничения)
+	IP        string    `json:"ip,omitempty"`       // Разрешённый IP-адрес клиента
+}
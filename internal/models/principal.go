@@ -0,0 +1,56 @@
+package models
+
+// Principal описывает того, от чьего имени выполняется запрос.
+// Пользователь может аутентифицироваться токеном сессии (Session != nil),
+// API-ключом (APIKey != nil) или клиентским сертификатом mTLS (ClientCert != nil);
+// права сессии не ограничены областями действия.
+// Роль readonly, независимо от способа входа, разрешает только чтение; так же ограничены
+// запросы без второго фактора, если роль его требует (MFAPending).
+type Principal struct {
+	UID        int64              // Идентификатор пользователя
+	Role       string             // Роль пользователя
+	Session    *Session           // Сессия, если запрос выполнен с токеном сессии (для JWT — только PublicID и UID)
+	APIKey     *APIKey            // API-ключ, если запрос выполнен с ключом
+	ClientCert *ClientCertMapping // Сопоставление сертификата, если запрос выполнен с сертификатом mTLS
+	MFAPending bool               // Роль требует второй фактор, а запрос выполнен без него
+}
+
+// HasScope проверяет, что запросу разрешена область действия scope.
+func (p *Principal) HasScope(scope string) bool {
+	if (p.Role == RoleReadOnly || p.MFAPending) && scope != ScopeAssetsRead {
+		return false
+	}
+	if p.APIKey != nil {
+		return p.APIKey.HasScope(scope)
+	}
+	if p.ClientCert != nil {
+		return p.ClientCert.HasScope(scope)
+	}
+	return true
+}
+
+// CanAccess проверяет, что запросу разрешена область действия scope для файла name.
+func (p *Principal) CanAccess(scope, name string) bool {
+	if !p.HasScope(scope) {
+		return false
+	}
+	if p.APIKey != nil {
+		return p.APIKey.AllowsName(name)
+	}
+	if p.ClientCert != nil {
+		return p.ClientCert.AllowsName(name)
+	}
+	return true
+}
+
+// NamePrefixes возвращает префиксы имён файлов, которыми ограничен запрос
+// (API-ключом или сопоставлением сертификата); nil — ограничений нет.
+func (p *Principal) NamePrefixes() []string {
+	switch {
+	case p.APIKey != nil:
+		return p.APIKey.NamePrefixes
+	case p.ClientCert != nil:
+		return p.ClientCert.NamePrefixes
+	}
+	return nil
+}
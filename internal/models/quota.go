@@ -0,0 +1,28 @@
+package models
+
+// Quota — ограничения на хранилище пользователя. Значение nil означает отсутствие ограничения
+// (в персональной квоте — что действует ограничение роли).
+type Quota struct {
+	MaxBytes    *int64 `json:"max_bytes"`     // Суммарный размер всех версий всех файлов, байт
+	MaxObjects  *int64 `json:"max_objects"`   // Число файлов
+	MaxFileSize *int64 `json:"max_file_size"` // Размер одного файла, байт
+}
+
+// RoleQuota — квота, действующая для всех пользователей роли, если у них нет персональной.
+type RoleQuota struct {
+	Role string `json:"role"` // Роль
+	Quota
+}
+
+// Usage — текущее потребление хранилища пользователем и действующая для него квота
+// (персональная с подстановкой ограничений роли).
+type Usage struct {
+	UID     int64 `json:"uid"`     // Идентификатор пользователя
+	Bytes   int64 `json:"bytes"`   // Суммарный размер всех версий всех файлов, байт
+	Objects int64 `json:"objects"` // Число файлов
+
+	ReservedBytes   int64 `json:"reserved_bytes"`   // Объявленный размер незавершённых загрузок, байт
+	ReservedObjects int64 `json:"reserved_objects"` // Число новых файлов, которые создадут незавершённые загрузки
+
+	Quota Quota `json:"quota"` // Действующая квота
+}
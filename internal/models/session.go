@@ -3,13 +3,23 @@ package models
 import "time"
 
 // Session представляет пользовательскую сессию.
-// Поле ID — уникальный идентификатор сессии (например, session token).
+// Поле ID — уникальный идентификатор сессии (session token); он секретен и не выводится в JSON.
+// Поле PublicID — несекретный идентификатор сессии для просмотра и отзыва через API.
 // Поле UID — идентификатор пользователя, которому принадлежит сессия.
+// Поле DeviceLabel — метка устройства, указанная при входе (или User-Agent клиента).
 // Поле IPAddress содержит IP-адрес, с которого пользователь прошёл авторизацию.
-// Поле CreatedAt фиксирует время создания сессии.
+// Поле CreatedAt фиксирует время создания сессии, LastUsedAt — время последнего запроса с ней.
+// Поле MFAVerified — при входе был подтверждён второй фактор.
+// Поле RefreshExpiresAt — срок действия текущего refresh-токена сессии.
 type Session struct {
-	ID        string    `json:"id"`         // Уникальный идентификатор сессии
-	UID       int64     `json:"uid"`        // Идентификатор пользователя
-	IPAddress string    `json:"ip_address"` // IP-адрес клиента
-	CreatedAt time.Time `json:"created_at"` // Время создания сессии
+	ID          string    `json:"-"`            // Уникальный идентификатор сессии (токен)
+	PublicID    string    `json:"id"`           // Публичный идентификатор сессии
+	UID         int64     `json:"uid"`          // Идентификатор пользователя
+	DeviceLabel string    `json:"device"`       // Метка устройства
+	IPAddress   string    `json:"ip_address"`   // IP-адрес клиента
+	CreatedAt   time.Time `json:"created_at"`   // Время создания сессии
+	LastUsedAt  time.Time `json:"last_used_at"` // Время последнего использования сессии
+	MFAVerified bool      `json:"mfa_verified"` // Второй фактор подтверждён
+
+	RefreshExpiresAt time.Time `json:"-"` // Срок действия refresh-токена
 }
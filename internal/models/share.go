@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ShareLink — постоянная публичная ссылка /s/{slug} на файл или на все файлы с префиксом
+// (Name с «/» на конце). Ссылку выпускает пользователь UID на свой файл или на чужой файл
+// владельца Owner, доступный ему по ACL. Ссылку можно защитить паролем, ограничить сроком
+// действия и числом скачиваний, а также отозвать; AccessCount и DownloadCount показывают,
+// сколько раз ею воспользовались.
+type ShareLink struct {
+	Slug           string     `json:"slug"`                       // Короткий идентификатор ссылки
+	UID            int64      `json:"uid"`                        // Пользователь, выпустивший ссылку
+	Owner          int64      `json:"owner"`                      // Владелец файлов
+	Name           string     `json:"name"`                       // Имя файла или префикс с «/» на конце
+	PasswordHash   string     `json:"-"`                          // Хеш пароля (пусто — без пароля)
+	HasPassword    bool       `json:"has_password"`               // Ссылка защищена паролем
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`       // Срок действия (nil — бессрочная)
+	MaxDownloads   *int       `json:"max_downloads,omitempty"`    // Ограничение числа скачиваний
+	DownloadCount  int        `json:"download_count"`             // Число скачиваний
+	AccessCount    int        `json:"access_count"`               // Число всех обращений
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"` // Время последнего обращения
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`       // Время отзыва
+	CreatedAt      time.Time  `json:"created_at"`                 // Время создания
+}
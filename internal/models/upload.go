@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Upload представляет незавершённую возобновляемую загрузку файла.
+// Клиент создаёт загрузку с известным итоговым размером (Length), затем по частям
+// дописывает данные, начиная с текущего смещения (Offset). Когда Offset достигает Length,
+// загрузка собирается в обычный Asset с именем Name.
+type Upload struct {
+	ID          string            `json:"id"`                     // Идентификатор загрузки
+	UID         int64             `json:"-"`                      // Идентификатор владельца
+	Name        string            `json:"name"`                   // Имя будущего файла
+	Length      int64             `json:"length"`                 // Итоговый размер файла в байтах
+	Offset      int64             `json:"offset"`                 // Количество уже принятых байт
+	ContentType string            `json:"content_type,omitempty"` // MIME-тип файла (пусто — определить по содержимому)
+	Metadata    map[string]string `json:"metadata,omitempty"`     // Пользовательские метаданные файла
+	CreatedAt   time.Time         `json:"created_at"`             // Время создания загрузки
+	UpdatedAt   time.Time         `json:"updated_at"`             // Время приёма последней части
+	ExpiresAt   time.Time         `json:"expires_at"`             // После этого времени загрузка удаляется сборщиком мусора
+}
+
+// UploadChunk описывает принятую часть загрузки, сохранённую в BlobStore отдельным объектом.
+type UploadChunk struct {
+	UploadID   string // Идентификатор загрузки
+	Offset     int64  // Смещение части в итоговом файле
+	Size       int64  // Размер части в байтах
+	StorageKey string // Ключ части в BlobStore
+}
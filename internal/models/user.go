@@ -2,14 +2,28 @@ package models
 
 import "time"
 
+// Роли пользователей.
+const (
+	RoleAdmin    = "admin"    // Администратор: всё, что может обычный пользователь, и API администрирования
+	RoleUser     = "user"     // Обычный пользователь
+	RoleReadOnly = "readonly" // Только чтение: скачивание и просмотр списков файлов
+)
+
+// AllRoles — все допустимые роли пользователей.
+var AllRoles = []string{RoleAdmin, RoleUser, RoleReadOnly}
+
 // User представляет пользователя системы.
 // ID — уникальный идентификатор пользователя.
 // Login — логин пользователя.
 // PasswordHash — хеш пароля (не выводится в JSON, чтобы не раскрывать пароль).
+// Role — роль пользователя (admin, user или readonly).
+// DisabledAt — время блокировки учётной записи администратором (nil — активна).
 // CreatedAt — время создания записи о пользователе.
 type User struct {
-	ID           int64     `json:"id"`         // Уникальный идентификатор пользователя
-	Login        string    `json:"login"`      // Логин пользователя
-	PasswordHash string    `json:"-"`          // Хеш пароля (не сериализуется в JSON)
-	CreatedAt    time.Time `json:"created_at"` // Время создания пользователя
+	ID           int64      `json:"id"`                    // Уникальный идентификатор пользователя
+	Login        string     `json:"login"`                 // Логин пользователя
+	PasswordHash string     `json:"-"`                     // Хеш пароля (не сериализуется в JSON)
+	Role         string     `json:"role"`                  // Роль пользователя
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"` // Время блокировки
+	CreatedAt    time.Time  `json:"created_at"`            // Время создания пользователя
 }
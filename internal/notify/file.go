@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileNotifier дописывает уведомления в файл, по одному JSON-объекту на строку.
+// Удобен для локального тестирования: сообщения можно прочитать из файла, не настраивая почту.
+type FileNotifier struct {
+	path string     // Путь к файлу уведомлений
+	mu   sync.Mutex // Сериализует запись в файл
+}
+
+// fileRecord — строка файла уведомлений.
+type fileRecord struct {
+	Time    time.Time `json:"time"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+}
+
+// NewFileNotifier создаёт FileNotifier, пишущий в файл path (каталог создаётся при необходимости).
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		return nil, errors.New("notifier file path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create notifier dir: %w", err)
+	}
+	return &FileNotifier{path: path}, nil
+}
+
+// Notify дописывает сообщение в конец файла. Файл доступен только владельцу процесса,
+// так как сообщения могут содержать секреты.
+func (n *FileNotifier) Notify(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(fileRecord{Time: time.Now().UTC(), To: msg.To, Subject: msg.Subject, Body: msg.Body})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// LogNotifier пишет уведомления в журнал сервера. Подходит только для локальной разработки:
+// секреты из сообщений (например, токены сброса пароля) попадают в журнал.
+type LogNotifier struct{}
+
+// NewLogNotifier создаёт LogNotifier.
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Notify записывает сообщение в журнал.
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log.Printf("[INFO] Notification to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
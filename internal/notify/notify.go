@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"go-asset-service/internal/config"
+)
+
+// Message — уведомление пользователю (например, письмо со ссылкой для сброса пароля).
+type Message struct {
+	To      string // Получатель (логин пользователя)
+	Subject string // Тема
+	Body    string // Текст
+}
+
+// Notifier доставляет уведомления пользователям. Реализации для локальной разработки
+// пишут сообщения в журнал сервера или в файл; в боевой среде сюда подключается
+// почтовый сервис или другой канал доставки.
+type Notifier interface {
+	// Notify отправляет сообщение msg.
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NewNotifier создаёт канал уведомлений в соответствии с параметром NOTIFIER из конфигурации.
+func NewNotifier(cfg *config.Config) (Notifier, error) {
+	switch cfg.Notifier {
+	case "log", "":
+		return NewLogNotifier(), nil
+	case "file":
+		return NewFileNotifier(cfg.NotifierFilePath)
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", cfg.Notifier)
+	}
+}
@@ -0,0 +1,412 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-asset-service/pkg/utils"
+)
+
+const (
+	// jwksRefreshInterval — не чаще этого интервала ключи провайдера перечитываются из-за
+	// неизвестного kid (провайдер сменил ключ подписи).
+	jwksRefreshInterval = time.Minute
+	// jwksMaxAge — через это время ключи провайдера перечитываются в любом случае.
+	jwksMaxAge = time.Hour
+	// clockSkew — допустимое расхождение часов сервиса и провайдера при проверке сроков токена.
+	clockSkew = time.Minute
+	// maxResponseSize — ограничение размера ответов провайдера.
+	maxResponseSize = 1 << 20
+)
+
+var (
+	// ErrInvalidIDToken возвращается, если ID-токен не прошёл проверку подписи или утверждений.
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrExchangeFailed возвращается, если провайдер отказал в обмене кода авторизации на токены.
+	ErrExchangeFailed = errors.New("authorization code exchange failed")
+)
+
+// Options содержит параметры клиента провайдера OpenID Connect.
+type Options struct {
+	IssuerURL    string       // Идентификатор провайдера (issuer); метаданные читаются из /.well-known/openid-configuration
+	ClientID     string       // Идентификатор клиента, выданный провайдером
+	ClientSecret string       // Секрет клиента; пустой — публичный клиент (только PKCE)
+	RedirectURL  string       // Адрес возврата после входа (эндпоинт callback этого сервиса)
+	Scopes       []string     // Запрашиваемые области; openid добавляется всегда
+	HTTPClient   *http.Client // HTTP-клиент для запросов к провайдеру (по умолчанию — с таймаутом 10 с)
+}
+
+// Provider — клиент провайдера OpenID Connect для потока authorization code с PKCE (RFC 7636):
+// формирует адрес входа, обменивает код на токены и проверяет ID-токен по ключам провайдера (JWKS).
+// Метаданные и ключи читаются при первом обращении и кешируются.
+type Provider struct {
+	opts   Options
+	client *http.Client
+
+	mu         sync.Mutex
+	meta       *metadata                   // Метаданные провайдера
+	keys       map[string]crypto.PublicKey // Ключи подписи по kid
+	keysLoaded time.Time                   // Время последнего чтения ключей
+}
+
+// metadata — нужная часть документа /.well-known/openid-configuration.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims — проверенные утверждения ID-токена.
+type Claims struct {
+	Issuer  string                 // iss
+	Subject string                 // sub — постоянный идентификатор пользователя у провайдера
+	raw     map[string]interface{} // Все утверждения токена
+}
+
+// String возвращает строковое утверждение name (пустую строку, если его нет).
+func (c *Claims) String(name string) string {
+	s, _ := c.raw[name].(string)
+	return s
+}
+
+// Bool возвращает логическое утверждение name (например, email_verified). Некоторые
+// провайдеры передают его строкой "true"; отсутствующее утверждение считается ложным.
+func (c *Claims) Bool(name string) bool {
+	switch v := c.raw[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// Strings возвращает утверждение name, которое может быть строкой или массивом строк
+// (например, groups или amr).
+func (c *Claims) Strings(name string) []string {
+	switch v := c.raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// NewProvider создаёт клиент провайдера и проверяет параметры. Сеть при этом не используется:
+// метаданные читаются при первом входе, так что сервис запускается и при недоступном провайдере.
+func NewProvider(opts Options) (*Provider, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, errors.New("oidc issuer, client id and redirect url are required")
+	}
+	opts.IssuerURL = strings.TrimSuffix(opts.IssuerURL, "/")
+	if u, err := url.Parse(opts.IssuerURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("oidc issuer must be an absolute URL, got %q", opts.IssuerURL)
+	}
+	hasOpenID := false
+	for _, s := range opts.Scopes {
+		hasOpenID = hasOpenID || s == "openid"
+	}
+	if !hasOpenID {
+		opts.Scopes = append([]string{"openid"}, opts.Scopes...)
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{opts: opts, client: client}, nil
+}
+
+// Issuer возвращает идентификатор провайдера.
+func (p *Provider) Issuer() string {
+	return p.opts.IssuerURL
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера. state защищает обратный вызов от
+// подделки, nonce связывает ID-токен с этим входом, codeChallenge — PKCE S256 от секретного
+// code verifier, который предъявляется при обмене кода.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.opts.ClientID)
+	q.Set("redirect_uri", p.opts.RedirectURL)
+	q.Set("scope", strings.Join(p.opts.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange обменивает код авторизации на токены и возвращает ID-токен (без проверки).
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.opts.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.opts.ClientSecret == "" {
+		form.Set("client_id", p.opts.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opts.ClientSecret != "" {
+		// client_secret_basic: идентификатор и секрет кодируются как application/x-www-form-urlencoded (RFC 6749, 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+	}
+
+	var resp struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	status, err := p.doJSON(req, &resp)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || resp.IDToken == "" {
+		return "", fmt.Errorf("%w: status=%d error=%q", ErrExchangeFailed, status, resp.Error)
+	}
+	return resp.IDToken, nil
+}
+
+// VerifyIDToken проверяет подпись ID-токена ключом провайдера и его утверждения: издателя,
+// получателя (client id), срок действия и nonce этого входа.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*Claims, error) {
+	payload, err := utils.VerifyJWT(rawToken, func(h *utils.JWTHeader) (crypto.PublicKey, error) {
+		return p.key(ctx, h.Kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	raw := map[string]interface{}{}
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	c := &Claims{raw: raw}
+	c.Issuer = c.String("iss")
+	c.Subject = c.String("sub")
+
+	now := time.Now()
+	switch {
+	case c.Issuer != p.opts.IssuerURL:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, c.Issuer)
+	case c.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	case !c.hasAudience(p.opts.ClientID):
+		return nil, fmt.Errorf("%w: token is not issued for this client", ErrInvalidIDToken)
+	case !c.timeAfter("exp", now.Add(-clockSkew)):
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidIDToken)
+	case c.hasClaim("nbf") && c.timeAfter("nbf", now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidIDToken)
+	case c.String("nonce") != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return c, nil
+}
+
+// NewCodeVerifier генерирует секретный code verifier PKCE (43 символа base64url).
+func NewCodeVerifier() (string, error) {
+	b, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(b))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// CodeChallengeS256 вычисляет code challenge PKCE по методу S256 для verifier.
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// hasAudience проверяет, что токен выпущен для клиента clientID (aud — строка или массив;
+// при нескольких получателях azp должен указывать на этот клиент).
+func (c *Claims) hasAudience(clientID string) bool {
+	aud := c.Strings("aud")
+	found := false
+	for _, a := range aud {
+		found = found || a == clientID
+	}
+	if !found {
+		return false
+	}
+	if azp := c.String("azp"); len(aud) > 1 && azp != clientID {
+		return false
+	}
+	return true
+}
+
+// hasClaim сообщает, есть ли в токене утверждение name.
+func (c *Claims) hasClaim(name string) bool {
+	_, ok := c.raw[name]
+	return ok
+}
+
+// timeAfter сообщает, что числовое утверждение-время name (секунды Unix) позже t.
+func (c *Claims) timeAfter(name string, t time.Time) bool {
+	n, ok := c.raw[name].(json.Number)
+	if !ok {
+		return false
+	}
+	v, err := n.Float64()
+	if err != nil {
+		return false
+	}
+	return time.Unix(int64(v), 0).After(t)
+}
+
+// metadata возвращает метаданные провайдера, при первом обращении читая их
+// из /.well-known/openid-configuration.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.IssuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta metadata
+	status, err := p.doJSON(req, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: unexpected status %d", status)
+	}
+	// Издатель в метаданных обязан совпадать с настроенным (OpenID Connect Discovery, 4.3)
+	if strings.TrimSuffix(meta.Issuer, "/") != p.opts.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch: %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key возвращает ключ подписи провайдера с идентификатором kid. Если ключ неизвестен
+// (провайдер сменил ключи) или ключи давно не обновлялись, они перечитываются из JWKS.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	since := time.Since(p.keysLoaded)
+	if key, ok := p.lookupKey(kid); ok && since < jwksMaxAge {
+		return key, nil
+	}
+	if p.keys != nil && since < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []utils.JWK `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc jwks: unexpected status %d", status)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		k := &set.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			// Ключи неподдерживаемых типов пропускаем: ими могут подписываться токены других клиентов
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	p.keys = keys
+	p.keysLoaded = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey ищет ключ по kid; токен без kid подходит, только если у провайдера один ключ.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// doJSON выполняет запрос и декодирует JSON-ответ в v. Возвращает HTTP-статус ответа.
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
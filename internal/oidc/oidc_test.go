@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-asset-service/internal/oidc/oidctest"
+)
+
+const testClientID = "asset-service"
+
+func newTestProvider(t *testing.T, clientSecret string) (*oidctest.Issuer, *Provider) {
+	t.Helper()
+	iss := oidctest.NewIssuer(t, testClientID)
+	p, err := NewProvider(Options{
+		IssuerURL:    iss.URL,
+		ClientID:     testClientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  "https://localhost:8443/api/auth/oidc/callback",
+		Scopes:       []string{"profile", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iss, p
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, p := newTestProvider(t, "")
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", "nonce-1", CodeChallengeS256("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"scope":                 "openid profile email",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        CodeChallengeS256("verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExchangeChecksCodeVerifier(t *testing.T) {
+	for _, secret := range []string{"", "s3cret"} {
+		iss, p := newTestProvider(t, secret)
+		ctx := context.Background()
+		verifier, err := NewCodeVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce", CodeChallengeS256(verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Код, перехваченный без code verifier, обменять нельзя
+		_, code := iss.Authorize(t, authURL, iss.Claims("user-1"))
+		other, _ := NewCodeVerifier()
+		if _, err := p.Exchange(ctx, code, other); !errors.Is(err, ErrExchangeFailed) {
+			t.Fatalf("secret=%q: exchange with wrong verifier: err = %v, want ErrExchangeFailed", secret, err)
+		}
+
+		_, code = iss.Authorize(t, authURL, iss.Claims("user-1"))
+		idToken, err := p.Exchange(ctx, code, verifier)
+		if err != nil {
+			t.Fatalf("secret=%q: exchange: %v", secret, err)
+		}
+		if _, err := p.VerifyIDToken(ctx, idToken, "nonce"); err != nil {
+			t.Fatalf("secret=%q: verify: %v", secret, err)
+		}
+		// Код одноразовый
+		if _, err := p.Exchange(ctx, code, verifier); !errors.Is(err, ErrExchangeFailed) {
+			t.Fatalf("secret=%q: second exchange: err = %v, want ErrExchangeFailed", secret, err)
+		}
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	iss, p := newTestProvider(t, "")
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		modify  func(c map[string]interface{})
+		nonce   string
+		wantErr string // Пусто — токен действителен
+	}{
+		{name: "valid", modify: func(c map[string]interface{}) {}},
+		{name: "wrong nonce", modify: func(c map[string]interface{}) {}, nonce: "other", wantErr: "nonce mismatch"},
+		{name: "wrong issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, wantErr: "unexpected issuer"},
+		{name: "wrong audience", modify: func(c map[string]interface{}) { c["aud"] = "other-client" }, wantErr: "not issued for this client"},
+		{name: "several audiences without azp", modify: func(c map[string]interface{}) {
+			c["aud"] = []string{"other-client", testClientID}
+		}, wantErr: "not issued for this client"},
+		{name: "several audiences with other azp", modify: func(c map[string]interface{}) {
+			c["aud"] = []string{"other-client", testClientID}
+			c["azp"] = "other-client"
+		}, wantErr: "not issued for this client"},
+		{name: "several audiences with azp", modify: func(c map[string]interface{}) {
+			c["aud"] = []string{"other-client", testClientID}
+			c["azp"] = testClientID
+		}},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = now.Add(-2 * clockSkew).Unix() }, wantErr: "token expired"},
+		{name: "expired within clock skew", modify: func(c map[string]interface{}) { c["exp"] = now.Add(-clockSkew / 2).Unix() }},
+		{name: "missing exp", modify: func(c map[string]interface{}) { delete(c, "exp") }, wantErr: "token expired"},
+		{name: "not yet valid", modify: func(c map[string]interface{}) { c["nbf"] = now.Add(2 * clockSkew).Unix() }, wantErr: "not yet valid"},
+		{name: "missing subject", modify: func(c map[string]interface{}) { delete(c, "sub") }, wantErr: "missing subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := iss.Claims("user-1")
+			claims["nonce"] = "nonce-1"
+			tt.modify(claims)
+			nonce := tt.nonce
+			if nonce == "" {
+				nonce = "nonce-1"
+			}
+
+			c, err := p.VerifyIDToken(ctx, iss.Sign(t, claims), nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if c.Subject != "user-1" || c.Issuer != iss.URL {
+					t.Fatalf("claims = %+v", c)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignSignature(t *testing.T) {
+	iss, p := newTestProvider(t, "")
+	other := oidctest.NewIssuer(t, testClientID)
+	ctx := context.Background()
+
+	// Токен подписан чужим ключом с тем же kid
+	claims := iss.Claims("user-1")
+	claims["nonce"] = "n"
+	if _, err := p.VerifyIDToken(ctx, other.Sign(t, claims), "n"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestUnknownKidRefreshesJWKS(t *testing.T) {
+	iss, p := newTestProvider(t, "")
+	ctx := context.Background()
+	claims := iss.Claims("user-1")
+	claims["nonce"] = "n"
+
+	if _, err := p.VerifyIDToken(ctx, iss.Sign(t, claims), "n"); err != nil {
+		t.Fatal(err)
+	}
+	if n := iss.JWKSRequests(); n != 1 {
+		t.Fatalf("JWKS requested %d times, want 1", n)
+	}
+
+	// Провайдер сменил ключ сразу после чтения ключей: повторное чтение ограничено
+	// jwksRefreshInterval, чтобы токены с произвольным kid не вызывали запрос на каждый вход
+	iss.RotateKey(t, "key-2")
+	rotated := iss.Sign(t, claims)
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken before refresh interval", err)
+	}
+	if n := iss.JWKSRequests(); n != 1 {
+		t.Fatalf("JWKS requested %d times within refresh interval, want 1", n)
+	}
+
+	// После jwksRefreshInterval неизвестный kid приводит к повторному чтению JWKS
+	p.mu.Lock()
+	p.keysLoaded = time.Now().Add(-jwksRefreshInterval - time.Second)
+	p.mu.Unlock()
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Fatalf("token signed with rotated key: %v", err)
+	}
+	if n := iss.JWKSRequests(); n != 2 {
+		t.Fatalf("JWKS requested %d times, want 2", n)
+	}
+
+	// Известный ключ берётся из кеша
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Fatal(err)
+	}
+	if n := iss.JWKSRequests(); n != 2 {
+		t.Fatalf("JWKS requested %d times for a cached key, want 2", n)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	iss := oidctest.NewIssuer(t, testClientID)
+	p, err := NewProvider(Options{
+		// Тот же сервер, но под другим идентификатором: документ discovery его не подтверждает
+		IssuerURL:   strings.Replace(iss.URL, "127.0.0.1", "localhost", 1),
+		ClientID:    testClientID,
+		RedirectURL: "https://localhost:8443/api/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("err = %v, want issuer mismatch", err)
+	}
+}
+
+func TestClaimsBool(t *testing.T) {
+	c := &Claims{raw: map[string]interface{}{"a": true, "b": "true", "c": false, "d": "yes"}}
+	for name, want := range map[string]bool{"a": true, "b": true, "c": false, "d": false, "missing": false} {
+		if got := c.Bool(name); got != want {
+			t.Errorf("Bool(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
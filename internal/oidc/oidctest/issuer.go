@@ -0,0 +1,196 @@
+// Package oidctest содержит имитацию провайдера OpenID Connect для тестов: документ
+// discovery, ключи JWKS и эндпоинт обмена кода на токены с проверкой PKCE.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go-asset-service/pkg/utils"
+)
+
+// Issuer — провайдер OpenID Connect на httptest.Server. Коды авторизации выдаются методом
+// Authorize вместо страницы входа; ID-токены подписываются текущим ключом (ES256).
+type Issuer struct {
+	URL      string // Идентификатор провайдера (issuer) и адрес сервера
+	ClientID string // Клиент, для которого выпускаются токены
+
+	mu           sync.Mutex
+	keys         map[string]*ecdsa.PrivateKey // Опубликованные ключи по kid
+	signKid      string                       // Ключ, которым подписываются новые токены
+	grants       map[string]grant             // Выданные и ещё не обменянные коды
+	jwksRequests int
+}
+
+// grant — выданный код авторизации.
+type grant struct {
+	challenge string                 // code_challenge из запроса входа
+	claims    map[string]interface{} // Утверждения ID-токена
+}
+
+// NewIssuer запускает провайдера для клиента clientID с одним ключом подписи "key-1".
+// Сервер останавливается по окончании теста.
+func NewIssuer(t testing.TB, clientID string) *Issuer {
+	t.Helper()
+	iss := &Issuer{ClientID: clientID, keys: map[string]*ecdsa.PrivateKey{}, grants: map[string]grant{}}
+	iss.RotateKey(t, "key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("/jwks", iss.jwks)
+	mux.HandleFunc("/token", iss.token)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	iss.URL = srv.URL
+	return iss
+}
+
+// RotateKey добавляет ключ kid в JWKS; следующие токены подписываются им.
+func (iss *Issuer) RotateKey(t testing.TB, kid string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.keys[kid] = key
+	iss.signKid = kid
+}
+
+// JWKSRequests возвращает, сколько раз запрашивались ключи провайдера.
+func (iss *Issuer) JWKSRequests() int {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	return iss.jwksRequests
+}
+
+// Claims возвращает утверждения действующего ID-токена пользователя sub для клиента.
+func (iss *Issuer) Claims(sub string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss": iss.URL,
+		"sub": sub,
+		"aud": iss.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+// Sign подписывает утверждения текущим ключом.
+func (iss *Issuer) Sign(t testing.TB, claims map[string]interface{}) string {
+	t.Helper()
+	iss.mu.Lock()
+	kid, key := iss.signKid, iss.keys[iss.signKid]
+	iss.mu.Unlock()
+	token, err := utils.SignJWT(kid, claims, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Authorize имитирует вход пользователя на странице провайдера по адресу authURL,
+// полученному от клиента: запоминает code_challenge, добавляет к claims nonce из запроса
+// (если его нет в claims) и возвращает state и код авторизации для обратного вызова.
+func (iss *Issuer) Authorize(t testing.TB, authURL string, claims map[string]interface{}) (state, code string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != iss.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = q.Get("nonce")
+	}
+	code, err = utils.GenerateToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.grants[code] = grant{challenge: q.Get("code_challenge"), claims: claims}
+	iss.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (iss *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 iss.URL,
+		"authorization_endpoint": iss.URL + "/authorize",
+		"token_endpoint":         iss.URL + "/token",
+		"jwks_uri":               iss.URL + "/jwks",
+	})
+}
+
+func (iss *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.jwksRequests++
+	set := utils.JWKSet{Keys: []utils.JWK{}}
+	for kid, key := range iss.keys {
+		jwk, err := utils.NewJWK(kid, utils.JWTAlgES256, &key.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+	writeJSON(w, http.StatusOK, set)
+}
+
+// token обменивает код на ID-токен. Код одноразовый; code_verifier должен соответствовать
+// code_challenge из запроса входа (RFC 7636, 4.6).
+func (iss *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID := r.PostForm.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+	if clientID != iss.ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	iss.mu.Lock()
+	g, ok := iss.grants[r.PostForm.Get("code")]
+	delete(iss.grants, r.PostForm.Get("code"))
+	iss.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	iss.mu.Lock()
+	kid, key := iss.signKid, iss.keys[iss.signKid]
+	iss.mu.Unlock()
+	idToken, err := utils.SignJWT(kid, g.claims, key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprint(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// ACLRepository отвечает за операции с группами пользователей (groups, group_members)
+// и списками доступа к файлам (asset_grants).
+type ACLRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewACLRepository создает новый экземпляр ACLRepository.
+func NewACLRepository(db *pgxpool.Pool) *ACLRepository {
+	return &ACLRepository{db: db}
+}
+
+// grantExists возвращает SQL-условие «у пользователя viewer есть право perm на файл
+// (или префикс) name владельца owner» — выданное ему лично или одной из его групп.
+// Запись для префикса с «/» на конце распространяется на все имена, начинающиеся с него.
+// Аргументы — SQL-выражения (колонки или плейсхолдеры).
+func grantExists(owner, name, viewer, perm string) string {
+	return `EXISTS (SELECT 1 FROM asset_grants g
+		WHERE g.owner_uid = ` + owner + ` AND g.permission = ` + perm + `
+		  AND (g.name = ` + name + ` OR (right(g.name, 1) = '/' AND starts_with(` + name + `, g.name)))
+		  AND (g.grantee_uid = ` + viewer + `
+		       OR g.group_id IN (SELECT group_id FROM group_members WHERE uid = ` + viewer + `)))`
+}
+
+// HasPermission проверяет, что пользователю uid выдано право permission на файл или префикс name
+// владельца owner.
+func (r *ACLRepository) HasPermission(ctx context.Context, uid, owner int64, name, permission string) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx,
+		`SELECT `+grantExists("$1", "$2", "$3", "$4"),
+		owner, name, uid, permission,
+	).Scan(&ok)
+	return ok, err
+}
+
+// CreateGroup создаёт группу и заполняет её идентификатор и время создания.
+// Если группа с таким именем уже есть, возвращается ErrAlreadyExists.
+func (r *ACLRepository) CreateGroup(ctx context.Context, g *models.Group) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO groups (name, owner_uid) VALUES ($1, $2) RETURNING id, created_at`,
+		g.Name, g.OwnerUID,
+	).Scan(&g.ID, &g.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// GetGroupByName возвращает группу (без списка участников) по имени.
+func (r *ACLRepository) GetGroupByName(ctx context.Context, name string) (*models.Group, error) {
+	var g models.Group
+	err := r.db.QueryRow(ctx,
+		`SELECT id, name, owner_uid, created_at FROM groups WHERE name = $1`,
+		name,
+	).Scan(&g.ID, &g.Name, &g.OwnerUID, &g.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ListGroups возвращает группы, которыми пользователь владеет или в которых состоит, с логинами участников.
+func (r *ACLRepository) ListGroups(ctx context.Context, uid int64) ([]models.Group, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT g.id, g.name, g.owner_uid, g.created_at,
+		        COALESCE(array_agg(u.login ORDER BY u.login) FILTER (WHERE u.login IS NOT NULL), '{}')
+		 FROM groups g
+		 LEFT JOIN group_members m ON m.group_id = g.id
+		 LEFT JOIN users u ON u.id = m.uid
+		 WHERE g.owner_uid = $1 OR EXISTS (SELECT 1 FROM group_members WHERE group_id = g.id AND uid = $1)
+		 GROUP BY g.id
+		 ORDER BY g.name`,
+		uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.OwnerUID, &g.CreatedAt, &g.Members); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// DeleteGroup удаляет группу владельца owner вместе с участниками и выданными ей правами.
+// Возвращает false, если такой группы у владельца нет.
+func (r *ACLRepository) DeleteGroup(ctx context.Context, owner int64, name string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM groups WHERE owner_uid = $1 AND name = $2`, owner, name)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// AddMember добавляет пользователя в группу; повторное добавление ничего не меняет.
+func (r *ACLRepository) AddMember(ctx context.Context, groupID, uid int64) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO group_members (group_id, uid) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		groupID, uid,
+	)
+	return err
+}
+
+// RemoveMember исключает пользователя из группы. Возвращает false, если он в ней не состоял.
+func (r *ACLRepository) RemoveMember(ctx context.Context, groupID, uid int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM group_members WHERE group_id = $1 AND uid = $2`, groupID, uid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CreateGrant сохраняет запись списка доступа и заполняет её идентификатор и время создания.
+// Если точно такое же право уже выдано, возвращается ErrAlreadyExists.
+func (r *ACLRepository) CreateGrant(ctx context.Context, g *models.Grant) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO asset_grants (owner_uid, name, grantee_uid, group_id, permission)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at`,
+		g.OwnerUID, g.Name, g.GranteeUID, g.GroupID, g.Permission,
+	).Scan(&g.ID, &g.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// ListGrants возвращает права, выданные владельцем owner на свои файлы, с логинами
+// пользователей и именами групп.
+func (r *ACLRepository) ListGrants(ctx context.Context, owner int64) ([]models.Grant, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT g.id, g.owner_uid, g.name, g.grantee_uid, COALESCE(u.login, ''), g.group_id, COALESCE(gr.name, ''),
+		        g.permission, g.created_at
+		 FROM asset_grants g
+		 LEFT JOIN users u ON u.id = g.grantee_uid
+		 LEFT JOIN groups gr ON gr.id = g.group_id
+		 WHERE g.owner_uid = $1
+		 ORDER BY g.name, g.id`,
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []models.Grant
+	for rows.Next() {
+		var g models.Grant
+		err := rows.Scan(&g.ID, &g.OwnerUID, &g.Name, &g.GranteeUID, &g.GranteeLogin, &g.GroupID, &g.GroupName,
+			&g.Permission, &g.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// DeleteGrant отзывает право, выданное владельцем owner. Возвращает false, если такой записи нет.
+func (r *ACLRepository) DeleteGrant(ctx context.Context, owner, id int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM asset_grants WHERE owner_uid = $1 AND id = $2`, owner, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// APIKeyRepository отвечает за операции с таблицей api_keys.
+type APIKeyRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewAPIKeyRepository создает новый экземпляр APIKeyRepository.
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// apiKeyColumns — список колонок, считываемых в models.APIKey функцией scanAPIKey.
+const apiKeyColumns = `id, uid, name, key_prefix, key_hash, scopes, name_prefixes, expires_at, last_used_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.UID, &k.Name, &k.Prefix, &k.KeyHash, &k.Scopes, &k.NamePrefixes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Create сохраняет новый API-ключ и заполняет его идентификатор.
+func (r *APIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	return r.db.QueryRow(ctx,
+		`INSERT INTO api_keys (uid, name, key_prefix, key_hash, scopes, name_prefixes, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id`,
+		k.UID, k.Name, k.Prefix, k.KeyHash, k.Scopes, k.NamePrefixes, k.ExpiresAt, k.CreatedAt,
+	).Scan(&k.ID)
+}
+
+// FindByHash ищет API-ключ по хешу.
+func (r *APIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	row := r.db.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`,
+		keyHash,
+	)
+	return scanAPIKey(row)
+}
+
+// ListByUID возвращает все API-ключи пользователя.
+func (r *APIKeyRepository) ListByUID(ctx context.Context, uid int64) ([]models.APIKey, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE uid = $1 ORDER BY created_at`,
+		uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// Touch обновляет время последнего использования ключа.
+func (r *APIKeyRepository) Touch(ctx context.Context, id int64, t time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, t)
+	return err
+}
+
+// Delete удаляет API-ключ пользователя. Возвращает false, если такого ключа у пользователя нет.
+func (r *APIKeyRepository) Delete(ctx context.Context, uid, id int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM api_keys WHERE uid = $1 AND id = $2`, uid, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -2,12 +2,19 @@ package repository
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go-asset-service/internal/models"
 )
 
-// AssetRepository отвечает за выполнение операций с таблицей assets в базе данных.
+// AssetRepository отвечает за выполнение операций с таблицами assets, asset_versions и blobs в базе данных.
+// В assets хранится логический файл и номер его текущей версии, в asset_versions — все версии,
+// а в blobs — содержимое, адресуемое по SHA-256: одинаковое содержимое хранится один раз
+// и удаляется из хранилища, когда на него не остаётся ссылок (refcount).
 type AssetRepository struct {
 	db *pgxpool.Pool // Пул соединений с базой данных
 }
@@ -17,57 +24,567 @@ func NewAssetRepository(db *pgxpool.Pool) *AssetRepository {
 	return &AssetRepository{db: db}
 }
 
-// CreateAsset сохраняет новый asset (файл/данные) в базе данных.
-func (r *AssetRepository) CreateAsset(ctx context.Context, asset *models.Asset) error {
-	_, err := r.db.Exec(ctx,
-		`INSERT INTO assets (name, uid, data, created_at)
-		 VALUES ($1, $2, $3, $4)`,
-		asset.Name, asset.UID, asset.Data, asset.CreatedAt,
+// assetColumns — колонки версии asset в порядке, ожидаемом scanAsset
+// (запросы соединяют assets a, asset_versions v и blobs b). Для текущей версии updated_at —
+// время, когда она стала текущей, для прежних — время их создания.
+const assetColumns = `v.name, v.uid, v.version, b.storage_key, b.size, v.sha256, v.content_type, v.metadata, v.created_at,
+	CASE WHEN v.version = a.current_version THEN a.updated_at ELSE v.created_at END`
+
+// scanAsset считывает одну строку с колонками assetColumns.
+func scanAsset(row interface{ Scan(...interface{}) error }) (*models.Asset, error) {
+	var a models.Asset
+	err := row.Scan(&a.Name, &a.UID, &a.Version, &a.StorageKey, &a.Size, &a.SHA256,
+		&a.ContentType, &a.Metadata, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateVersion сохраняет новую версию asset и делает её текущей; если asset с таким именем
+// ещё нет, он создаётся. Номер версии (следующий после максимального) записывается в asset.Version.
+// Само содержимое к этому моменту уже должно лежать в BlobStore под ключом asset.StorageKey.
+// Если содержимое с таким SHA-256 уже хранится, увеличивается его счётчик ссылок, а в
+// asset.StorageKey записывается ключ уже сохранённого объекта — новый объект вызывающий
+// должен удалить из хранилища как дубликат.
+// Размер версии (и новый файл, если он создаётся) учитывается в потреблении владельца; если это
+// вместе с резервом незавершённых загрузок (кроме загрузки uploadID, из которой собрана версия;
+// пусто — прямая загрузка) превысило бы его квоту, ничего не сохраняется и возвращается
+// ErrQuotaExceeded.
+func (r *AssetRepository) CreateVersion(ctx context.Context, asset *models.Asset, uploadID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUsage(ctx, tx, asset.UID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO blobs (sha256, storage_key, size, refcount, created_at)
+		 VALUES ($1, $2, $3, 1, $4)
+		 ON CONFLICT (sha256) DO UPDATE SET refcount = blobs.refcount + 1
+		 RETURNING storage_key`,
+		asset.SHA256, asset.StorageKey, asset.Size, asset.CreatedAt,
+	).Scan(&asset.StorageKey)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO assets (name, uid, current_version, created_at, updated_at)
+		 VALUES ($1, $2, 0, $3, $3)
+		 ON CONFLICT (name, uid) DO NOTHING`,
+		asset.Name, asset.UID, asset.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := chargeUsage(ctx, tx, asset.UID, asset.Size, tag.RowsAffected(), asset.Size, uploadID); err != nil {
+		return err
+	}
+
+	// Блокируем строку asset, чтобы параллельные загрузки получили разные номера версий
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE((SELECT max(version) FROM asset_versions WHERE name = a.name AND uid = a.uid), 0) + 1
+		 FROM assets a
+		 WHERE a.name = $1 AND a.uid = $2
+		 FOR UPDATE`,
+		asset.Name, asset.UID,
+	).Scan(&asset.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO asset_versions (name, uid, version, sha256, content_type, metadata, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		asset.Name, asset.UID, asset.Version, asset.SHA256, asset.ContentType, asset.Metadata, asset.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE assets SET current_version = $3, updated_at = $4 WHERE name = $1 AND uid = $2`,
+		asset.Name, asset.UID, asset.Version, asset.CreatedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-// GetAsset извлекает asset по имени и идентификатору пользователя.
+// GetAsset извлекает текущую версию asset по имени и идентификатору пользователя.
 func (r *AssetRepository) GetAsset(ctx context.Context, name string, uid int64) (*models.Asset, error) {
 	row := r.db.QueryRow(ctx,
-		`SELECT name, uid, data, created_at
-		 FROM assets
-		 WHERE name = $1 AND uid = $2`,
+		`SELECT `+assetColumns+`
+		 FROM assets a
+		 JOIN asset_versions v ON v.name = a.name AND v.uid = a.uid AND v.version = a.current_version
+		 JOIN blobs b ON b.sha256 = v.sha256
+		 WHERE a.name = $1 AND a.uid = $2`,
 		name, uid,
 	)
-	var a models.Asset
-	err := row.Scan(&a.Name, &a.UID, &a.Data, &a.CreatedAt)
+	return scanAsset(row)
+}
+
+// GetVersion извлекает указанную версию asset. Если такой версии нет, возвращается pgx.ErrNoRows.
+func (r *AssetRepository) GetVersion(ctx context.Context, name string, uid int64, version int) (*models.Asset, error) {
+	row := r.db.QueryRow(ctx,
+		`SELECT `+assetColumns+`
+		 FROM assets a
+		 JOIN asset_versions v ON v.name = a.name AND v.uid = a.uid
+		 JOIN blobs b ON b.sha256 = v.sha256
+		 WHERE v.name = $1 AND v.uid = $2 AND v.version = $3`,
+		name, uid, version,
+	)
+	return scanAsset(row)
+}
+
+// assetListQuery накапливает условия WHERE и параметры запроса списка файлов.
+type assetListQuery struct {
+	args  []interface{}
+	where []string
+}
+
+// newAssetListQuery строит условия выборки текущих версий файлов, доступных пользователю uid,
+// по фильтрам opts: его собственных и чужих, право на чтение которых ему выдано (ACL).
+func newAssetListQuery(uid int64, opts models.AssetListOptions) *assetListQuery {
+	q := &assetListQuery{args: []interface{}{uid}}
+	shared := grantExists("a.uid", "a.name", "$1", q.arg(models.PermissionRead))
+	switch opts.Owner {
+	case 0:
+		q.add("(a.uid = $1 OR " + shared + ")")
+	case uid:
+		q.add("a.uid = $1")
+	default:
+		q.add("a.uid = " + q.arg(opts.Owner) + " AND " + shared)
+	}
+	if opts.Prefix != "" {
+		q.add("v.name LIKE " + q.arg(escapeLike(opts.Prefix)+"%"))
+	}
+	if opts.Glob != "" {
+		q.add("v.name LIKE " + q.arg(globToLike(opts.Glob)))
+	}
+	if len(opts.AllowedPrefixes) > 0 {
+		patterns := make([]string, 0, len(opts.AllowedPrefixes))
+		for _, p := range opts.AllowedPrefixes {
+			patterns = append(patterns, escapeLike(p)+"%")
+		}
+		q.add("v.name LIKE ANY(" + q.arg(patterns) + ")")
+	}
+	if opts.ContentType != "" {
+		if family, ok := strings.CutSuffix(opts.ContentType, "/*"); ok {
+			q.add("v.content_type LIKE " + q.arg(escapeLike(family)+"/%"))
+		} else {
+			q.add("v.content_type = " + q.arg(opts.ContentType))
+		}
+	}
+	if opts.CreatedAfter != nil {
+		q.add("v.created_at >= " + q.arg(*opts.CreatedAfter))
+	}
+	if opts.CreatedBefore != nil {
+		q.add("v.created_at < " + q.arg(*opts.CreatedBefore))
+	}
+	if opts.MinSize != nil {
+		q.add("b.size >= " + q.arg(*opts.MinSize))
+	}
+	if opts.MaxSize != nil {
+		q.add("b.size <= " + q.arg(*opts.MaxSize))
+	}
+	return q
+}
+
+// arg добавляет параметр запроса и возвращает его плейсхолдер ($N).
+func (q *assetListQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *assetListQuery) add(cond string) {
+	q.where = append(q.where, cond)
+}
+
+// query выполняет выборку с колонками columns, порядком orderBy и ограничением limit.
+func (q *assetListQuery) query(ctx context.Context, db *pgxpool.Pool, columns, orderBy string, limit int) (pgx.Rows, error) {
+	return db.Query(ctx,
+		`SELECT `+columns+`
+		 FROM assets a
+		 JOIN asset_versions v ON v.name = a.name AND v.uid = a.uid AND v.version = a.current_version
+		 JOIN blobs b ON b.sha256 = v.sha256
+		 WHERE `+strings.Join(q.where, " AND ")+`
+		 ORDER BY `+orderBy+`
+		 LIMIT `+q.arg(limit),
+		q.args...,
+	)
+}
+
+// ListAssets возвращает страницу списка файлов, доступных пользователю uid, с метаданными их текущих версий,
+// отфильтрованную и отсортированную согласно opts (курсор opts.Cursor здесь не разбирается).
+// after — последний файл предыдущей страницы (nil для первой): используется постраничная
+// выборка по ключу (keyset), поэтому глубина страницы не влияет на скорость запроса.
+// Возвращается не более limit файлов.
+func (r *AssetRepository) ListAssets(ctx context.Context, uid int64, opts models.AssetListOptions, after *models.Asset, limit int) ([]models.Asset, error) {
+	q := newAssetListQuery(uid, opts)
+
+	// Имя файла уникально у владельца, поэтому тройка (поле сортировки, имя, владелец) задаёт
+	// строгий порядок, и следующая страница начинается сразу после последней строки предыдущей.
+	sortColumn, dir, cmp := "v.name", "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	var afterValue interface{}
+	switch opts.Sort {
+	case models.AssetSortSize:
+		sortColumn = "b.size"
+		if after != nil {
+			afterValue = after.Size
+		}
+	case models.AssetSortCreatedAt:
+		sortColumn = "v.created_at"
+		if after != nil {
+			afterValue = after.CreatedAt
+		}
+	}
+	if after != nil {
+		if afterValue == nil {
+			q.add("(v.name, v.uid) " + cmp + " (" + q.arg(after.Name) + ", " + q.arg(after.UID) + ")")
+		} else {
+			q.add("(" + sortColumn + ", v.name, v.uid) " + cmp +
+				" (" + q.arg(afterValue) + ", " + q.arg(after.Name) + ", " + q.arg(after.UID) + ")")
+		}
+	}
+	orderBy := "v.name " + dir + ", v.uid " + dir
+	if sortColumn != "v.name" {
+		orderBy = sortColumn + " " + dir + ", " + orderBy
+	}
+
+	rows, err := q.query(ctx, r.db, assetColumns, orderBy, limit)
 	if err != nil {
 		return nil, err
 	}
-	return &a, nil
+	defer rows.Close()
+
+	var assets []models.Asset
+	for rows.Next() {
+		a, err := scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, *a)
+	}
+	return assets, rows.Err()
 }
 
-// ListAssets возвращает список всех assets (файлов), загруженных пользователем с заданным uid.
-func (r *AssetRepository) ListAssets(ctx context.Context, uid int64) ([]models.Asset, error) {
+// TreeEntry — элемент списка файлов с разделителем: либо файл (Asset), либо общий
+// префикс «вложенной папки» (Prefix), как CommonPrefixes в S3.
+type TreeEntry struct {
+	Prefix string
+	Asset  *models.Asset
+}
+
+// ListTree возвращает страницу списка файлов одного владельца (opts.Owner должен быть задан),
+// сгруппированного по разделителю delimiter:
+// файлы, имя которых после opts.Prefix не содержит разделителя, возвращаются как есть, а
+// остальные сворачиваются в общий префикс до первого разделителя включительно.
+// Элементы упорядочены по имени (префиксу); after — ключ последнего элемента предыдущей страницы.
+func (r *AssetRepository) ListTree(ctx context.Context, uid int64, opts models.AssetListOptions, delimiter, after string, limit int) ([]TreeEntry, error) {
+	q := newAssetListQuery(uid, opts)
+
+	// Ключ группировки: имя до первого разделителя после префикса включительно либо всё имя
+	plen := q.arg(len([]rune(opts.Prefix)))
+	delim := q.arg(delimiter)
+	rest := "substr(v.name, " + plen + " + 1)"
+	key := "CASE WHEN strpos(" + rest + ", " + delim + ") > 0" +
+		" THEN left(v.name, " + plen + " + strpos(" + rest + ", " + delim + ") + char_length(" + delim + ") - 1)" +
+		" ELSE v.name END"
+
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if after != "" {
+		q.add(key + " " + cmp + " " + q.arg(after))
+	}
+
+	rows, err := q.query(ctx, r.db,
+		`DISTINCT ON (`+key+`) `+key+`, `+assetColumns,
+		key+" "+dir+", v.name",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []TreeEntry
+	for rows.Next() {
+		var k string
+		a, err := scanAsset(scanFunc(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&k}, dest...)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		if k != a.Name {
+			entries = append(entries, TreeEntry{Prefix: k})
+		} else {
+			entries = append(entries, TreeEntry{Asset: a})
+		}
+	}
+	return entries, rows.Err()
+}
+
+// scanFunc позволяет передать произвольную функцию сканирования туда, где ожидается строка результата.
+type scanFunc func(dest ...interface{}) error
+
+func (f scanFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+// ListVersions возвращает все версии asset, начиная с самой новой.
+func (r *AssetRepository) ListVersions(ctx context.Context, name string, uid int64) ([]models.Asset, error) {
 	rows, err := r.db.Query(ctx,
-		`SELECT name, uid, created_at FROM assets WHERE uid = $1`,
-		uid,
+		`SELECT `+assetColumns+`
+		 FROM assets a
+		 JOIN asset_versions v ON v.name = a.name AND v.uid = a.uid
+		 JOIN blobs b ON b.sha256 = v.sha256
+		 WHERE v.name = $1 AND v.uid = $2
+		 ORDER BY v.version DESC`,
+		name, uid,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var assets []models.Asset
+	var versions []models.Asset
 	for rows.Next() {
-		var a models.Asset
-		err := rows.Scan(&a.Name, &a.UID, &a.CreatedAt)
+		a, err := scanAsset(rows)
 		if err != nil {
 			return nil, err
 		}
-		assets = append(assets, a)
+		versions = append(versions, *a)
+	}
+	return versions, rows.Err()
+}
+
+// SetCurrentVersion делает указанную версию текущей. Если asset или версии нет,
+// возвращается pgx.ErrNoRows.
+func (r *AssetRepository) SetCurrentVersion(ctx context.Context, name string, uid int64, version int) (*models.Asset, error) {
+	row := r.db.QueryRow(ctx,
+		`WITH updated AS (
+		     UPDATE assets a SET current_version = $3, updated_at = now()
+		     WHERE a.name = $1 AND a.uid = $2
+		       AND EXISTS (SELECT 1 FROM asset_versions WHERE name = $1 AND uid = $2 AND version = $3)
+		     RETURNING a.name, a.uid, a.current_version, a.updated_at
+		 )
+		 SELECT `+assetColumns+`
+		 FROM updated a
+		 JOIN asset_versions v ON v.name = a.name AND v.uid = a.uid AND v.version = $3
+		 JOIN blobs b ON b.sha256 = v.sha256`,
+		name, uid, version,
+	)
+	return scanAsset(row)
+}
+
+// PruneVersions удаляет старые версии asset: все, кроме keep самых новых (если keep > 0),
+// и созданные раньше olderThan (если olderThan не нулевое). Текущая версия не удаляется никогда.
+// Возвращает число удалённых версий и ключи объектов в BlobStore, на которые больше нет ссылок.
+func (r *AssetRepository) PruneVersions(ctx context.Context, name string, uid int64, keep int, olderThan time.Time) (int, []string, error) {
+	var cutoff *time.Time
+	if !olderThan.IsZero() {
+		cutoff = &olderThan
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUsage(ctx, tx, uid); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := tx.Query(ctx,
+		`WITH ranked AS (
+		     SELECT version, row_number() OVER (ORDER BY version DESC) AS rn
+		     FROM asset_versions
+		     WHERE name = $1 AND uid = $2
+		 )
+		 DELETE FROM asset_versions v
+		 USING ranked r, assets a
+		 WHERE v.name = $1 AND v.uid = $2 AND v.version = r.version
+		   AND a.name = v.name AND a.uid = v.uid AND v.version <> a.current_version
+		   AND (($3 > 0 AND r.rn > $3) OR ($4::timestamptz IS NOT NULL AND v.created_at < $4))
+		 RETURNING v.sha256`,
+		name, uid, keep, cutoff,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	hashes, err := collectStrings(rows)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := releaseUsage(ctx, tx, uid, hashes, 0); err != nil {
+		return 0, nil, err
+	}
+	keys, err := releaseBlobs(ctx, tx, hashes)
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(hashes), keys, tx.Commit(ctx)
+}
+
+// DeleteAsset удаляет asset со всеми версиями и возвращает ключи объектов в BlobStore,
+// на которые больше нет ссылок. Если asset не найден, возвращается pgx.ErrNoRows.
+func (r *AssetRepository) DeleteAsset(ctx context.Context, name string, uid int64) ([]string, error) {
+	n, keys, err := r.deleteAssets(ctx, uid, `name = $2`, name)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return keys, nil
+}
+
+// DeletePrefix удаляет все assets пользователя, имена которых начинаются с prefix, вместе
+// с версиями. Возвращает число удалённых файлов и ключи объектов в BlobStore, на которые
+// больше нет ссылок.
+func (r *AssetRepository) DeletePrefix(ctx context.Context, uid int64, prefix string) (int64, []string, error) {
+	return r.deleteAssets(ctx, uid, `name LIKE $2`, escapeLike(prefix)+"%")
+}
+
+// deleteAssets удаляет assets пользователя uid, подходящие под условие cond (с параметром $2),
+// в одной транзакции уменьшая счётчики ссылок на их содержимое и потребление пользователя.
+func (r *AssetRepository) deleteAssets(ctx context.Context, uid int64, cond string, arg interface{}) (int64, []string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUsage(ctx, tx, uid); err != nil {
+		return 0, nil, err
+	}
+
+	// Сначала удаляем версии: их хеши нужны, чтобы уменьшить счётчики ссылок
+	rows, err := tx.Query(ctx,
+		`DELETE FROM asset_versions WHERE uid = $1 AND `+cond+` RETURNING sha256`,
+		uid, arg,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	hashes, err := collectStrings(rows)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM assets WHERE uid = $1 AND `+cond, uid, arg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := releaseUsage(ctx, tx, uid, hashes, tag.RowsAffected()); err != nil {
+		return 0, nil, err
+	}
+	keys, err := releaseBlobs(ctx, tx, hashes)
+	if err != nil {
+		return 0, nil, err
+	}
+	return tag.RowsAffected(), keys, tx.Commit(ctx)
+}
+
+// Move переименовывает asset from в to вместе с историей версий.
+// Если asset не найден, возвращается pgx.ErrNoRows, если имя to занято — ErrAlreadyExists.
+func (r *AssetRepository) Move(ctx context.Context, uid int64, from, to string) error {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE assets SET name = $3, updated_at = now() WHERE uid = $1 AND name = $2`,
+		uid, from, to,
+	)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
 	}
-	return assets, nil
+	return nil
 }
 
-// DeleteAsset удаляет asset с указанным именем и идентификатором пользователя из базы данных.
-func (r *AssetRepository) DeleteAsset(ctx context.Context, name string, uid int64) error {
-	_, err := r.db.Exec(ctx, `DELETE FROM assets WHERE name = $1 AND uid = $2`, name, uid)
-	return err
+// MovePrefix переносит все assets, имена которых начинаются с fromPrefix, заменяя префикс
+// на toPrefix (переименование «папки»). Версии переименовываются каскадно (ON UPDATE CASCADE).
+// Возвращает число перенесённых файлов; если хотя бы одно новое имя занято — ErrAlreadyExists.
+func (r *AssetRepository) MovePrefix(ctx context.Context, uid int64, fromPrefix, toPrefix string) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE assets
+		 SET name = $3 || substr(name, $4 + 1), updated_at = now()
+		 WHERE uid = $1 AND name LIKE $2`,
+		uid, escapeLike(fromPrefix)+"%", toPrefix, len([]rune(fromPrefix)),
+	)
+	if isUniqueViolation(err) {
+		return 0, ErrAlreadyExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// releaseBlobs уменьшает счётчики ссылок содержимого с хешами hashes (хеш может повторяться —
+// по одному разу на каждую удалённую версию), удаляет записи, на которые не осталось ссылок,
+// и возвращает их ключи в BlobStore.
+func releaseBlobs(ctx context.Context, tx pgx.Tx, hashes []string) ([]string, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	_, err := tx.Exec(ctx,
+		`UPDATE blobs b SET refcount = b.refcount - r.n
+		 FROM (SELECT h, count(*) AS n FROM unnest($1::text[]) AS h GROUP BY h) r
+		 WHERE b.sha256 = r.h`,
+		hashes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx,
+		`DELETE FROM blobs WHERE sha256 = ANY($1) AND refcount <= 0 RETURNING storage_key`,
+		hashes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectStrings(rows)
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE (%, _ и \).
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// globToLike преобразует шаблон с * и ? в шаблон LIKE.
+func globToLike(glob string) string {
+	var b strings.Builder
+	for _, c := range glob {
+		switch c {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		default:
+			b.WriteString(escapeLike(string(c)))
+		}
+	}
+	return b.String()
 }
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// AuditRepository отвечает за операции с таблицей audit_log. Записи только добавляются:
+// изменение и удаление запрещены триггерами в БД.
+type AuditRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewAuditRepository создает новый экземпляр AuditRepository.
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// auditColumns — список колонок, считываемых в models.AuditEvent функцией scanAuditEvent.
+const auditColumns = `id, created_at, uid, action, method, target, owner, ip, result, status, details, prev_hash, hash`
+
+func scanAuditEvent(row interface{ Scan(...interface{}) error }) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(&e.ID, &e.Time, &e.UID, &e.Action, &e.Method, &e.Target, &e.Owner, &e.IP,
+		&e.Result, &e.Status, &e.Details, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Append добавляет запись в конец цепочки: записывает в e.PrevHash хеш последней записи
+// (пустой для первой), а в e.Hash — результат hash(e). Таблица блокируется на время вставки,
+// поэтому записи с нескольких экземпляров сервиса не ответвляются от одной и той же предыдущей.
+func (r *AuditRepository) Append(ctx context.Context, e *models.AuditEvent, hash func(*models.AuditEvent) string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE audit_log IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	e.PrevHash = ""
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	e.Hash = hash(e)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO audit_log (created_at, uid, action, method, target, owner, ip, result, status, details, prev_hash, hash)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 RETURNING id`,
+		e.Time, e.UID, e.Action, e.Method, e.Target, e.Owner, e.IP, e.Result, e.Status, e.Details, e.PrevHash, e.Hash,
+	).Scan(&e.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// List возвращает записи, подходящие под фильтр f, от новых к старым; не больше limit.
+func (r *AuditRepository) List(ctx context.Context, f models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if f.UID != 0 {
+		add("uid = ?", f.UID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Since != nil {
+		add("created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < ?", *f.Until)
+	}
+	if f.BeforeID != 0 {
+		add("id < ?", f.BeforeID)
+	}
+
+	sql := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		sql += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, limit)
+	sql += ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectAuditEvents(rows)
+}
+
+// ListAfter возвращает записи с идентификатором больше afterID в порядке цепочки; не больше limit.
+func (r *AuditRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+auditColumns+` FROM audit_log WHERE id > $1 ORDER BY id LIMIT $2`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectAuditEvents(rows)
+}
+
+// collectAuditEvents считывает все строки результата и закрывает его.
+func collectAuditEvents(rows pgx.Rows) ([]models.AuditEvent, error) {
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// ClientCertRepository отвечает за операции с таблицей client_cert_mappings.
+type ClientCertRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewClientCertRepository создает новый экземпляр ClientCertRepository.
+func NewClientCertRepository(db *pgxpool.Pool) *ClientCertRepository {
+	return &ClientCertRepository{db: db}
+}
+
+// clientCertColumns — список колонок, считываемых в models.ClientCertMapping функцией scanClientCert.
+const clientCertColumns = `id, match_type, value, uid, scopes, name_prefixes, last_used_at, created_at`
+
+func scanClientCert(row interface{ Scan(...interface{}) error }) (*models.ClientCertMapping, error) {
+	var m models.ClientCertMapping
+	err := row.Scan(&m.ID, &m.Match, &m.Value, &m.UID, &m.Scopes, &m.NamePrefixes, &m.LastUsedAt, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Create сохраняет новое сопоставление и заполняет его идентификатор и время создания.
+// Если такое поле сертификата уже сопоставлено, возвращается ErrAlreadyExists.
+func (r *ClientCertRepository) Create(ctx context.Context, m *models.ClientCertMapping) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO client_cert_mappings (match_type, value, uid, scopes, name_prefixes)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at`,
+		m.Match, m.Value, m.UID, m.Scopes, m.NamePrefixes,
+	).Scan(&m.ID, &m.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// FindByFields возвращает сопоставления, у которых пара (match_type, value) совпадает с одной
+// из пар (matches[i], values[i]).
+func (r *ClientCertRepository) FindByFields(ctx context.Context, matches, values []string) ([]models.ClientCertMapping, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+clientCertColumns+`
+		 FROM client_cert_mappings
+		 WHERE (match_type, value) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+		 ORDER BY id`,
+		matches, values,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectClientCerts(rows)
+}
+
+// List возвращает все сопоставления.
+func (r *ClientCertRepository) List(ctx context.Context) ([]models.ClientCertMapping, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+clientCertColumns+` FROM client_cert_mappings ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return collectClientCerts(rows)
+}
+
+// Touch обновляет время последнего использования сопоставления.
+func (r *ClientCertRepository) Touch(ctx context.Context, id int64, t time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE client_cert_mappings SET last_used_at = $2 WHERE id = $1`, id, t)
+	return err
+}
+
+// Delete удаляет сопоставление. Возвращает false, если его нет.
+func (r *ClientCertRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM client_cert_mappings WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// collectClientCerts считывает все строки результата и закрывает его.
+func collectClientCerts(rows pgx.Rows) ([]models.ClientCertMapping, error) {
+	defer rows.Close()
+
+	var mappings []models.ClientCertMapping
+	for rows.Next() {
+		m, err := scanClientCert(rows)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, *m)
+	}
+	return mappings, rows.Err()
+}
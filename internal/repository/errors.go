@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrAlreadyExists возвращается, если вставка нарушает ограничение уникальности.
+var ErrAlreadyExists = errors.New("already exists")
+
+// isUniqueViolation проверяет, что ошибка Postgres вызвана нарушением уникальности (SQLSTATE 23505).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// JWTRepository отвечает за операции с таблицами jwt_signing_keys и revoked_sessions.
+// Записи в revoked_sessions добавляет SessionRepository при удалении сессий.
+type JWTRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewJWTRepository создает новый экземпляр JWTRepository.
+func NewJWTRepository(db *pgxpool.Pool) *JWTRepository {
+	return &JWTRepository{db: db}
+}
+
+// ListKeys возвращает все ключи подписи, начиная с самого нового.
+func (r *JWTRepository) ListKeys(ctx context.Context) ([]models.JWTSigningKey, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT kid, alg, private_key, created_at FROM jwt_signing_keys ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.JWTSigningKey
+	for rows.Next() {
+		var k models.JWTSigningKey
+		if err := rows.Scan(&k.Kid, &k.Alg, &k.PrivateKey, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateKeyIfStale сохраняет ключ k, если нет ключа, созданного после freshAfter.
+// Таблица блокируется на время проверки, поэтому одновременная смена ключа на нескольких
+// экземплярах сервиса создаёт только один новый ключ. Возвращает false, если ключ не создан.
+func (r *JWTRepository) CreateKeyIfStale(ctx context.Context, k *models.JWTSigningKey, freshAfter time.Time) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE jwt_signing_keys IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO jwt_signing_keys (kid, alg, private_key, created_at)
+		 SELECT $1, $2, $3, $4
+		 WHERE NOT EXISTS (SELECT 1 FROM jwt_signing_keys WHERE created_at > $5)`,
+		k.Kid, k.Alg, k.PrivateKey, k.CreatedAt, freshAfter,
+	)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	return true, tx.Commit(ctx)
+}
+
+// DeleteKeysBefore удаляет ключи, созданные до cutoff, и возвращает их число.
+func (r *JWTRepository) DeleteKeysBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM jwt_signing_keys WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListRevoked возвращает сессии, отозванные не раньше since, в порядке отзыва.
+func (r *JWTRepository) ListRevoked(ctx context.Context, since time.Time) ([]models.RevokedSession, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT public_id, revoked_at FROM revoked_sessions WHERE revoked_at >= $1 ORDER BY revoked_at`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []models.RevokedSession
+	for rows.Next() {
+		var s models.RevokedSession
+		if err := rows.Scan(&s.SessionID, &s.RevokedAt); err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, s)
+	}
+	return revoked, rows.Err()
+}
+
+// DeleteRevokedBefore удаляет записи об отозванных до cutoff сессиях и возвращает их число.
+func (r *JWTRepository) DeleteRevokedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM revoked_sessions WHERE revoked_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
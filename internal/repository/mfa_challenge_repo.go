@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// MFAChallengeRepository отвечает за операции с таблицей mfa_challenges.
+type MFAChallengeRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewMFAChallengeRepository создает новый экземпляр MFAChallengeRepository.
+func NewMFAChallengeRepository(db *pgxpool.Pool) *MFAChallengeRepository {
+	return &MFAChallengeRepository{db: db}
+}
+
+// Create сохраняет хеш токена незавершённого входа.
+func (r *MFAChallengeRepository) Create(ctx context.Context, tokenHash string, c *models.MFAChallenge) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO mfa_challenges (token_hash, uid, device_label, expires_at) VALUES ($1, $2, $3, $4)`,
+		tokenHash, c.UID, c.DeviceLabel, c.ExpiresAt,
+	)
+	return err
+}
+
+// Find возвращает действующий на момент now незавершённый вход.
+// Если токена нет или он истёк, возвращается pgx.ErrNoRows.
+func (r *MFAChallengeRepository) Find(ctx context.Context, tokenHash string, now time.Time) (*models.MFAChallenge, error) {
+	var c models.MFAChallenge
+	err := r.db.QueryRow(ctx,
+		`SELECT uid, device_label, attempts, expires_at FROM mfa_challenges
+		 WHERE token_hash = $1 AND expires_at > $2`,
+		tokenHash, now,
+	).Scan(&c.UID, &c.DeviceLabel, &c.Attempts, &c.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Fail увеличивает счётчик неверных кодов и удаляет вход, если он достиг maxAttempts.
+func (r *MFAChallengeRepository) Fail(ctx context.Context, tokenHash string, maxAttempts int) error {
+	var attempts int
+	err := r.db.QueryRow(ctx,
+		`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token_hash = $1 RETURNING attempts`,
+		tokenHash,
+	).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil || attempts < maxAttempts {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `DELETE FROM mfa_challenges WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+// Consume удаляет незавершённый вход. Возвращает false, если его уже нет: токен одноразовый,
+// и из двух одновременных запросов завершить вход сможет только один.
+func (r *MFAChallengeRepository) Consume(ctx context.Context, tokenHash string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM mfa_challenges WHERE token_hash = $1`, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteExpired удаляет истёкшие незавершённые входы и возвращает их число.
+func (r *MFAChallengeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM mfa_challenges WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// MFARepository отвечает за операции с таблицами user_mfa, mfa_recovery_codes и mfa_required_roles.
+type MFARepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewMFARepository создает новый экземпляр MFARepository.
+func NewMFARepository(db *pgxpool.Pool) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// Get возвращает второй фактор пользователя uid. Если он не подключался, возвращается pgx.ErrNoRows.
+func (r *MFARepository) Get(ctx context.Context, uid int64) (*models.UserMFA, error) {
+	var m models.UserMFA
+	err := r.db.QueryRow(ctx,
+		`SELECT uid, secret, confirmed_at, last_used_step, created_at FROM user_mfa WHERE uid = $1`,
+		uid,
+	).Scan(&m.UID, &m.Secret, &m.ConfirmedAt, &m.LastUsedStep, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Enroll сохраняет новый секрет пользователя uid, заменяя неподтверждённый. Если второй фактор
+// уже подтверждён, ничего не меняется и возвращается ErrAlreadyExists.
+func (r *MFARepository) Enroll(ctx context.Context, uid int64, secret string, now time.Time) error {
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO user_mfa (uid, secret, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (uid) DO UPDATE
+		 SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+		 WHERE user_mfa.confirmed_at IS NULL`,
+		uid, secret, now,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// Confirm подтверждает второй фактор пользователя uid кодом шага step и сохраняет хеши
+// кодов восстановления. Возвращает false, если подтверждать нечего (подключение не начато
+// или уже подтверждено).
+func (r *MFARepository) Confirm(ctx context.Context, uid, step int64, codeHashes []string, now time.Time) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE user_mfa SET confirmed_at = $3, last_used_step = $2
+		 WHERE uid = $1 AND confirmed_at IS NULL`,
+		uid, step, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := replaceRecoveryCodes(ctx, tx, uid, codeHashes); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// UseStep запоминает шаг step принятого кода TOTP пользователя uid. Возвращает false, если
+// код этого или более позднего шага уже был принят: так один код нельзя использовать дважды,
+// в том числе в двух одновременных запросах.
+func (r *MFARepository) UseStep(ctx context.Context, uid, step int64) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE user_mfa SET last_used_step = $2 WHERE uid = $1 AND last_used_step < $2`,
+		uid, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ReplaceRecoveryCodes заменяет коды восстановления пользователя uid новыми.
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, uid int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, uid, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// replaceRecoveryCodes удаляет коды восстановления пользователя uid и сохраняет новые в транзакции tx.
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, uid int64, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE uid = $1`, uid); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO mfa_recovery_codes (uid, code_hash) SELECT $1, unnest($2::text[])`,
+		uid, codeHashes,
+	)
+	return err
+}
+
+// UseRecoveryCode отмечает код восстановления пользователя uid использованным.
+// Возвращает false, если такого неиспользованного кода нет.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, uid int64, codeHash string, now time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE mfa_recovery_codes SET used_at = $3
+		 WHERE uid = $1 AND code_hash = $2 AND used_at IS NULL`,
+		uid, codeHash, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления пользователя uid.
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, uid int64) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx,
+		`SELECT count(*) FROM mfa_recovery_codes WHERE uid = $1 AND used_at IS NULL`,
+		uid,
+	).Scan(&n)
+	return n, err
+}
+
+// Delete отключает второй фактор пользователя uid: удаляет секрет и коды восстановления.
+// Возвращает false, если второй фактор не подключался.
+func (r *MFARepository) Delete(ctx context.Context, uid int64) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE uid = $1`, uid); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE uid = $1`, uid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, tx.Commit(ctx)
+}
+
+// IsRequired сообщает, обязателен ли второй фактор для роли role.
+func (r *MFARepository) IsRequired(ctx context.Context, role string) (bool, error) {
+	var required bool
+	err := r.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM mfa_required_roles WHERE role = $1)`,
+		role,
+	).Scan(&required)
+	return required, err
+}
+
+// RequiredRoles возвращает роли, для которых второй фактор обязателен.
+func (r *MFARepository) RequiredRoles(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	return collectStrings(rows)
+}
+
+// SetRequiredRoles заменяет список ролей, для которых второй фактор обязателен.
+func (r *MFARepository) SetRequiredRoles(ctx context.Context, roles []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_required_roles`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO mfa_required_roles (role) SELECT unnest($1::text[])`, roles)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// OIDCRepository отвечает за операции с таблицами oidc_login_states и user_identities.
+type OIDCRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewOIDCRepository создает новый экземпляр OIDCRepository.
+func NewOIDCRepository(db *pgxpool.Pool) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+// CreateState сохраняет начатый вход под хешем параметра state.
+func (r *OIDCRepository) CreateState(ctx context.Context, stateHash string, s *models.OIDCLoginState) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO oidc_login_states (state_hash, code_verifier, nonce, device_label, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		stateHash, s.CodeVerifier, s.Nonce, s.DeviceLabel, s.ExpiresAt,
+	)
+	return err
+}
+
+// ConsumeState удаляет действующий на момент now начатый вход и возвращает его: state
+// одноразовый, и повторный обратный вызов с тем же state отклоняется.
+// Если входа нет или он истёк, возвращается pgx.ErrNoRows.
+func (r *OIDCRepository) ConsumeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCLoginState, error) {
+	var s models.OIDCLoginState
+	err := r.db.QueryRow(ctx,
+		`DELETE FROM oidc_login_states WHERE state_hash = $1 AND expires_at > $2
+		 RETURNING code_verifier, nonce, device_label, expires_at`,
+		stateHash, now,
+	).Scan(&s.CodeVerifier, &s.Nonce, &s.DeviceLabel, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteExpiredStates удаляет истёкшие начатые входы и возвращает их число.
+func (r *OIDCRepository) DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// FindUID возвращает пользователя, привязанного к учётной записи subject у провайдера issuer.
+// Если привязки нет, возвращается pgx.ErrNoRows.
+func (r *OIDCRepository) FindUID(ctx context.Context, issuer, subject string) (int64, error) {
+	var uid int64
+	err := r.db.QueryRow(ctx,
+		`SELECT uid FROM user_identities WHERE issuer = $1 AND subject = $2`,
+		issuer, subject,
+	).Scan(&uid)
+	return uid, err
+}
+
+// Link привязывает пользователя uid к учётной записи subject у провайдера issuer.
+// Если эта учётная запись уже привязана, возвращается ErrAlreadyExists.
+func (r *OIDCRepository) Link(ctx context.Context, uid int64, issuer, subject string) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO user_identities (issuer, subject, uid) VALUES ($1, $2, $3)`,
+		issuer, subject, uid,
+	)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// CreateLinkedUser в одной транзакции создаёт пользователя u и привязывает его к учётной
+// записи subject у провайдера issuer; заполняет ID и время создания пользователя.
+// Если логин занят или учётная запись уже привязана, возвращается ErrAlreadyExists.
+func (r *OIDCRepository) CreateLinkedUser(ctx context.Context, u *models.User, issuer, subject string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO users (login, password_hash, role) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		u.Login, u.PasswordHash, u.Role,
+	).Scan(&u.ID, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO user_identities (issuer, subject, uid) VALUES ($1, $2, $3)`,
+		issuer, subject, u.ID,
+	)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
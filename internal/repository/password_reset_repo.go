@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PasswordResetRepository отвечает за операции с таблицей password_reset_tokens.
+type PasswordResetRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewPasswordResetRepository создает новый экземпляр PasswordResetRepository.
+func NewPasswordResetRepository(db *pgxpool.Pool) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create сохраняет хеш нового токена сброса пароля пользователя uid, заменяя выданные ранее.
+func (r *PasswordResetRepository) Create(ctx context.Context, tokenHash string, uid int64, expiresAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM password_reset_tokens WHERE uid = $1`, uid); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO password_reset_tokens (token_hash, uid, expires_at) VALUES ($1, $2, $3)`,
+		tokenHash, uid, expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// FindUID возвращает пользователя, которому выдан действующий на момент now токен.
+// Если токена нет или он истёк, возвращается pgx.ErrNoRows.
+func (r *PasswordResetRepository) FindUID(ctx context.Context, tokenHash string, now time.Time) (int64, error) {
+	var uid int64
+	err := r.db.QueryRow(ctx,
+		`SELECT uid FROM password_reset_tokens WHERE token_hash = $1 AND expires_at > $2`,
+		tokenHash, now,
+	).Scan(&uid)
+	return uid, err
+}
+
+// Consume удаляет действующий токен и возвращает пользователя, которому он выдан: токен
+// одноразовый, и из двух одновременных запросов воспользоваться им сможет только один.
+// Если токена нет или он истёк, возвращается pgx.ErrNoRows.
+func (r *PasswordResetRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (int64, error) {
+	var uid int64
+	err := r.db.QueryRow(ctx,
+		`DELETE FROM password_reset_tokens WHERE token_hash = $1 AND expires_at > $2 RETURNING uid`,
+		tokenHash, now,
+	).Scan(&uid)
+	return uid, err
+}
+
+// DeleteByUID удаляет все токены сброса пароля пользователя.
+func (r *PasswordResetRepository) DeleteByUID(ctx context.Context, uid int64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM password_reset_tokens WHERE uid = $1`, uid)
+	return err
+}
+
+// DeleteExpired удаляет токены, истёкшие к моменту before, и возвращает их число.
+func (r *PasswordResetRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM password_reset_tokens WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PresignRepository хранит счётчики использований подписанных ссылок с ограниченным
+// числом использований (таблица presigned_url_uses). Сами ссылки в БД не хранятся.
+type PresignRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewPresignRepository создает новый экземпляр PresignRepository.
+func NewPresignRepository(db *pgxpool.Pool) *PresignRepository {
+	return &PresignRepository{db: db}
+}
+
+// Use атомарно засчитывает ещё одно использование ссылки id. Возвращает false,
+// если ссылка уже использована maxUses раз. expiresAt — срок действия ссылки,
+// после которого счётчик можно удалить.
+func (r *PresignRepository) Use(ctx context.Context, id string, maxUses int, expiresAt time.Time) (bool, error) {
+	var uses int
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO presigned_url_uses (id, uses, expires_at)
+		 VALUES ($1, 1, $3)
+		 ON CONFLICT (id) DO UPDATE SET uses = presigned_url_uses.uses + 1
+		 WHERE presigned_url_uses.uses < $2
+		 RETURNING uses`,
+		id, maxUses, expiresAt,
+	).Scan(&uses)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpired удаляет счётчики ссылок, срок действия которых истёк до before.
+// Возвращает число удалённых записей.
+func (r *PresignRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM presigned_url_uses WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
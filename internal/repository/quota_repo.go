@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// ErrQuotaExceeded возвращается, если изменение превысило бы квоту пользователя.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaRepository отвечает за операции с таблицами role_quotas, user_quotas и user_usage.
+type QuotaRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewQuotaRepository создает новый экземпляр QuotaRepository.
+func NewQuotaRepository(db *pgxpool.Pool) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// effectiveQuota — подзапрос, возвращающий действующую квоту пользователя $1 (колонки
+// max_bytes, max_objects, max_file_size): персональные ограничения с подстановкой ограничений роли.
+const effectiveQuota = `SELECT COALESCE(uq.max_bytes, rq.max_bytes) AS max_bytes,
+	       COALESCE(uq.max_objects, rq.max_objects) AS max_objects,
+	       COALESCE(uq.max_file_size, rq.max_file_size) AS max_file_size
+	FROM users u
+	LEFT JOIN role_quotas rq ON rq.role = u.role
+	LEFT JOIN user_quotas uq ON uq.uid = u.id
+	WHERE u.id = $1`
+
+// pendingUsage возвращает подзапрос с резервом незавершённых загрузок пользователя $1 (колонки
+// bytes — их объявленный размер, objects — число ещё не существующих файлов, которые они
+// создадут), не считая загрузки с идентификатором из параметра exclude: резерв занимает место
+// в квоте с создания загрузки до её сборки или удаления.
+func pendingUsage(exclude string) string {
+	return `SELECT COALESCE(sum(p.length), 0) AS bytes,
+	       count(DISTINCT p.name) FILTER (WHERE a.name IS NULL) AS objects
+	FROM uploads p
+	LEFT JOIN assets a ON a.name = p.name AND a.uid = p.uid
+	WHERE p.uid = $1 AND p.id <> ` + exclude
+}
+
+// Usage возвращает потребление пользователя, резерв его незавершённых загрузок и действующую
+// для него квоту. Если пользователя нет, возвращается pgx.ErrNoRows.
+func (r *QuotaRepository) Usage(ctx context.Context, uid int64) (*models.Usage, error) {
+	return r.UsageExcept(ctx, uid, "")
+}
+
+// UsageExcept возвращает то же, что Usage, но без резерва загрузки uploadID — при сборке этой
+// загрузки её собственный резерв не должен мешать сохранению файла.
+func (r *QuotaRepository) UsageExcept(ctx context.Context, uid int64, uploadID string) (*models.Usage, error) {
+	u := models.Usage{UID: uid}
+	err := r.db.QueryRow(ctx,
+		`SELECT COALESCE(s.bytes, 0), COALESCE(s.objects, 0), p.bytes, p.objects,
+		        q.max_bytes, q.max_objects, q.max_file_size
+		 FROM (`+effectiveQuota+`) q
+		 CROSS JOIN (`+pendingUsage("$2")+`) p
+		 LEFT JOIN user_usage s ON s.uid = $1`,
+		uid, uploadID,
+	).Scan(&u.Bytes, &u.Objects, &u.ReservedBytes, &u.ReservedObjects,
+		&u.Quota.MaxBytes, &u.Quota.MaxObjects, &u.Quota.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetUserQuota задаёт персональную квоту пользователя (поля nil — ограничения роли).
+func (r *QuotaRepository) SetUserQuota(ctx context.Context, uid int64, q models.Quota) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO user_quotas (uid, max_bytes, max_objects, max_file_size)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (uid) DO UPDATE
+		 SET max_bytes = EXCLUDED.max_bytes, max_objects = EXCLUDED.max_objects, max_file_size = EXCLUDED.max_file_size`,
+		uid, q.MaxBytes, q.MaxObjects, q.MaxFileSize,
+	)
+	return err
+}
+
+// DeleteUserQuota удаляет персональную квоту: для пользователя снова действует квота роли.
+func (r *QuotaRepository) DeleteUserQuota(ctx context.Context, uid int64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM user_quotas WHERE uid = $1`, uid)
+	return err
+}
+
+// ListRoleQuotas возвращает квоты всех ролей.
+func (r *QuotaRepository) ListRoleQuotas(ctx context.Context) ([]models.RoleQuota, error) {
+	rows, err := r.db.Query(ctx, `SELECT role, max_bytes, max_objects, max_file_size FROM role_quotas ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []models.RoleQuota
+	for rows.Next() {
+		var q models.RoleQuota
+		if err := rows.Scan(&q.Role, &q.MaxBytes, &q.MaxObjects, &q.MaxFileSize); err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+// SetRoleQuota задаёт квоту роли (поля nil — без ограничения).
+func (r *QuotaRepository) SetRoleQuota(ctx context.Context, role string, q models.Quota) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO role_quotas (role, max_bytes, max_objects, max_file_size)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (role) DO UPDATE
+		 SET max_bytes = EXCLUDED.max_bytes, max_objects = EXCLUDED.max_objects, max_file_size = EXCLUDED.max_file_size`,
+		role, q.MaxBytes, q.MaxObjects, q.MaxFileSize,
+	)
+	return err
+}
+
+// lockUsage блокирует строку счётчиков потребления пользователя uid до конца транзакции tx
+// (создавая её при необходимости). Вызывается первой в каждой транзакции, которая создаёт или
+// удаляет версии файлов пользователя: параллельные изменения одного пользователя выполняются
+// последовательно и не могут вместе превысить квоту, а одинаковый порядок блокировок
+// исключает взаимоблокировки с записями blobs и assets.
+func lockUsage(ctx context.Context, tx pgx.Tx, uid int64) error {
+	_, err := tx.Exec(ctx, `INSERT INTO user_usage (uid) VALUES ($1) ON CONFLICT (uid) DO NOTHING`, uid)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `SELECT 1 FROM user_usage WHERE uid = $1 FOR UPDATE`, uid)
+	return err
+}
+
+// chargeUsage изменяет счётчики потребления пользователя uid на bytes и objects в транзакции tx,
+// предварительно заблокированные lockUsage. Увеличение проверяется по действующей квоте с учётом
+// резерва незавершённых загрузок, кроме загрузки uploadID, из которой собран файл (fileSize —
+// размер загружаемого файла, 0 — без проверки): если она была бы превышена, счётчики
+// не меняются и возвращается ErrQuotaExceeded.
+func chargeUsage(ctx context.Context, tx pgx.Tx, uid, bytes, objects, fileSize int64, uploadID string) error {
+	tag, err := tx.Exec(ctx,
+		`WITH q AS (`+effectiveQuota+`), p AS (`+pendingUsage("$5")+`)
+		 UPDATE user_usage s
+		 SET bytes = s.bytes + $2, objects = s.objects + $3
+		 FROM q, p
+		 WHERE s.uid = $1
+		   AND ($2 <= 0 OR q.max_bytes IS NULL OR s.bytes + p.bytes + $2 <= q.max_bytes)
+		   AND ($3 <= 0 OR q.max_objects IS NULL OR s.objects + p.objects + $3 <= q.max_objects)
+		   AND ($4 <= 0 OR q.max_file_size IS NULL OR $4 <= q.max_file_size)`,
+		uid, bytes, objects, fileSize, uploadID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// releaseUsage уменьшает счётчики потребления пользователя uid на размер удалённых версий
+// с хешами hashes и на objects удалённых файлов. Вызывается до releaseBlobs, пока записи
+// о содержимом (с его размером) ещё не удалены.
+func releaseUsage(ctx context.Context, tx pgx.Tx, uid int64, hashes []string, objects int64) error {
+	if len(hashes) == 0 && objects == 0 {
+		return nil
+	}
+	var bytes int64
+	err := tx.QueryRow(ctx,
+		`SELECT COALESCE(sum(b.size), 0) FROM unnest($1::text[]) AS h JOIN blobs b ON b.sha256 = h`,
+		hashes,
+	).Scan(&bytes)
+	if err != nil {
+		return err
+	}
+	return chargeUsage(ctx, tx, uid, -bytes, -objects, 0, "")
+}
@@ -2,12 +2,17 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go-asset-service/internal/models"
 )
 
+// ErrRefreshTokenReused возвращается, если предъявлен refresh-токен, который уже был обменян.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
 // SessionRepository отвечает за выполнение операций с таблицей sessions в базе данных.
 type SessionRepository struct {
 	db *pgxpool.Pool // Пул соединений с базой данных
@@ -18,12 +23,28 @@ func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
 	return &SessionRepository{db: db}
 }
 
+// sessionColumns — список колонок, считываемых в models.Session функцией scanSession.
+const sessionColumns = `id, public_id, uid, coalesce(device_label, ''), coalesce(ip_address, ''), created_at, last_used_at, mfa_verified, refresh_expires_at`
+
+// scanSession считывает строку с колонками sessionColumns.
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.Session, error) {
+	var s models.Session
+	err := row.Scan(&s.ID, &s.PublicID, &s.UID, &s.DeviceLabel, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.MFAVerified, &s.RefreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
 // Create создает новую сессию и сохраняет ее в таблице sessions.
-func (r *SessionRepository) Create(ctx context.Context, s *models.Session) error {
+// refreshHash — хеш refresh-токена сессии (сам токен в БД не хранится).
+func (r *SessionRepository) Create(ctx context.Context, s *models.Session, refreshHash string) error {
 	_, err := r.db.Exec(ctx,
-		`INSERT INTO sessions (id, uid, ip_address, created_at)
-		 VALUES ($1, $2, $3, $4)`,
-		s.ID, s.UID, s.IPAddress, s.CreatedAt,
+		`INSERT INTO sessions (id, public_id, uid, device_label, ip_address, created_at, last_used_at,
+		                       mfa_verified, refresh_token_hash, refresh_expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		s.ID, s.PublicID, s.UID, s.DeviceLabel, s.IPAddress, s.CreatedAt, s.LastUsedAt,
+		s.MFAVerified, refreshHash, s.RefreshExpiresAt,
 	)
 	return err
 }
@@ -31,33 +52,204 @@ func (r *SessionRepository) Create(ctx context.Context, s *models.Session) error
 // FindByID ищет и возвращает сессию по ее уникальному идентификатору (ID).
 func (r *SessionRepository) FindByID(ctx context.Context, sessionID string) (*models.Session, error) {
 	row := r.db.QueryRow(ctx,
-		`SELECT id, uid, ip_address, created_at
+		`SELECT `+sessionColumns+`
 		 FROM sessions
 		 WHERE id = $1`,
 		sessionID,
 	)
+	return scanSession(row)
+}
 
-	var s models.Session
-	err := row.Scan(&s.ID, &s.UID, &s.IPAddress, &s.CreatedAt)
+// FindByPublicID ищет сессию по её публичному идентификатору.
+func (r *SessionRepository) FindByPublicID(ctx context.Context, publicID string) (*models.Session, error) {
+	row := r.db.QueryRow(ctx,
+		`SELECT `+sessionColumns+`
+		 FROM sessions
+		 WHERE public_id = $1`,
+		publicID,
+	)
+	return scanSession(row)
+}
+
+// FindByRefreshHash ищет сессию по хешу её текущего refresh-токена.
+// Если токен уже был обменян ранее (повторное использование), сессия, которой он принадлежал,
+// удаляется целиком и возвращается ErrRefreshTokenReused: так украденный refresh-токен
+// перестаёт работать и у злоумышленника, и у владельца. Вместе с ошибкой возвращается
+// удалённая сессия, в которой заполнен только PublicID.
+func (r *SessionRepository) FindByRefreshHash(ctx context.Context, refreshHash string) (*models.Session, error) {
+	row := r.db.QueryRow(ctx,
+		`SELECT `+sessionColumns+`
+		 FROM sessions
+		 WHERE refresh_token_hash = $1`,
+		refreshHash,
+	)
+	s, err := scanSession(row)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return s, err
+	}
+
+	revoked, err := r.revokeSessions(ctx,
+		`public_id IN (SELECT session_public_id FROM used_refresh_tokens WHERE token_hash = $1)`,
+		refreshHash,
+	)
 	if err != nil {
 		return nil, err
 	}
-	return &s, nil
+	if len(revoked) > 0 {
+		return &models.Session{PublicID: revoked[0]}, ErrRefreshTokenReused
+	}
+	return nil, pgx.ErrNoRows
 }
 
-// DeleteByUID удаляет все сессии для указанного пользователя (UID).
-// Это используется для реализации механизма "единственной активной сессии".
-func (r *SessionRepository) DeleteByUID(ctx context.Context, uid int64) error {
-	_, err := r.db.Exec(ctx,
-		`DELETE FROM sessions WHERE uid = $1`,
+// Rotate заменяет токен доступа и refresh-токен сессии. Старый refresh-токен запоминается
+// как использованный, чтобы обнаружить его повторное предъявление.
+// Если refresh-токен сессии успел смениться (параллельный обмен), возвращается ErrRefreshTokenReused.
+func (r *SessionRepository) Rotate(ctx context.Context, s *models.Session, oldRefreshHash, newRefreshHash string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE sessions
+		 SET id = $2, refresh_token_hash = $3, refresh_expires_at = $4, last_used_at = $5
+		 WHERE public_id = $1 AND refresh_token_hash = $6`,
+		s.PublicID, s.ID, newRefreshHash, s.RefreshExpiresAt, s.LastUsedAt, oldRefreshHash,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO used_refresh_tokens (token_hash, session_public_id, used_at)
+		 VALUES ($1, $2, $3)`,
+		oldRefreshHash, s.PublicID, s.LastUsedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListByUID возвращает все сессии пользователя, начиная с последних использованных.
+func (r *SessionRepository) ListByUID(ctx context.Context, uid int64) ([]models.Session, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+sessionColumns+`
+		 FROM sessions
+		 WHERE uid = $1
+		 ORDER BY last_used_at DESC`,
 		uid,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch обновляет время последнего использования сессии.
+func (r *SessionRepository) Touch(ctx context.Context, sessionID string, t time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE sessions SET last_used_at = $2 WHERE id = $1`, sessionID, t)
 	return err
 }
 
-// DeleteExpired удаляет все сессии, созданные до времени cutoff.
-// Этот метод можно использовать для очистки просроченных сессий.
-func (r *SessionRepository) DeleteExpired(ctx context.Context, cutoff time.Time) error {
-	_, err := r.db.Exec(ctx, `DELETE FROM sessions WHERE created_at < $1`, cutoff)
+// SetMFAVerified отмечает сессию с публичным идентификатором publicID как прошедшую
+// проверку второго фактора.
+func (r *SessionRepository) SetMFAVerified(ctx context.Context, publicID string) error {
+	_, err := r.db.Exec(ctx, `UPDATE sessions SET mfa_verified = true WHERE public_id = $1`, publicID)
 	return err
 }
+
+// DeleteByID удаляет сессию по её идентификатору (токену).
+func (r *SessionRepository) DeleteByID(ctx context.Context, sessionID string) error {
+	_, err := r.db.Exec(ctx, revokeSessionsSQL(`id = $1`), sessionID)
+	return err
+}
+
+// DeleteByPublicID удаляет сессию пользователя uid по её публичному идентификатору.
+// Возвращает false, если такой сессии у пользователя нет.
+func (r *SessionRepository) DeleteByPublicID(ctx context.Context, uid int64, publicID string) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		revokeSessionsSQL(`uid = $1 AND public_id = $2`),
+		uid, publicID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteOthers удаляет все сессии пользователя, кроме сессии keepID, и возвращает
+// публичные идентификаторы удалённых.
+func (r *SessionRepository) DeleteOthers(ctx context.Context, uid int64, keepID string) ([]string, error) {
+	return r.revokeSessions(ctx, `uid = $1 AND id <> $2`, uid, keepID)
+}
+
+// DeleteByUID удаляет все сессии для указанного пользователя (UID) и возвращает
+// публичные идентификаторы удалённых.
+func (r *SessionRepository) DeleteByUID(ctx context.Context, uid int64) ([]string, error) {
+	return r.revokeSessions(ctx, `uid = $1`, uid)
+}
+
+// DeleteExpired удаляет сессии, которыми больше нельзя воспользоваться: созданные до createdBefore
+// (превышен абсолютный срок жизни), а также неактивные с idleBefore, у которых истёк и refresh-токен.
+// Заодно удаляются записи об использованных refresh-токенах, выданных до usedBefore.
+// Возвращает число удалённых сессий.
+func (r *SessionRepository) DeleteExpired(ctx context.Context, now, createdBefore, idleBefore, usedBefore time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM sessions
+		 WHERE created_at < $2
+		    OR (last_used_at < $3 AND refresh_expires_at < $1)`,
+		now, createdBefore, idleBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.db.Exec(ctx, `DELETE FROM used_refresh_tokens WHERE used_at < $1`, usedBefore)
+	return tag.RowsAffected(), err
+}
+
+// revokeSessions удаляет сессии по условию where и возвращает их публичные идентификаторы,
+// чтобы вызывающий мог сразу отозвать их токены доступа на этом экземпляре сервиса.
+func (r *SessionRepository) revokeSessions(ctx context.Context, where string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(ctx, revokeSessionsSQL(where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// revokeSessionsSQL возвращает запрос, который удаляет сессии по условию where, заносит их
+// в список отозванных (revoked_sessions) и возвращает их public_id: токены доступа в формате
+// JWT проверяются без таблицы sessions и перестают действовать только по этому списку.
+// Число затронутых запросом строк равно числу удалённых сессий. Просроченные сессии
+// (DeleteExpired) в список не заносятся: выданные им токены доступа к этому времени уже истекли.
+func revokeSessionsSQL(where string) string {
+	return `WITH deleted AS (DELETE FROM sessions WHERE ` + where + ` RETURNING public_id)
+		INSERT INTO revoked_sessions (public_id) SELECT public_id FROM deleted
+		ON CONFLICT (public_id) DO UPDATE SET revoked_at = now()
+		RETURNING public_id`
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+// ErrLimitReached возвращается, если у ссылки исчерпан лимит скачиваний.
+var ErrLimitReached = errors.New("limit reached")
+
+// ShareRepository отвечает за операции с таблицей share_links.
+type ShareRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// NewShareRepository создает новый экземпляр ShareRepository.
+func NewShareRepository(db *pgxpool.Pool) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// shareColumns — список колонок, считываемых в models.ShareLink функцией scanShareLink.
+const shareColumns = `slug, uid, owner_uid, name, COALESCE(password_hash, ''), expires_at, max_downloads,
+	download_count, access_count, last_accessed_at, revoked_at, created_at`
+
+func scanShareLink(row interface{ Scan(...interface{}) error }) (*models.ShareLink, error) {
+	var l models.ShareLink
+	err := row.Scan(&l.Slug, &l.UID, &l.Owner, &l.Name, &l.PasswordHash, &l.ExpiresAt, &l.MaxDownloads,
+		&l.DownloadCount, &l.AccessCount, &l.LastAccessedAt, &l.RevokedAt, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	l.HasPassword = l.PasswordHash != ""
+	return &l, nil
+}
+
+// Create сохраняет новую ссылку и заполняет время её создания.
+// Если ссылка с таким slug уже есть, возвращается ErrAlreadyExists.
+func (r *ShareRepository) Create(ctx context.Context, l *models.ShareLink) error {
+	var passwordHash *string
+	if l.PasswordHash != "" {
+		passwordHash = &l.PasswordHash
+	}
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO share_links (slug, uid, owner_uid, name, password_hash, expires_at, max_downloads)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at`,
+		l.Slug, l.UID, l.Owner, l.Name, passwordHash, l.ExpiresAt, l.MaxDownloads,
+	).Scan(&l.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// FindBySlug ищет ссылку по slug.
+func (r *ShareRepository) FindBySlug(ctx context.Context, slug string) (*models.ShareLink, error) {
+	row := r.db.QueryRow(ctx, `SELECT `+shareColumns+` FROM share_links WHERE slug = $1`, slug)
+	return scanShareLink(row)
+}
+
+// ListByUID возвращает все ссылки, выпущенные пользователем, включая отозванные.
+func (r *ShareRepository) ListByUID(ctx context.Context, uid int64) ([]models.ShareLink, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+shareColumns+` FROM share_links WHERE uid = $1 ORDER BY created_at DESC`,
+		uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.ShareLink
+	for rows.Next() {
+		l, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *l)
+	}
+	return links, rows.Err()
+}
+
+// RecordAccess засчитывает обращение к ссылке, а если download — и скачивание.
+// Скачивание сверх max_downloads не засчитывается: тогда возвращается ErrLimitReached.
+func (r *ShareRepository) RecordAccess(ctx context.Context, slug string, download bool) error {
+	var n int
+	err := r.db.QueryRow(ctx,
+		`UPDATE share_links
+		 SET access_count = access_count + 1,
+		     download_count = download_count + CASE WHEN $2 THEN 1 ELSE 0 END,
+		     last_accessed_at = now()
+		 WHERE slug = $1 AND (NOT $2 OR max_downloads IS NULL OR download_count < max_downloads)
+		 RETURNING download_count`,
+		slug, download,
+	).Scan(&n)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLimitReached
+	}
+	return err
+}
+
+// Revoke отзывает ссылку пользователя. Возвращает false, если такой действующей ссылки у него нет.
+func (r *ShareRepository) Revoke(ctx context.Context, uid int64, slug string) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE share_links SET revoked_at = now() WHERE uid = $1 AND slug = $2 AND revoked_at IS NULL`,
+		uid, slug,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
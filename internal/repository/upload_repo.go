@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/models"
+)
+
+var (
+	// ErrOffsetMismatch возвращается, если часть загрузки пришла не с текущего смещения.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrTooManyUploads возвращается, если у пользователя уже максимальное число незавершённых загрузок.
+	ErrTooManyUploads = errors.New("too many pending uploads")
+)
+
+// UploadRepository отвечает за операции с таблицами uploads и upload_chunks.
+type UploadRepository struct {
+	db *pgxpool.Pool // Пул соединений с базой данных
+}
+
+// uploadColumns — колонки таблицы uploads в порядке, ожидаемом scanUpload.
+const uploadColumns = `id, uid, name, length, upload_offset, content_type, metadata, created_at, updated_at, expires_at`
+
+// scanUpload считывает одну строку с колонками uploadColumns.
+func scanUpload(row interface{ Scan(...interface{}) error }) (*models.Upload, error) {
+	var u models.Upload
+	err := row.Scan(&u.ID, &u.UID, &u.Name, &u.Length, &u.Offset, &u.ContentType, &u.Metadata,
+		&u.CreatedAt, &u.UpdatedAt, &u.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// NewUploadRepository создает новый экземпляр UploadRepository.
+func NewUploadRepository(db *pgxpool.Pool) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// Create сохраняет новую загрузку пользователя u.UID. Объявленный размер незавершённых
+// загрузок и файлы, которые они создадут, учитываются в квоте вместе с сохранёнными файлами
+// (см. pendingUsage): если с новой загрузкой квота на объём или число файлов была бы
+// превышена, возвращается ErrQuotaExceeded, а если у пользователя уже
+// maxPending незавершённых загрузок (0 — без ограничения) — ErrTooManyUploads.
+// Проверка и вставка выполняются под блокировкой счётчиков потребления (lockUsage),
+// поэтому параллельно созданные загрузки не могут вместе превысить квоту.
+func (r *UploadRepository) Create(ctx context.Context, u *models.Upload, maxPending int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUsage(ctx, tx, u.UID); err != nil {
+		return err
+	}
+	var (
+		pending int
+		fits    bool
+	)
+	// Новая загрузка добавляет файл, если его ещё нет и в него не собирается другая загрузка
+	err = tx.QueryRow(ctx,
+		`SELECT (SELECT count(*) FROM uploads WHERE uid = $1),
+		        (q.max_bytes IS NULL OR COALESCE(s.bytes, 0) + p.bytes + $2 <= q.max_bytes)
+		        AND (q.max_objects IS NULL OR COALESCE(s.objects, 0) + p.objects + 1 <= q.max_objects
+		             OR EXISTS (SELECT 1 FROM assets WHERE name = $3 AND uid = $1)
+		             OR EXISTS (SELECT 1 FROM uploads WHERE name = $3 AND uid = $1))
+		 FROM (`+effectiveQuota+`) q
+		 CROSS JOIN (`+pendingUsage("''")+`) p
+		 LEFT JOIN user_usage s ON s.uid = $1`,
+		u.UID, u.Length, u.Name,
+	).Scan(&pending, &fits)
+	if err != nil {
+		return err
+	}
+	if maxPending > 0 && pending >= maxPending {
+		return ErrTooManyUploads
+	}
+	if !fits {
+		return ErrQuotaExceeded
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO uploads (`+uploadColumns+`)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		u.ID, u.UID, u.Name, u.Length, u.Offset, u.ContentType, u.Metadata, u.CreatedAt, u.UpdatedAt, u.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Get возвращает загрузку по идентификатору, если она принадлежит пользователю uid.
+func (r *UploadRepository) Get(ctx context.Context, id string, uid int64) (*models.Upload, error) {
+	row := r.db.QueryRow(ctx,
+		`SELECT `+uploadColumns+`
+		 FROM uploads
+		 WHERE id = $1 AND uid = $2`,
+		id, uid,
+	)
+	return scanUpload(row)
+}
+
+// AddChunk атомарно регистрирует принятую часть: проверяет под блокировкой строки, что
+// текущее смещение загрузки совпадает со смещением части, сохраняет часть и сдвигает смещение.
+// Возвращает обновлённую загрузку либо ErrOffsetMismatch, если смещение успело измениться.
+func (r *UploadRepository) AddChunk(ctx context.Context, c *models.UploadChunk, expiresAt time.Time) (*models.Upload, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var offset int64
+	err = tx.QueryRow(ctx,
+		`SELECT upload_offset FROM uploads WHERE id = $1 FOR UPDATE`,
+		c.UploadID,
+	).Scan(&offset)
+	if err != nil {
+		return nil, err
+	}
+	if offset != c.Offset {
+		return nil, ErrOffsetMismatch
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO upload_chunks (upload_id, chunk_offset, size, storage_key)
+		 VALUES ($1, $2, $3, $4)`,
+		c.UploadID, c.Offset, c.Size, c.StorageKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := scanUpload(tx.QueryRow(ctx,
+		`UPDATE uploads
+		 SET upload_offset = upload_offset + $2, updated_at = now(), expires_at = $3
+		 WHERE id = $1
+		 RETURNING `+uploadColumns,
+		c.UploadID, c.Size, expiresAt,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return u, tx.Commit(ctx)
+}
+
+// ListChunks возвращает части загрузки в порядке возрастания смещения.
+func (r *UploadRepository) ListChunks(ctx context.Context, uploadID string) ([]models.UploadChunk, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT upload_id, chunk_offset, size, storage_key
+		 FROM upload_chunks
+		 WHERE upload_id = $1
+		 ORDER BY chunk_offset`,
+		uploadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []models.UploadChunk
+	for rows.Next() {
+		var c models.UploadChunk
+		if err := rows.Scan(&c.UploadID, &c.Offset, &c.Size, &c.StorageKey); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// Delete удаляет загрузку вместе с записями о её частях (ON DELETE CASCADE)
+// и возвращает ключи частей в BlobStore, которые нужно удалить из хранилища.
+func (r *UploadRepository) Delete(ctx context.Context, id string) ([]string, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH deleted AS (
+		     DELETE FROM uploads WHERE id = $1 RETURNING id
+		 )
+		 SELECT c.storage_key FROM upload_chunks c JOIN deleted d ON d.id = c.upload_id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collectStrings(rows)
+}
+
+// ListExpired возвращает идентификаторы загрузок, срок жизни которых истёк к моменту now.
+func (r *UploadRepository) ListExpired(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM uploads WHERE expires_at < $1`, now)
+	if err != nil {
+		return nil, err
+	}
+	return collectStrings(rows)
+}
+
+// ListIDsByUID возвращает идентификаторы всех незавершённых загрузок пользователя.
+func (r *UploadRepository) ListIDsByUID(ctx context.Context, uid int64) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM uploads WHERE uid = $1`, uid)
+	if err != nil {
+		return nil, err
+	}
+	return collectStrings(rows)
+}
+
+// collectStrings считывает все строки результата, состоящего из одной текстовой колонки.
+func collectStrings(rows pgx.Rows) ([]string, error) {
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
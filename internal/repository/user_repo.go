@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go-asset-service/internal/models"
@@ -17,43 +18,90 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// FindByLogin находит пользователя по логину.
-// Если пользователь найден, возвращает указатель на объект модели User, иначе — ошибку.
-func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*models.User, error) {
-	row := r.db.QueryRow(ctx, `
-		SELECT id, login, password_hash, created_at 
-		FROM users 
-		WHERE login = $1`, login)
+// userColumns — список колонок, считываемых в models.User функцией scanUser.
+const userColumns = `id, login, password_hash, role, disabled_at, created_at`
 
+// scanUser считывает строку с колонками userColumns.
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
 	var u models.User
-	err := row.Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt)
+	err := row.Scan(&u.ID, &u.Login, &u.PasswordHash, &u.Role, &u.DisabledAt, &u.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-// CreateUser создает нового пользователя в базе данных.
-// Пример реализации, которую можно доработать в зависимости от требований.
+// FindByLogin находит пользователя по логину.
+// Если пользователь найден, возвращает указатель на объект модели User, иначе — ошибку.
+func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	row := r.db.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE login = $1`, login)
+	return scanUser(row)
+}
+
+// CreateUser создает нового пользователя в базе данных и заполняет его ID и время создания.
+// Если логин занят, возвращается ErrAlreadyExists.
 func (r *UserRepository) CreateUser(ctx context.Context, u *models.User) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO users (login, password_hash, created_at)
-		VALUES ($1, $2, $3)`,
-		u.Login, u.PasswordHash, u.CreatedAt)
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO users (login, password_hash, role)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		u.Login, u.PasswordHash, u.Role,
+	).Scan(&u.ID, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
 	return err
 }
 
 // GetUserByID возвращает пользователя по его ID.
 func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
-	row := r.db.QueryRow(ctx, `
-		SELECT id, login, password_hash, created_at
-		FROM users
-		WHERE id = $1`, id)
+	row := r.db.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	return scanUser(row)
+}
 
-	var u models.User
-	err := row.Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt)
+// ListUsers возвращает всех пользователей в порядке создания.
+func (r *UserRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// UpdatePasswordHash заменяет хеш пароля пользователя (например, при переходе на новую схему хеширования).
+func (r *UserRepository) UpdatePasswordHash(ctx context.Context, id int64, hash string) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1`, id, hash)
+	return err
+}
+
+// UpdateRole меняет роль пользователя.
+func (r *UserRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1`, id, role)
+	return err
+}
+
+// SetDisabled блокирует учётную запись (disabledAt != nil) или снимает блокировку (nil).
+func (r *UserRepository) SetDisabled(ctx context.Context, id int64, disabledAt *time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET disabled_at = $2 WHERE id = $1`, id, disabledAt)
+	return err
+}
+
+// DeleteUser удаляет пользователя; связанные записи удаляются каскадно.
+// Возвращает false, если пользователя нет.
+func (r *UserRepository) DeleteUser(ctx context.Context, id int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
 }
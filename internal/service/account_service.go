@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/notify"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+var (
+	// ErrRegistrationDisabled возвращается, если самостоятельная регистрация выключена в конфигурации.
+	ErrRegistrationDisabled = errors.New("registration disabled")
+	// ErrWrongPassword возвращается, если при смене пароля указан неверный текущий пароль.
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrInvalidResetToken возвращается для неизвестного, истёкшего или уже использованного
+	// токена сброса пароля.
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+	// ErrExternalAccount возвращается при смене или сбросе пароля пользователя, который входит
+	// через провайдера OIDC и не имеет локального пароля.
+	ErrExternalAccount = errors.New("password is managed by the identity provider")
+)
+
+// AccountService реализует операции пользователя со своей учётной записью: самостоятельную
+// регистрацию, смену пароля и сброс забытого пароля по одноразовому токену, который
+// доставляется через Notifier.
+type AccountService struct {
+	userRepo    *repository.UserRepository          // Репозиторий пользователей
+	sessionRepo *repository.SessionRepository       // Репозиторий сессий (выход с других устройств)
+	jwtService  *JWTService                         // Отзыв токенов доступа JWT завершённых сессий
+	resetRepo   *repository.PasswordResetRepository // Репозиторий токенов сброса пароля
+	notifier    notify.Notifier                     // Доставка токенов сброса пароля
+	loginGuard  *LoginGuard                         // Ограничение попыток подбора текущего пароля
+
+	registrationEnabled bool          // Разрешена ли самостоятельная регистрация
+	resetTTL            time.Duration // Срок действия токена сброса пароля
+	passwordScheme      string        // Схема хеширования паролей
+}
+
+// NewAccountService создаёт новый экземпляр AccountService.
+func NewAccountService(u *repository.UserRepository, s *repository.SessionRepository, jwtService *JWTService, reset *repository.PasswordResetRepository, loginGuard *LoginGuard, notifier notify.Notifier, cfg *config.Config) *AccountService {
+	return &AccountService{
+		userRepo:            u,
+		sessionRepo:         s,
+		jwtService:          jwtService,
+		resetRepo:           reset,
+		notifier:            notifier,
+		loginGuard:          loginGuard,
+		registrationEnabled: cfg.RegistrationEnabled,
+		resetTTL:            cfg.PasswordResetTTL,
+		passwordScheme:      cfg.PasswordHashScheme,
+	}
+}
+
+// Register создаёт учётную запись с ролью user, если регистрация разрешена в конфигурации.
+// Логин и пароль проверяются по тем же правилам, что и при создании пользователя администратором.
+func (s *AccountService) Register(ctx context.Context, login, password string) (*models.User, error) {
+	if !s.registrationEnabled {
+		return nil, ErrRegistrationDisabled
+	}
+	if !validLogin(login) {
+		return nil, ErrInvalidUser
+	}
+	if err := checkPassword(login, password); err != nil {
+		return nil, err
+	}
+	hash, err := utils.HashPassword(s.passwordScheme, password)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &models.User{Login: login, PasswordHash: hash, Role: models.RoleUser}
+	err = s.userRepo.CreateUser(ctx, u)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ChangePassword меняет пароль пользователя, с сессии current которого выполнен запрос,
+// после проверки текущего пароля. Все остальные сессии пользователя и выданные ему токены
+// сброса пароля при этом становятся недействительными; текущая сессия сохраняется.
+// Неверный текущий пароль учитывается как неудачная попытка входа по логину пользователя
+// и адресу ip: иначе с перехваченной сессией пароль можно было бы подбирать без ограничений.
+// При превышении лимита возвращается *AttemptsError.
+func (s *AccountService) ChangePassword(ctx context.Context, current *models.Session, oldPassword, newPassword, ip string) error {
+	u, err := s.userRepo.GetUserByID(ctx, current.UID)
+	if err != nil {
+		return err
+	}
+	if u.PasswordHash == externalPasswordHash {
+		return ErrExternalAccount
+	}
+	attempt, err := s.loginGuard.Reserve(ctx, u.Login, ip)
+	if err != nil {
+		return err
+	}
+	ok, err := s.loginGuard.VerifyPassword(ctx, u.PasswordHash, oldPassword)
+	if err != nil || !ok {
+		return ErrWrongPassword
+	}
+	s.loginGuard.Release(ctx, attempt)
+	if err := s.setPassword(ctx, u, newPassword); err != nil {
+		return err
+	}
+	revoked, err := s.sessionRepo.DeleteOthers(ctx, u.ID, current.ID)
+	if err != nil {
+		return err
+	}
+	s.jwtService.Deny(revoked...)
+	return nil
+}
+
+// RequestPasswordReset выдаёт пользователю login одноразовый токен сброса пароля и отправляет
+// его через Notifier; выданный ранее токен при этом перестаёт действовать. Если пользователя
+// нет, он заблокирован или входит через провайдера OIDC, ничего не происходит и ошибка
+// не возвращается — по ответу нельзя узнать, существует ли учётная запись.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, login string) error {
+	u, err := s.userRepo.FindByLogin(ctx, login)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[INFO] Password reset requested for unknown login=%s", login)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if u.DisabledAt != nil {
+		log.Printf("[INFO] Password reset requested for disabled user=%d", u.ID)
+		return nil
+	}
+	if u.PasswordHash == externalPasswordHash {
+		log.Printf("[INFO] Password reset requested for external user=%d", u.ID)
+		return nil
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.resetTTL)
+	if err := s.resetRepo.Create(ctx, utils.HashToken(token), u.ID, expiresAt); err != nil {
+		return err
+	}
+
+	return s.notifier.Notify(ctx, notify.Message{
+		To:      u.Login,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("A password reset was requested for your account %q.\n"+
+			"To set a new password, send POST /api/auth/password-reset/confirm with "+
+			"{\"token\":\"%s\",\"new_password\":\"...\"} before %s.\n"+
+			"If you did not request this, ignore this message.",
+			u.Login, token, expiresAt.UTC().Format(time.RFC3339)),
+	})
+}
+
+// ResetPassword задаёт новый пароль по токену сброса. Токен одноразовый; после сброса все
+// сессии пользователя завершаются.
+func (s *AccountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	tokenHash := utils.HashToken(token)
+	now := time.Now()
+
+	// Сначала проверяем пароль, и только потом гасим токен: из-за слабого пароля
+	// пользователю не придётся запрашивать новый токен
+	uid, err := s.resetRepo.FindUID(ctx, tokenHash, now)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	u, err := s.userRepo.GetUserByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.DisabledAt != nil {
+		return ErrAccountDisabled
+	}
+	if u.PasswordHash == externalPasswordHash {
+		return ErrExternalAccount
+	}
+	if err := checkPassword(u.Login, newPassword); err != nil {
+		return err
+	}
+
+	if _, err := s.resetRepo.Consume(ctx, tokenHash, now); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := s.setPassword(ctx, u, newPassword); err != nil {
+		return err
+	}
+	revoked, err := s.sessionRepo.DeleteByUID(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	s.jwtService.Deny(revoked...)
+	return nil
+}
+
+// DeleteExpiredResetTokens удаляет истёкшие токены сброса пароля.
+// Вызывается периодически фоновой задачей.
+func (s *AccountService) DeleteExpiredResetTokens(ctx context.Context) error {
+	n, err := s.resetRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired password reset tokens", n)
+	}
+	return nil
+}
+
+// setPassword проверяет сложность нового пароля, сохраняет его хеш и гасит токены сброса пароля.
+func (s *AccountService) setPassword(ctx context.Context, u *models.User, password string) error {
+	if err := checkPassword(u.Login, password); err != nil {
+		return err
+	}
+	hash, err := utils.HashPassword(s.passwordScheme, password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePasswordHash(ctx, u.ID, hash); err != nil {
+		return err
+	}
+	return s.resetRepo.DeleteByUID(ctx, u.ID)
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+)
+
+// maxGroupNameLen — максимальная длина имени группы.
+const maxGroupNameLen = 64
+
+var (
+	// ErrGroupNotFound возвращается, если группы с указанным именем нет.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrGroupExists возвращается, если группа с таким именем уже существует.
+	ErrGroupExists = errors.New("group already exists")
+	// ErrInvalidGroupName возвращается при недопустимом имени группы.
+	ErrInvalidGroupName = errors.New("invalid group name")
+	// ErrNotGroupOwner возвращается, если составом группы пытается управлять не её владелец.
+	ErrNotGroupOwner = errors.New("not a group owner")
+	// ErrUserNotFound возвращается, если пользователя с указанным логином нет.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrMemberNotFound возвращается, если пользователь не состоит в группе.
+	ErrMemberNotFound = errors.New("group member not found")
+	// ErrInvalidGrant возвращается при некорректных параметрах выдачи права.
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrGrantExists возвращается, если такое право уже выдано.
+	ErrGrantExists = errors.New("grant already exists")
+	// ErrGrantNotFound возвращается, если у владельца нет записи доступа с указанным идентификатором.
+	ErrGrantNotFound = errors.New("grant not found")
+	// ErrAccessDenied возвращается, если у пользователя нет нужного права на чужой файл.
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// ACLService реализует совместный доступ к файлам: группы пользователей и списки доступа (ACL),
+// по которым владелец выдаёт другим пользователям и группам права read, write и delete
+// на отдельные файлы или на все файлы с префиксом.
+type ACLService struct {
+	aclRepo  *repository.ACLRepository  // Репозиторий групп и списков доступа
+	userRepo *repository.UserRepository // Репозиторий пользователей (поиск по логину)
+}
+
+// NewACLService создаёт новый экземпляр ACLService.
+func NewACLService(aclRepo *repository.ACLRepository, userRepo *repository.UserRepository) *ACLService {
+	return &ACLService{
+		aclRepo:  aclRepo,
+		userRepo: userRepo,
+	}
+}
+
+// Allowed проверяет, что пользователь uid может выполнить над файлом (или префиксом) name
+// владельца owner действие, требующее права permission. Владельцу разрешено всё.
+func (s *ACLService) Allowed(ctx context.Context, uid, owner int64, name, permission string) (bool, error) {
+	if uid == owner {
+		return true, nil
+	}
+	return s.aclRepo.HasPermission(ctx, uid, owner, name, permission)
+}
+
+// CreateGroup создаёт группу, владельцем которой становится пользователь uid.
+func (s *ACLService) CreateGroup(ctx context.Context, uid int64, name string) (*models.Group, error) {
+	if !validGroupName(name) {
+		return nil, ErrInvalidGroupName
+	}
+	g := &models.Group{Name: name, OwnerUID: uid, Members: []string{}}
+	err := s.aclRepo.CreateGroup(ctx, g)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrGroupExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ListGroups возвращает группы, которыми пользователь владеет или в которых состоит.
+func (s *ACLService) ListGroups(ctx context.Context, uid int64) ([]models.Group, error) {
+	return s.aclRepo.ListGroups(ctx, uid)
+}
+
+// DeleteGroup удаляет группу пользователя вместе со всеми выданными ей правами.
+func (s *ACLService) DeleteGroup(ctx context.Context, uid int64, name string) error {
+	ok, err := s.aclRepo.DeleteGroup(ctx, uid, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// AddMember добавляет пользователя login в группу. Составом группы управляет только её владелец.
+func (s *ACLService) AddMember(ctx context.Context, uid int64, group, login string) error {
+	g, err := s.findGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	if g.OwnerUID != uid {
+		return ErrNotGroupOwner
+	}
+	member, err := s.findUser(ctx, login)
+	if err != nil {
+		return err
+	}
+	return s.aclRepo.AddMember(ctx, g.ID, member.ID)
+}
+
+// RemoveMember исключает пользователя login из группы. Исключать участников может владелец
+// группы, а любой участник может выйти из группы сам.
+func (s *ACLService) RemoveMember(ctx context.Context, uid int64, group, login string) error {
+	g, err := s.findGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	member, err := s.findUser(ctx, login)
+	if err != nil {
+		return err
+	}
+	if g.OwnerUID != uid && member.ID != uid {
+		return ErrNotGroupOwner
+	}
+	ok, err := s.aclRepo.RemoveMember(ctx, g.ID, member.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// Grant выдаёт право permission на файл name (или на все файлы с префиксом, если name
+// заканчивается на «/») пользователя uid другому пользователю login или группе group —
+// должно быть задано ровно одно из них.
+func (s *ACLService) Grant(ctx context.Context, uid int64, name, permission, login, group string) (*models.Grant, error) {
+	if !ValidAssetName(strings.TrimSuffix(name, "/")) || !validPermission(permission) || (login == "") == (group == "") {
+		return nil, ErrInvalidGrant
+	}
+
+	g := &models.Grant{OwnerUID: uid, Name: name, Permission: permission}
+	if login != "" {
+		grantee, err := s.findUser(ctx, login)
+		if err != nil {
+			return nil, err
+		}
+		if grantee.ID == uid {
+			return nil, ErrInvalidGrant
+		}
+		g.GranteeUID, g.GranteeLogin = &grantee.ID, grantee.Login
+	} else {
+		gr, err := s.findGroup(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		g.GroupID, g.GroupName = &gr.ID, gr.Name
+	}
+
+	err := s.aclRepo.CreateGrant(ctx, g)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrGrantExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ListGrants возвращает права, выданные пользователем на свои файлы.
+func (s *ACLService) ListGrants(ctx context.Context, uid int64) ([]models.Grant, error) {
+	return s.aclRepo.ListGrants(ctx, uid)
+}
+
+// Revoke отзывает выданное пользователем право.
+func (s *ACLService) Revoke(ctx context.Context, uid, id int64) error {
+	ok, err := s.aclRepo.DeleteGrant(ctx, uid, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+func (s *ACLService) findGroup(ctx context.Context, name string) (*models.Group, error) {
+	g, err := s.aclRepo.GetGroupByName(ctx, name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrGroupNotFound
+	}
+	return g, err
+}
+
+func (s *ACLService) findUser(ctx context.Context, login string) (*models.User, error) {
+	u, err := s.userRepo.FindByLogin(ctx, login)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+// validGroupName проверяет имя группы: латинские буквы, цифры, '-', '_' и '.'.
+func validGroupName(name string) bool {
+	if name == "" || len(name) > maxGroupNameLen {
+		return false
+	}
+	return strings.IndexFunc(name, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.')
+	}) < 0
+}
+
+func validPermission(permission string) bool {
+	for _, p := range models.AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// APIKeyPrefix — префикс, по которому API-ключи отличаются от токенов сессий.
+const APIKeyPrefix = "ak_"
+
+var (
+	// ErrAPIKeyNotFound возвращается, если у пользователя нет ключа с указанным идентификатором.
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKey возвращается при некорректных параметрах создания ключа.
+	ErrInvalidAPIKey = errors.New("invalid api key parameters")
+)
+
+// APIKeyService реализует выпуск, проверку и отзыв API-ключей машинных клиентов.
+type APIKeyService struct {
+	keyRepo *repository.APIKeyRepository // Репозиторий API-ключей
+}
+
+// NewAPIKeyService создаёт новый экземпляр APIKeyService.
+func NewAPIKeyService(keyRepo *repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{keyRepo: keyRepo}
+}
+
+// IsAPIKey сообщает, что токен из заголовка Authorization является API-ключом.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Create выпускает новый API-ключ. Возвращает сам ключ (показывается владельцу только один раз)
+// и его описание. В БД сохраняется только хеш ключа.
+func (s *APIKeyService) Create(ctx context.Context, uid int64, name string, scopes, namePrefixes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if name == "" || len(scopes) == 0 || !validScopes(scopes) {
+		return "", nil, ErrInvalidAPIKey
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return "", nil, ErrInvalidAPIKey
+	}
+	if namePrefixes == nil {
+		namePrefixes = []string{}
+	}
+
+	secret, err := utils.GenerateToken(24)
+	if err != nil {
+		return "", nil, err
+	}
+	key := APIKeyPrefix + secret
+
+	k := &models.APIKey{
+		UID:          uid,
+		Name:         name,
+		Prefix:       key[:len(APIKeyPrefix)+8],
+		KeyHash:      utils.HashToken(key),
+		Scopes:       scopes,
+		NamePrefixes: namePrefixes,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.keyRepo.Create(ctx, k); err != nil {
+		return "", nil, err
+	}
+	return key, k, nil
+}
+
+// Authenticate проверяет API-ключ и возвращает его описание.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	k, err := s.keyRepo.FindByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	// Время последнего использования обновляется не чаще раза в sessionTouchInterval
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > sessionTouchInterval {
+		if err := s.keyRepo.Touch(ctx, k.ID, now); err != nil {
+			log.Printf("[WARN] Failed to update api key last_used_at: %v", err)
+		} else {
+			k.LastUsedAt = &now
+		}
+	}
+	return k, nil
+}
+
+// List возвращает API-ключи пользователя (без самих ключей).
+func (s *APIKeyService) List(ctx context.Context, uid int64) ([]models.APIKey, error) {
+	return s.keyRepo.ListByUID(ctx, uid)
+}
+
+// Revoke удаляет API-ключ пользователя.
+func (s *APIKeyService) Revoke(ctx context.Context, uid, id int64) error {
+	ok, err := s.keyRepo.Delete(ctx, uid, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func validScopes(scopes []string) bool {
+	for _, s := range scopes {
+		known := false
+		for _, a := range models.AllScopes {
+			if s == a {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/internal/storage"
+	"go-asset-service/pkg/utils"
+)
+
+var (
+	// ErrAssetNotFound возвращается, если у пользователя нет asset с указанным именем.
+	ErrAssetNotFound = errors.New("asset not found")
+	// ErrVersionNotFound возвращается, если у asset нет версии с указанным номером.
+	ErrVersionNotFound = errors.New("asset version not found")
+	// ErrInvalidCursor возвращается, если курсор страницы повреждён или выдан для другой сортировки.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidListOptions возвращается при неизвестном поле сортировки списка файлов
+	// или сортировке не по имени при листинге с разделителем.
+	ErrInvalidListOptions = errors.New("invalid list options")
+	// ErrInvalidAssetName возвращается при недопустимом имени файла или префикса.
+	ErrInvalidAssetName = errors.New("invalid asset name")
+	// ErrAssetExists возвращается, если при переносе целевое имя уже занято.
+	ErrAssetExists = errors.New("asset already exists")
+)
+
+// AssetService реализует бизнес-логику работы с файлами: содержимое хранится в BlobStore,
+// а в Postgres — только метаданные и ключ объекта в хранилище. Повторная загрузка под тем же
+// именем создаёт новую версию файла; предыдущие версии сохраняются до явной очистки.
+// Содержимое дедуплицируется по SHA-256: одинаковые файлы разных пользователей и под
+// разными именами хранятся в одном экземпляре.
+// Загрузки учитываются в потреблении владельца и ограничиваются его квотой.
+type AssetService struct {
+	assetRepo *repository.AssetRepository // Репозиторий метаданных файлов
+	quotaRepo *repository.QuotaRepository // Квоты и счётчики потребления пользователей
+	store     storage.BlobStore           // Хранилище содержимого файлов
+}
+
+// NewAssetService создаёт новый экземпляр AssetService.
+func NewAssetService(assetRepo *repository.AssetRepository, quotaRepo *repository.QuotaRepository, store storage.BlobStore) *AssetService {
+	return &AssetService{
+		assetRepo: assetRepo,
+		quotaRepo: quotaRepo,
+		store:     store,
+	}
+}
+
+// CheckQuota проверяет, может ли пользователь uid загрузить файл name размером size байт
+// (-1, если размер неизвестен), не превысив квоту с учётом резерва незавершённых загрузок.
+// Вызывается до чтения тела запроса, чтобы
+// заведомо не помещающийся файл отклонялся сразу; окончательная проверка выполняется
+// при сохранении версии в Upload.
+func (s *AssetService) CheckQuota(ctx context.Context, uid int64, name string, size int64) error {
+	_, err := s.uploadLimit(ctx, uid, name, size, "")
+	return err
+}
+
+// uploadLimit проверяет квоту пользователя uid для загрузки файла name размером size
+// (-1 — неизвестен) и возвращает, сколько байт можно принять, или -1, если ограничения нет.
+// Место, зарезервированное незавершёнными загрузками (кроме загрузки uploadID, из которой
+// собирается файл), считается занятым. Возвращает ErrFileTooLarge или ErrQuotaExceeded,
+// если файл заведомо не поместится.
+func (s *AssetService) uploadLimit(ctx context.Context, uid int64, name string, size int64, uploadID string) (int64, error) {
+	usage, err := s.quotaRepo.UsageExcept(ctx, uid, uploadID)
+	if err != nil {
+		return 0, err
+	}
+	q := usage.Quota
+
+	limit := int64(-1)
+	if q.MaxFileSize != nil {
+		limit = *q.MaxFileSize
+		if size > limit {
+			return 0, ErrFileTooLarge
+		}
+	}
+	if q.MaxBytes != nil {
+		remaining := *q.MaxBytes - usage.Bytes - usage.ReservedBytes
+		if remaining < 0 || size > remaining || (size < 0 && remaining == 0) {
+			return 0, ErrQuotaExceeded
+		}
+		if limit < 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	if q.MaxObjects != nil && usage.Objects+usage.ReservedObjects >= *q.MaxObjects {
+		// Новая версия существующего файла не увеличивает число файлов
+		_, err := s.assetRepo.GetAsset(ctx, name, uid)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrQuotaExceeded
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return limit, nil
+}
+
+// Upload потоково записывает содержимое body в хранилище и сохраняет его как новую
+// (текущую) версию asset.
+// По мере записи вычисляется SHA-256 содержимого, который затем служит ETag'ом.
+// size — значение Content-Length запроса, либо -1, если оно неизвестно.
+// contentType — MIME-тип из запроса; если он не указан, тип определяется по первым байтам содержимого.
+// metadata — пользовательские метаданные версии.
+// Если метаданные сохранить не удалось, уже записанный объект удаляется из хранилища;
+// он удаляется и тогда, когда такое же содержимое уже хранится (дубликат).
+// Тело читается не дальше остатка квоты владельца: если файл в неё не помещается, возвращается
+// ErrFileTooLarge или ErrQuotaExceeded.
+func (s *AssetService) Upload(ctx context.Context, uid int64, name string, body io.Reader, size int64, contentType string, metadata map[string]string) (*models.Asset, error) {
+	return s.upload(ctx, uid, name, body, size, contentType, metadata, "")
+}
+
+// upload реализует Upload. uploadID — возобновляемая загрузка, из которой собирается файл:
+// её резерв в квоте занимает сам файл (пусто — прямая загрузка).
+func (s *AssetService) upload(ctx context.Context, uid int64, name string, body io.Reader, size int64, contentType string, metadata map[string]string, uploadID string) (*models.Asset, error) {
+	if !ValidAssetName(name) {
+		return nil, ErrInvalidAssetName
+	}
+	limit, err := s.uploadLimit(ctx, uid, name, size, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 {
+		// Лишний байт позволяет отличить файл ровно на остаток квоты от превышающего его
+		body = io.LimitReader(body, limit+1)
+	}
+	if contentType == "" {
+		var err error
+		if contentType, body, err = sniffContentType(body); err != nil {
+			return nil, err
+		}
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	key, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	written, err := s.store.Put(ctx, key, io.TeeReader(body, hash), size)
+	if err != nil {
+		s.deleteBlob(key)
+		return nil, err
+	}
+	if limit >= 0 && written > limit {
+		s.deleteBlob(key)
+		return nil, s.exceededError(ctx, uid, written)
+	}
+
+	now := time.Now()
+	asset := &models.Asset{
+		Name:        name,
+		UID:         uid,
+		StorageKey:  key,
+		Size:        written,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		ContentType: contentType,
+		Metadata:    metadata,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.assetRepo.CreateVersion(ctx, asset, uploadID); err != nil {
+		s.deleteBlob(key)
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			// Квоту успела исчерпать параллельная загрузка или её уменьшил администратор
+			return nil, s.exceededError(ctx, uid, written)
+		}
+		return nil, err
+	}
+	if asset.StorageKey != key {
+		// Такое содержимое уже есть в хранилище — версия ссылается на него, копия не нужна
+		s.deleteBlob(key)
+	}
+	return asset, nil
+}
+
+// Open возвращает метаданные текущей версии asset и поток для чтения её содержимого
+// с поддержкой Seek. Вызывающий обязан закрыть поток.
+func (s *AssetService) Open(ctx context.Context, uid int64, name string) (*models.Asset, io.ReadSeekCloser, error) {
+	asset, err := s.assetRepo.GetAsset(ctx, name, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.open(ctx, asset)
+}
+
+// OpenVersion возвращает метаданные и содержимое указанной версии asset.
+func (s *AssetService) OpenVersion(ctx context.Context, uid int64, name string, version int) (*models.Asset, io.ReadSeekCloser, error) {
+	asset, err := s.assetRepo.GetVersion(ctx, name, uid, version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.open(ctx, asset)
+}
+
+// open открывает содержимое версии asset в хранилище.
+func (s *AssetService) open(ctx context.Context, asset *models.Asset) (*models.Asset, io.ReadSeekCloser, error) {
+	content, err := s.store.Get(ctx, asset.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return asset, content, nil
+}
+
+// maxAssetNameLen — максимальная длина имени файла в байтах.
+const maxAssetNameLen = 1024
+
+// Размер страницы списка файлов по умолчанию и максимальный.
+const (
+	defaultAssetPageSize = 100
+	maxAssetPageSize     = 1000
+)
+
+// assetCursor — содержимое курсора следующей страницы: сортировка, с которой он выдан,
+// и ключ сортировки последнего файла страницы.
+type assetCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	Name      string    `json:"n"`
+	UID       int64     `json:"u,omitempty"`
+	Size      int64     `json:"z,omitempty"`
+	CreatedAt time.Time `json:"t,omitempty"`
+}
+
+// List возвращает страницу списка файлов пользователя согласно opts вместе с курсором
+// следующей страницы (пустым, если страница последняя). В список попадают и чужие файлы,
+// право на чтение которых выдано пользователю (ACL), если opts.Owner не ограничивает владельца.
+// Если задан opts.Delimiter, вложенные уровни имён сворачиваются в общие префиксы; такой список
+// сортируется только по имени и строится по именам одного владельца (по умолчанию — самого пользователя).
+func (s *AssetService) List(ctx context.Context, uid int64, opts models.AssetListOptions) (*models.AssetPage, error) {
+	switch opts.Sort {
+	case "":
+		opts.Sort = models.AssetSortName
+	case models.AssetSortName, models.AssetSortSize, models.AssetSortCreatedAt:
+	default:
+		return nil, ErrInvalidListOptions
+	}
+	if opts.Delimiter != "" && opts.Sort != models.AssetSortName {
+		return nil, ErrInvalidListOptions
+	}
+	if opts.Delimiter != "" && opts.Owner == 0 {
+		opts.Owner = uid
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultAssetPageSize
+	}
+	if limit > maxAssetPageSize {
+		limit = maxAssetPageSize
+	}
+
+	var after *assetCursor
+	if opts.Cursor != "" {
+		c, err := decodeAssetCursor(opts.Cursor)
+		if err != nil || c.Sort != opts.Sort || c.Desc != opts.Desc {
+			return nil, ErrInvalidCursor
+		}
+		after = c
+	}
+	if opts.Delimiter != "" {
+		return s.listTree(ctx, uid, opts, after, limit)
+	}
+
+	var afterAsset *models.Asset
+	if after != nil {
+		afterAsset = &models.Asset{Name: after.Name, UID: after.UID, Size: after.Size, CreatedAt: after.CreatedAt}
+	}
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	assets, err := s.assetRepo.ListAssets(ctx, uid, opts, afterAsset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.AssetPage{Assets: assets}
+	if len(assets) <= limit {
+		return page, nil
+	}
+	page.Assets = assets[:limit]
+	last := page.Assets[limit-1]
+	page.NextCursor, err = encodeAssetCursor(assetCursor{
+		Sort:      opts.Sort,
+		Desc:      opts.Desc,
+		Name:      last.Name,
+		UID:       last.UID,
+		Size:      last.Size,
+		CreatedAt: last.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// listTree возвращает страницу списка с разделителем: файлы текущего уровня и общие префиксы.
+func (s *AssetService) listTree(ctx context.Context, uid int64, opts models.AssetListOptions, after *assetCursor, limit int) (*models.AssetPage, error) {
+	afterKey := ""
+	if after != nil {
+		afterKey = after.Name
+	}
+	entries, err := s.assetRepo.ListTree(ctx, uid, opts, opts.Delimiter, afterKey, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AssetPage{}
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	for _, e := range entries {
+		if e.Asset != nil {
+			page.Assets = append(page.Assets, *e.Asset)
+		} else {
+			page.CommonPrefixes = append(page.CommonPrefixes, e.Prefix)
+		}
+	}
+	if hasMore {
+		last := entries[limit-1]
+		key := last.Prefix
+		if last.Asset != nil {
+			key = last.Asset.Name
+		}
+		page.NextCursor, err = encodeAssetCursor(assetCursor{Sort: opts.Sort, Desc: opts.Desc, Name: key})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func encodeAssetCursor(c assetCursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeAssetCursor(cursor string) (*assetCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var c assetCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListVersions возвращает все версии asset (от новой к старой) и номер текущей версии.
+func (s *AssetService) ListVersions(ctx context.Context, uid int64, name string) ([]models.Asset, int, error) {
+	current, err := s.assetRepo.GetAsset(ctx, name, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	versions, err := s.assetRepo.ListVersions(ctx, name, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+	return versions, current.Version, nil
+}
+
+// Restore делает одну из прежних версий asset текущей. История версий при этом
+// не меняется: следующая загрузка получит номер после максимального.
+func (s *AssetService) Restore(ctx context.Context, uid int64, name string, version int) (*models.Asset, error) {
+	asset, err := s.assetRepo.SetCurrentVersion(ctx, name, uid, version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.assetRepo.GetAsset(ctx, name, uid); errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAssetNotFound
+		}
+		return nil, ErrVersionNotFound
+	}
+	return asset, err
+}
+
+// PruneVersions удаляет прежние версии asset: все, кроме keep самых новых (если keep > 0),
+// и старше maxAge (если maxAge > 0). Текущая версия сохраняется всегда. Содержимое
+// удаляется из хранилища, только если на него больше никто не ссылается.
+// Возвращает число удалённых версий.
+func (s *AssetService) PruneVersions(ctx context.Context, uid int64, name string, keep int, maxAge time.Duration) (int, error) {
+	if _, err := s.assetRepo.GetAsset(ctx, name, uid); errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrAssetNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	var olderThan time.Time
+	if maxAge > 0 {
+		olderThan = time.Now().Add(-maxAge)
+	}
+	n, keys, err := s.assetRepo.PruneVersions(ctx, name, uid, keep, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+	return n, nil
+}
+
+// Move переименовывает файл from в to вместе с историей версий. Если from и to заканчиваются
+// на «/», переносятся все файлы с префиксом from (переименование «папки»).
+// Возвращает число перенесённых файлов.
+func (s *AssetService) Move(ctx context.Context, uid int64, from, to string) (int64, error) {
+	fromDir, toDir := strings.HasSuffix(from, "/"), strings.HasSuffix(to, "/")
+	if fromDir != toDir || from == to {
+		return 0, ErrInvalidAssetName
+	}
+	if !fromDir {
+		if !ValidAssetName(to) {
+			return 0, ErrInvalidAssetName
+		}
+		err := s.assetRepo.Move(ctx, uid, from, to)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrAssetNotFound
+		}
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			return 0, ErrAssetExists
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	// Перенос папки внутрь самой себя мог бы временно пересечься по именам с ещё не перенесёнными файлами
+	if !ValidAssetName(strings.TrimSuffix(to, "/")) || strings.HasPrefix(to, from) {
+		return 0, ErrInvalidAssetName
+	}
+	n, err := s.assetRepo.MovePrefix(ctx, uid, from, to)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return 0, ErrAssetExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrAssetNotFound
+	}
+	return n, nil
+}
+
+// DeletePrefix рекурсивно удаляет все файлы с префиксом prefix (содержимое «папки») со всеми
+// версиями и возвращает их число. Префикс должен заканчиваться на «/».
+func (s *AssetService) DeletePrefix(ctx context.Context, uid int64, prefix string) (int64, error) {
+	if !strings.HasSuffix(prefix, "/") || !ValidAssetName(strings.TrimSuffix(prefix, "/")) {
+		return 0, ErrInvalidAssetName
+	}
+	n, keys, err := s.assetRepo.DeletePrefix(ctx, uid, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrAssetNotFound
+	}
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+	return n, nil
+}
+
+// DeleteAll удаляет все файлы пользователя со всеми версиями и возвращает их число.
+// Используется перед удалением учётной записи: каскадное удаление в БД не уменьшило бы
+// счётчики ссылок на содержимое, и объекты остались бы в хранилище навсегда.
+func (s *AssetService) DeleteAll(ctx context.Context, uid int64) (int64, error) {
+	n, keys, err := s.assetRepo.DeletePrefix(ctx, uid, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+	return n, nil
+}
+
+// ValidAssetName проверяет имя файла: непустые сегменты через «/», без «.» и «..»,
+// без «/» в начале и в конце.
+func ValidAssetName(name string) bool {
+	if name == "" || len(name) > maxAssetNameLen {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Delete удаляет asset со всеми версиями, а затем из хранилища — содержимое,
+// на которое больше не ссылаются другие версии и файлы.
+func (s *AssetService) Delete(ctx context.Context, uid int64, name string) error {
+	keys, err := s.assetRepo.DeleteAsset(ctx, name, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAssetNotFound
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+	return nil
+}
+
+// exceededError возвращает ErrFileTooLarge, если файл размером size больше допустимого
+// для пользователя uid, и ErrQuotaExceeded в остальных случаях.
+func (s *AssetService) exceededError(ctx context.Context, uid, size int64) error {
+	usage, err := s.quotaRepo.Usage(ctx, uid)
+	if err == nil && usage.Quota.MaxFileSize != nil && size > *usage.Quota.MaxFileSize {
+		return ErrFileTooLarge
+	}
+	return ErrQuotaExceeded
+}
+
+// sniffContentType определяет MIME-тип по первым 512 байтам содержимого (как http.DetectContentType)
+// и возвращает поток, из которого эти байты можно прочитать повторно.
+func sniffContentType(body io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), body), nil
+}
+
+// deleteBlob удаляет объект из хранилища. Ошибка только логируется: запись в БД уже
+// отсутствует, и «осиротевший» объект не влияет на корректность работы сервиса.
+func (s *AssetService) deleteBlob(key string) {
+	if err := s.store.Delete(context.Background(), key); err != nil {
+		log.Printf("[WARN] Failed to delete blob key=%s: %v", key, err)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+)
+
+// Размер страницы журнала аудита по умолчанию и максимальный, а также размер пачки
+// записей при проверке цепочки.
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	auditVerifyBatch     = 1000
+)
+
+// AuditService ведёт журнал аудита: добавляет записи в цепочку хешей, выдаёт их с фильтрами
+// и проверяет, что цепочка не нарушена.
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+// NewAuditService создает новый экземпляр AuditService.
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record добавляет запись e в журнал, заполняя время, идентификатор и хеши.
+func (s *AuditService) Record(ctx context.Context, e *models.AuditEvent) error {
+	// Время хранится в БД с точностью до микросекунд: хеш считается от того же значения,
+	// которое потом будет прочитано при проверке
+	e.Time = time.Now().UTC().Truncate(time.Microsecond)
+	return s.auditRepo.Append(ctx, e, auditHash)
+}
+
+// List возвращает страницу журнала по фильтру f, от новых записей к старым.
+func (s *AuditService) List(ctx context.Context, f models.AuditFilter) (*models.AuditPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	events, err := s.auditRepo.List(ctx, f, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = strconv.FormatInt(page.Events[limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+// Verify проходит журнал от первой записи до последней и проверяет, что каждая запись ссылается
+// на хеш предыдущей и её собственный хеш соответствует содержимому. Возвращает первую запись,
+// на которой цепочка нарушена. Удаление записей из конца журнала цепочка не выявляет:
+// от него защищают триггеры в БД.
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+	var afterID int64
+	prev := ""
+	for {
+		events, err := s.auditRepo.ListAfter(ctx, afterID, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			e := &events[i]
+			if e.PrevHash != prev || auditHash(e) != e.Hash {
+				result.Valid = false
+				result.BrokenAt = e.ID
+				return result, nil
+			}
+			prev = e.Hash
+			result.Checked++
+		}
+		if len(events) < auditVerifyBatch {
+			return result, nil
+		}
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// auditHashInput — содержимое записи журнала, от которого считается её хеш.
+type auditHashInput struct {
+	PrevHash string            `json:"prev_hash"`
+	Time     string            `json:"time"`
+	UID      *int64            `json:"uid"`
+	Action   string            `json:"action"`
+	Method   string            `json:"method"`
+	Target   string            `json:"target"`
+	Owner    *int64            `json:"owner"`
+	IP       string            `json:"ip"`
+	Result   string            `json:"result"`
+	Status   int               `json:"status"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// auditHash вычисляет хеш записи: SHA-256 (hex) от JSON с её содержимым и хешем предыдущей
+// записи. Ключи details сериализуются в порядке сортировки, поэтому хеш не зависит
+// от порядка, в котором БД вернёт JSON.
+func auditHash(e *models.AuditEvent) string {
+	data, _ := json.Marshal(auditHashInput{
+		PrevHash: e.PrevHash,
+		Time:     e.Time.UTC().Format(time.RFC3339Nano),
+		UID:      e.UID,
+		Action:   e.Action,
+		Method:   e.Method,
+		Target:   e.Target,
+		Owner:    e.Owner,
+		IP:       e.IP,
+		Result:   e.Result,
+		Status:   e.Status,
+		Details:  e.Details,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
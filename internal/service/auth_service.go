@@ -3,8 +3,10 @@ package service
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
+	"go-asset-service/internal/config"
 	"go-asset-service/internal/models"
 	"go-asset-service/internal/repository"
 	"go-asset-service/pkg/utils"
@@ -14,75 +16,481 @@ import (
 type AuthService struct {
 	userRepo    *repository.UserRepository    // Репозиторий для поиска пользователей
 	sessionRepo *repository.SessionRepository // Репозиторий для работы с сессиями
+	loginGuard  *LoginGuard                   // Защита от перебора паролей
+	mfaService  *MFAService                   // Второй фактор
+	jwtService  *JWTService                   // Токены доступа в формате JWT
 
-	sessionTTL time.Duration // Максимальное время жизни сессии (например, 24 часа)
+	idleTimeout     time.Duration // Токен доступа истекает после этого времени без активности
+	maxLifetime     time.Duration // Абсолютный срок жизни сессии с момента входа
+	refreshTokenTTL time.Duration // Срок действия refresh-токена с момента выдачи
+	passwordScheme  string        // Схема хеширования паролей (argon2id или bcrypt)
+	passwordLogin   bool          // Разрешён ли вход по паролю
+	dummyHash       string        // Хеш случайного пароля, проверяемый при входе с неизвестным логином
 }
 
 // NewAuthService создает новый экземпляр AuthService.
-func NewAuthService(u *repository.UserRepository, s *repository.SessionRepository) *AuthService {
+func NewAuthService(u *repository.UserRepository, s *repository.SessionRepository, guard *LoginGuard, mfa *MFAService, jwt *JWTService, cfg *config.Config) *AuthService {
+	// Для неизвестного логина пароль проверяется по хешу той же схемы, что и у настоящих
+	// пользователей, чтобы время ответа не выдавало, существует ли логин
+	dummyHash, err := newDummyHash(cfg.PasswordHashScheme)
+	if err != nil {
+		log.Printf("[WARN] Failed to prepare dummy password hash: %v", err)
+	}
 	return &AuthService{
-		userRepo:    u,
-		sessionRepo: s,
-		sessionTTL:  24 * time.Hour, // Ограничение 24 часа для пользовательской сессии
+		userRepo:        u,
+		sessionRepo:     s,
+		loginGuard:      guard,
+		mfaService:      mfa,
+		jwtService:      jwt,
+		idleTimeout:     cfg.SessionIdleTimeout,
+		maxLifetime:     cfg.SessionMaxLifetime,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		passwordScheme:  cfg.PasswordHashScheme,
+		passwordLogin:   cfg.PasswordLoginEnabled,
+		dummyHash:       dummyHash,
+	}
+}
+
+// newDummyHash хеширует случайный пароль по схеме scheme.
+func newDummyHash(scheme string) (string, error) {
+	password, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", err
 	}
+	return utils.HashPassword(scheme, password)
+}
+
+var (
+	// ErrSessionNotFound возвращается, если у пользователя нет сессии с указанным идентификатором.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrInvalidToken возвращается для неизвестного токена доступа или refresh-токена.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrSessionExpired возвращается, если сессия истекла по неактивности или по абсолютному сроку.
+	ErrSessionExpired = errors.New("session expired")
+	// ErrRefreshTokenReused возвращается при повторном предъявлении уже обменянного refresh-токена;
+	// сессия, которой он принадлежал, при этом отзывается.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrPasswordLoginDisabled возвращается, если вход по паролю выключен в конфигурации
+	// и пользователи входят только через внешнего провайдера.
+	ErrPasswordLoginDisabled = errors.New("password login disabled")
+
+	// errInvalidCredentials возвращается при неизвестном логине или неверном пароле.
+	errInvalidCredentials = errors.New("invalid login/password")
+)
+
+// Tokens — набор токенов, выдаваемый при входе и при обмене refresh-токена.
+type Tokens struct {
+	UID              int64     // Пользователь, которому выданы токены
+	AccessToken      string    // Токен доступа (session ID или JWT) для заголовка Authorization
+	RefreshToken     string    // Одноразовый токен для получения новой пары токенов
+	AccessExpiresAt  time.Time // Токен доступа истечёт в это время, если им не пользоваться
+	RefreshExpiresAt time.Time // Срок действия refresh-токена
+
+	// Роль пользователя требует второй фактор, а он не подключён: до подключения
+	// пользователю доступно только чтение
+	MFAEnrollmentRequired bool
+}
+
+// PendingMFA — результат входа по паролю, когда нужен ещё код второго фактора.
+type PendingMFA struct {
+	Token     string    // Одноразовый токен для LoginMFA
+	ExpiresAt time.Time // Срок действия токена
 }
 
+// sessionTouchInterval — как часто обновляется время последнего использования сессии.
+// Запись при каждом запросе не нужна: достаточно точности в пределах этого интервала.
+const sessionTouchInterval = time.Minute
+
 // Login осуществляет аутентификацию пользователя.
-// Принимает логин, пароль и IP-адрес клиента. Если аутентификация успешна,
-// удаляются предыдущие сессии пользователя (чтобы оставалась только одна активная),
-// генерируется новый session ID, создается новая сессия и возвращается session ID.
-func (as *AuthService) Login(ctx context.Context, login, password, ip string) (string, error) {
+// Принимает логин, пароль, IP-адрес клиента и метку устройства. Если аутентификация успешна,
+// создается новая сессия и возвращаются её токен доступа и refresh-токен.
+// Другие сессии пользователя при этом сохраняются: можно одновременно работать с нескольких устройств.
+// После неудачных попыток по логину или с IP-адреса вход временно запрещается (*AttemptsError).
+// Если у пользователя подключён второй фактор, нужен ещё его код: либо сразу в mfaCode,
+// либо вторым шагом — тогда вместо токенов возвращается PendingMFA для LoginMFA.
+func (as *AuthService) Login(ctx context.Context, login, password, mfaCode, ip, device string) (*Tokens, *PendingMFA, error) {
+	if !as.passwordLogin {
+		return nil, nil, ErrPasswordLoginDisabled
+	}
+
+	// Пока действует задержка или блокировка после неудачных попыток, пароль даже не проверяем;
+	// иначе попытка заранее засчитывается как неудачная и отменяется, только если пароль верен
+	attempt, err := as.loginGuard.Reserve(ctx, login, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Поиск пользователя по логину
 	user, err := as.userRepo.FindByLogin(ctx, login)
 	if err != nil {
-		return "", errors.New("invalid login/password")
+		// Тратим на неизвестный логин столько же времени, сколько на неверный пароль
+		if as.dummyHash != "" {
+			as.loginGuard.VerifyPassword(ctx, as.dummyHash, password)
+		}
+		return nil, nil, errInvalidCredentials
+	}
+
+	// Проверка пароля по хешу любой поддерживаемой схемы (argon2id, bcrypt, устаревший md5)
+	ok, err := as.loginGuard.VerifyPassword(ctx, user.PasswordHash, password)
+	if err != nil || !ok {
+		return nil, nil, errInvalidCredentials
 	}
 
-	// Проверка пароля: хешируем входной пароль и сравниваем с сохраненным в базе
-	hashed := utils.Md5Hash(password)
-	if user.PasswordHash != hashed {
-		return "", errors.New("invalid login/password")
+	// Заблокированный администратором пользователь войти не может
+	if user.DisabledAt != nil {
+		as.loginGuard.Release(ctx, attempt)
+		return nil, nil, ErrAccountDisabled
 	}
 
-	// Удаляем предыдущие сессии пользователя, чтобы сохранить только одну активную сессию
-	err = as.sessionRepo.DeleteByUID(ctx, user.ID)
+	// Если хеш записан по устаревшей схеме или со слабыми параметрами, прозрачно перехешируем пароль
+	as.upgradePasswordHash(ctx, user, password)
+
+	mfaEnabled, err := as.mfaService.Enabled(ctx, user.ID)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	if mfaEnabled {
+		// Счётчик неудач не сбрасываем, пока не проверен второй фактор: иначе, зная пароль,
+		// можно было бы перебирать коды без блокировки. Неверный код оставляет попытку
+		// засчитанной, как и неверный пароль
+		if mfaCode == "" {
+			as.loginGuard.Release(ctx, attempt)
+			token, expiresAt, err := as.mfaService.CreateChallenge(ctx, user.ID, device)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, &PendingMFA{Token: token, ExpiresAt: expiresAt}, nil
+		}
+		if err := as.mfaService.Verify(ctx, user.ID, mfaCode); err != nil {
+			if !errors.Is(err, ErrInvalidMFACode) {
+				as.loginGuard.Release(ctx, attempt)
+			}
+			return nil, nil, err
+		}
 	}
+	as.loginGuard.Release(ctx, attempt)
 
-	// Генерируем новый session ID
-	sessionID, err := utils.GenerateToken(16)
+	tokens, err := as.createSession(ctx, user, ip, device, mfaEnabled)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	if !mfaEnabled {
+		if tokens.MFAEnrollmentRequired, err = as.mfaService.Required(ctx, user.Role); err != nil {
+			return nil, nil, err
+		}
 	}
+	return tokens, nil, nil
+}
 
-	// Создаем новую сессию с текущим временем и IP-адресом клиента
+// LoginExternal создаёт сессию пользователя, личность которого подтвердил внешний провайдер
+// (OpenID Connect), без проверки пароля. mfaVerified — провайдер подтвердил, что при входе
+// использовался второй фактор; иначе, если у пользователя подключён второй фактор этого сервиса,
+// вместо токенов возвращается PendingMFA для LoginMFA.
+func (as *AuthService) LoginExternal(ctx context.Context, user *models.User, ip, device string, mfaVerified bool) (*Tokens, *PendingMFA, error) {
+	if user.DisabledAt != nil {
+		return nil, nil, ErrAccountDisabled
+	}
+	if !mfaVerified {
+		mfaEnabled, err := as.mfaService.Enabled(ctx, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mfaEnabled {
+			token, expiresAt, err := as.mfaService.CreateChallenge(ctx, user.ID, device)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, &PendingMFA{Token: token, ExpiresAt: expiresAt}, nil
+		}
+	}
+
+	tokens, err := as.createSession(ctx, user, ip, device, mfaVerified)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !mfaVerified {
+		if tokens.MFAEnrollmentRequired, err = as.mfaService.Required(ctx, user.Role); err != nil {
+			return nil, nil, err
+		}
+	}
+	return tokens, nil, nil
+}
+
+// LoginMFA завершает вход с вторым фактором: проверяет код (TOTP или код восстановления)
+// для токена, выданного Login, и создаёт сессию. Неверные коды учитываются так же,
+// как неверные пароли.
+func (as *AuthService) LoginMFA(ctx context.Context, mfaToken, code, ip string) (*Tokens, error) {
+	challenge, err := as.mfaService.FindChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := as.userRepo.GetUserByID(ctx, challenge.UID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	attempt, err := as.loginGuard.Reserve(ctx, user.Login, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := as.mfaService.CompleteChallenge(ctx, mfaToken, challenge, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			as.loginGuard.Release(ctx, attempt)
+		}
+		return nil, err
+	}
+	as.loginGuard.Release(ctx, attempt)
+	return as.createSession(ctx, user, ip, challenge.DeviceLabel, true)
+}
+
+// createSession создаёт сессию пользователя после успешного входа и сбрасывает счётчик
+// неудачных попыток входа по его логину. mfaVerified — при входе подтверждён второй фактор.
+func (as *AuthService) createSession(ctx context.Context, user *models.User, ip, device string, mfaVerified bool) (*Tokens, error) {
+	if err := as.loginGuard.Succeed(ctx, user.Login); err != nil {
+		log.Printf("[WARN] Failed to reset login attempts: user=%d err=%v", user.ID, err)
+	}
+
+	// Генерируем публичный идентификатор сессии
+	publicID, err := utils.GenerateToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	// Создаем новую сессию с текущим временем, IP-адресом клиента и меткой устройства
+	now := time.Now()
 	sess := &models.Session{
-		ID:        sessionID,
-		UID:       user.ID,
-		IPAddress: ip,
-		CreatedAt: time.Now(),
+		PublicID:    publicID,
+		UID:         user.ID,
+		DeviceLabel: device,
+		IPAddress:   ip,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		MFAVerified: mfaVerified,
 	}
-	err = as.sessionRepo.Create(ctx, sess)
+	tokens, refreshHash, err := as.issueTokens(ctx, sess, user, now)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	err = as.sessionRepo.Create(ctx, sess, refreshHash)
+	if err != nil {
+		return nil, err
 	}
 
-	return sessionID, nil
+	return tokens, nil
 }
 
-// ValidateToken проверяет, существует ли сессия с данным session ID,
-// и не просрочена ли она (срок жизни не превышает sessionTTL).
+// Refresh обменивает refresh-токен на новую пару токенов. Оба токена сессии при этом
+// заменяются (ротация), а предъявленный refresh-токен становится недействительным.
+// Повторное предъявление уже обменянного токена считается признаком кражи: сессия отзывается.
+func (as *AuthService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	oldHash := utils.HashToken(refreshToken)
+	sess, err := as.sessionRepo.FindByRefreshHash(ctx, oldHash)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		as.jwtService.Deny(sess.PublicID)
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if now.Sub(sess.CreatedAt) > as.maxLifetime || now.After(sess.RefreshExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	// Для токена доступа в формате JWT нужны текущие роль пользователя и состояние второго фактора
+	var user *models.User
+	if as.jwtService.Enabled() {
+		if user, err = as.userRepo.GetUserByID(ctx, sess.UID); err != nil {
+			return nil, err
+		}
+		if user.DisabledAt != nil {
+			return nil, ErrAccountDisabled
+		}
+	}
+
+	sess.LastUsedAt = now
+	tokens, newHash, err := as.issueTokens(ctx, sess, user, now)
+	if err != nil {
+		return nil, err
+	}
+	err = as.sessionRepo.Rotate(ctx, sess, oldHash, newHash)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout завершает сессию, с которой выполнен запрос.
+func (as *AuthService) Logout(ctx context.Context, sess *models.Session) error {
+	if err := as.sessionRepo.DeleteByID(ctx, sess.ID); err != nil {
+		return err
+	}
+	as.jwtService.Deny(sess.PublicID)
+	return nil
+}
+
+// DeleteExpiredSessions удаляет сессии, которыми больше нельзя воспользоваться.
+// Вызывается периодически фоновой задачей.
+func (as *AuthService) DeleteExpiredSessions(ctx context.Context) error {
+	now := time.Now()
+	idle := as.idleTimeout
+	if as.refreshTokenTTL > idle {
+		idle = as.refreshTokenTTL
+	}
+	n, err := as.sessionRepo.DeleteExpired(ctx, now, now.Add(-as.maxLifetime), now.Add(-idle), now.Add(-as.refreshTokenTTL))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired sessions", n)
+	}
+	return nil
+}
+
+// issueTokens генерирует для сессии новый токен доступа и refresh-токен.
+// Случайный токен доступа записывается в sess.ID, срок refresh-токена — в sess.RefreshExpiresAt;
+// вместе с токенами возвращается хеш refresh-токена для сохранения в БД.
+// Если токены доступа выдаются в формате JWT, клиент получает JWT для пользователя user,
+// а sess.ID остаётся внутренним идентификатором сессии, который никому не выдаётся.
+func (as *AuthService) issueTokens(ctx context.Context, sess *models.Session, user *models.User, now time.Time) (*Tokens, string, error) {
+	accessToken, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Ни один из токенов не переживает абсолютный срок жизни сессии
+	deadline := sess.CreatedAt.Add(as.maxLifetime)
+	sess.ID = accessToken
+	sess.RefreshExpiresAt = minTime(now.Add(as.refreshTokenTTL), deadline)
+	accessExpiresAt := minTime(now.Add(as.idleTimeout), deadline)
+
+	if as.jwtService.Enabled() {
+		pending, err := as.mfaService.Pending(ctx, user, sess)
+		if err != nil {
+			return nil, "", err
+		}
+		accessToken, accessExpiresAt, err = as.jwtService.Issue(user, sess, pending, now, deadline)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return &Tokens{
+		UID:              sess.UID,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshExpiresAt: sess.RefreshExpiresAt,
+	}, utils.HashToken(refreshToken), nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// upgradePasswordHash перехеширует пароль по текущей схеме, если это требуется.
+// Ошибка не мешает входу пользователя: хеш будет обновлён при следующем успешном входе.
+func (as *AuthService) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.PasswordHash, as.passwordScheme) {
+		return
+	}
+	hash, err := utils.HashPassword(as.passwordScheme, password)
+	if err == nil {
+		err = as.userRepo.UpdatePasswordHash(ctx, user.ID, hash)
+	}
+	if err != nil {
+		log.Printf("[WARN] Failed to upgrade password hash for user=%d: %v", user.ID, err)
+		return
+	}
+	log.Printf("[INFO] Password hash upgraded for user=%d: %s -> %s", user.ID, utils.PasswordHashScheme(user.PasswordHash), utils.PasswordHashScheme(hash))
+}
+
+// ValidateToken проверяет, существует ли сессия с данным session ID и не просрочена ли она:
+// с последнего использования прошло не больше idleTimeout, с момента входа — не больше maxLifetime.
+// Каждое использование продлевает сессию (скользящий срок действия).
 func (as *AuthService) ValidateToken(ctx context.Context, sessionID string) (*models.Session, error) {
 	sess, err := as.sessionRepo.FindByID(ctx, sessionID)
 	if err != nil {
-		return nil, errors.New("invalid token")
+		return nil, ErrInvalidToken
 	}
 
 	// Если сессия просрочена, возвращаем ошибку
-	if time.Since(sess.CreatedAt) > as.sessionTTL {
-		return nil, errors.New("session expired")
+	now := time.Now()
+	if now.Sub(sess.CreatedAt) > as.maxLifetime || now.Sub(sess.LastUsedAt) > as.idleTimeout {
+		return nil, ErrSessionExpired
 	}
 
+	// Фиксируем время последнего использования сессии
+	if now.Sub(sess.LastUsedAt) > sessionTouchInterval {
+		if err := as.sessionRepo.Touch(ctx, sess.ID, now); err != nil {
+			log.Printf("[WARN] Failed to update session last_used_at: %v", err)
+		} else {
+			sess.LastUsedAt = now
+		}
+	}
+
+	return sess, nil
+}
+
+// FindSession возвращает сессию по её публичному идентификатору. Используется для токенов
+// доступа в формате JWT, которые несут только публичный идентификатор сессии.
+func (as *AuthService) FindSession(ctx context.Context, publicID string) (*models.Session, error) {
+	sess, err := as.sessionRepo.FindByPublicID(ctx, publicID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
 	return sess, nil
 }
+
+// ListSessions возвращает все сессии пользователя.
+func (as *AuthService) ListSessions(ctx context.Context, uid int64) ([]models.Session, error) {
+	return as.sessionRepo.ListByUID(ctx, uid)
+}
+
+// RevokeSession завершает сессию пользователя по её публичному идентификатору.
+func (as *AuthService) RevokeSession(ctx context.Context, uid int64, publicID string) error {
+	ok, err := as.sessionRepo.DeleteByPublicID(ctx, uid, publicID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	as.jwtService.Deny(publicID)
+	return nil
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей, и возвращает их число.
+func (as *AuthService) RevokeOtherSessions(ctx context.Context, current *models.Session) (int64, error) {
+	revoked, err := as.sessionRepo.DeleteOthers(ctx, current.UID, current.ID)
+	if err != nil {
+		return 0, err
+	}
+	as.jwtService.Deny(revoked...)
+	return int64(len(revoked)), nil
+}
+
+// RevokeUserSessions завершает все сессии пользователя uid (например, после смены роли:
+// токены доступа в формате JWT несут роль, с которой они выданы).
+func (as *AuthService) RevokeUserSessions(ctx context.Context, uid int64) error {
+	revoked, err := as.sessionRepo.DeleteByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	as.jwtService.Deny(revoked...)
+	return nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+)
+
+var (
+	// ErrClientCertNotMapped возвращается, если клиентский сертификат не сопоставлен пользователю.
+	ErrClientCertNotMapped = errors.New("client certificate not mapped to a user")
+	// ErrClientCertAmbiguous возвращается, если сертификат подходит под несколько сопоставлений:
+	// от чьего имени выполнять запрос, неизвестно.
+	ErrClientCertAmbiguous = errors.New("client certificate matches several mappings")
+	// ErrInvalidClientCertMapping возвращается при некорректных параметрах сопоставления.
+	ErrInvalidClientCertMapping = errors.New("invalid client certificate mapping")
+	// ErrClientCertMappingExists возвращается, если такое поле сертификата уже сопоставлено.
+	ErrClientCertMappingExists = errors.New("client certificate mapping already exists")
+	// ErrClientCertMappingNotFound возвращается, если сопоставления с указанным идентификатором нет.
+	ErrClientCertMappingNotFound = errors.New("client certificate mapping not found")
+)
+
+// ClientCertService сопоставляет клиентские сертификаты mTLS пользователям: проверенный
+// сервером сертификат внутреннего сервиса служит удостоверением вместо токена.
+type ClientCertService struct {
+	certRepo *repository.ClientCertRepository // Сопоставления сертификатов
+	userRepo *repository.UserRepository       // Проверка существования пользователя
+}
+
+// NewClientCertService создаёт новый экземпляр ClientCertService.
+func NewClientCertService(certRepo *repository.ClientCertRepository, userRepo *repository.UserRepository) *ClientCertService {
+	return &ClientCertService{certRepo: certRepo, userRepo: userRepo}
+}
+
+// Authenticate находит сопоставление для клиентского сертификата, уже проверенного сервером
+// по CA клиентов. Сертификат должен подходить ровно под одно сопоставление.
+func (s *ClientCertService) Authenticate(ctx context.Context, cert *x509.Certificate) (*models.ClientCertMapping, error) {
+	matches, values := certificateFields(cert)
+	mappings, err := s.certRepo.FindByFields(ctx, matches, values)
+	if err != nil {
+		return nil, err
+	}
+	switch len(mappings) {
+	case 0:
+		return nil, ErrClientCertNotMapped
+	case 1:
+	default:
+		return nil, ErrClientCertAmbiguous
+	}
+	m := &mappings[0]
+
+	// Время последнего использования обновляется не чаще раза в sessionTouchInterval
+	now := time.Now()
+	if m.LastUsedAt == nil || now.Sub(*m.LastUsedAt) > sessionTouchInterval {
+		if err := s.certRepo.Touch(ctx, m.ID, now); err != nil {
+			log.Printf("[WARN] Failed to update client certificate mapping last_used_at: %v", err)
+		} else {
+			m.LastUsedAt = &now
+		}
+	}
+	return m, nil
+}
+
+// List возвращает все сопоставления сертификатов.
+func (s *ClientCertService) List(ctx context.Context) ([]models.ClientCertMapping, error) {
+	return s.certRepo.List(ctx)
+}
+
+// Create сопоставляет сертификаты, у которых поле match равно value, пользователю uid.
+func (s *ClientCertService) Create(ctx context.Context, match, value string, uid int64, scopes, namePrefixes []string) (*models.ClientCertMapping, error) {
+	value = strings.TrimSpace(value)
+	if !validCertMatch(match) || value == "" || len(scopes) == 0 || !validScopes(scopes) {
+		return nil, ErrInvalidClientCertMapping
+	}
+	if namePrefixes == nil {
+		namePrefixes = []string{}
+	}
+	if _, err := s.userRepo.GetUserByID(ctx, uid); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	m := &models.ClientCertMapping{
+		Match:        match,
+		Value:        value,
+		UID:          uid,
+		Scopes:       scopes,
+		NamePrefixes: namePrefixes,
+	}
+	err := s.certRepo.Create(ctx, m)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrClientCertMappingExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Delete удаляет сопоставление id: сертификаты, подходившие под него, больше не принимаются.
+func (s *ClientCertService) Delete(ctx context.Context, id int64) error {
+	ok, err := s.certRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrClientCertMappingNotFound
+	}
+	return nil
+}
+
+// certificateFields возвращает поля сертификата, по которым ищется сопоставление:
+// пары (тип поля, значение) в виде двух срезов одинаковой длины.
+func certificateFields(cert *x509.Certificate) (matches, values []string) {
+	add := func(match, value string) {
+		if value != "" {
+			matches = append(matches, match)
+			values = append(values, value)
+		}
+	}
+	add(models.CertMatchSubject, cert.Subject.String())
+	for _, name := range cert.DNSNames {
+		add(models.CertMatchDNS, name)
+	}
+	for _, email := range cert.EmailAddresses {
+		add(models.CertMatchEmail, email)
+	}
+	for _, uri := range cert.URIs {
+		add(models.CertMatchURI, uri.String())
+	}
+	return matches, values
+}
+
+func validCertMatch(match string) bool {
+	switch match {
+	case models.CertMatchSubject, models.CertMatchDNS, models.CertMatchEmail, models.CertMatchURI:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPeriodically выполняет task каждые interval до отмены ctx.
+// Используется для фоновых задач обслуживания (сборка мусора, очистка просроченных записей).
+// Ошибки задачи логируются и не прерывают дальнейшие запуски.
+func RunPeriodically(ctx context.Context, interval time.Duration, name string, task func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := task(ctx); err != nil {
+				log.Printf("[ERROR] Background task %s failed: %v", name, err)
+			}
+		}
+	}
+}
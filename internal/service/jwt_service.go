@@ -0,0 +1,452 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// Форматы токенов доступа (ACCESS_TOKEN_FORMAT).
+const (
+	AccessTokenOpaque = "opaque" // Случайный токен, проверяется по таблице sessions
+	AccessTokenJWT    = "jwt"    // Подписанный JWT, проверяется без обращения к БД
+)
+
+const (
+	// jwtKeyPublishLead — сколько новый ключ публикуется в JWKS, прежде чем им начинают
+	// подписывать токены: сервисы, проверяющие токены, успевают получить его заранее.
+	jwtKeyPublishLead = 5 * time.Minute
+	// jwtKeyReloadInterval — не чаще этого ключи перечитываются из БД, если предъявлен токен
+	// с неизвестным kid (ключ создан другим экземпляром сервиса).
+	jwtKeyReloadInterval = 10 * time.Second
+	// jwtClockSkew — допустимое расхождение часов при проверке сроков токена.
+	jwtClockSkew = 30 * time.Second
+	// jwtRevokedOverlap — насколько раньше последней известной записи перечитывается список
+	// отозванных сессий: транзакции фиксируются не обязательно в порядке времени отзыва.
+	jwtRevokedOverlap = time.Minute
+)
+
+// errNoSigningKey возвращается, если ключи подписи ещё не загружены.
+var errNoSigningKey = errors.New("no jwt signing key")
+
+// AccessClaims — утверждения токена доступа в формате JWT.
+type AccessClaims struct {
+	Issuer     string `json:"iss"`                   // Издатель (JWT_ISSUER)
+	Subject    string `json:"sub"`                   // Идентификатор пользователя (uid)
+	SessionID  string `json:"sid"`                   // Публичный идентификатор сессии
+	Role       string `json:"role"`                  // Роль пользователя на момент выдачи
+	Scope      string `json:"scope"`                 // Разрешённые области действия через пробел
+	MFAPending bool   `json:"mfa_pending,omitempty"` // Роль требует второй фактор, а он не подтверждён
+	IssuedAt   int64  `json:"iat"`                   // Время выдачи (Unix)
+	ExpiresAt  int64  `json:"exp"`                   // Срок действия (Unix)
+}
+
+// UID возвращает идентификатор пользователя из утверждения sub.
+func (c *AccessClaims) UID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// jwtKey — загруженный ключ подписи.
+type jwtKey struct {
+	kid       string
+	alg       string
+	signer    crypto.Signer
+	createdAt time.Time
+}
+
+// JWTService выдаёт и проверяет токены доступа в формате JWT (ACCESS_TOKEN_FORMAT=jwt).
+// Токен несёт uid, роль, области действия и идентификатор сессии и проверяется без обращения
+// к БД. Ключи подписи общие для всех экземпляров сервиса, хранятся в БД, периодически
+// сменяются и публикуются в JWKS. Отзыв сессии действует через список отозванных сессий,
+// который держится в памяти и периодически перечитывается (см. Sync).
+type JWTService struct {
+	repo *repository.JWTRepository // Ключи подписи и список отозванных сессий
+
+	enabled          bool          // Токены доступа выдаются в формате JWT
+	alg              string        // Алгоритм подписи новых ключей
+	issuer           string        // Издатель токенов (iss)
+	accessTTL        time.Duration // Срок действия токена доступа
+	rotationInterval time.Duration // Период смены ключа подписи
+
+	mu           sync.RWMutex
+	keys         []jwtKey             // Ключи подписи, начиная с самого нового
+	keysLoadedAt time.Time            // Когда ключи были загружены из БД
+	revoked      map[string]time.Time // Отозванные сессии и время отзыва
+	revokedSince time.Time            // Время последней загруженной записи об отзыве
+}
+
+// NewJWTService создаёт новый экземпляр JWTService. Возвращает ошибку для неизвестного
+// формата токенов доступа или алгоритма подписи.
+func NewJWTService(repo *repository.JWTRepository, cfg *config.Config) (*JWTService, error) {
+	switch cfg.AccessTokenFormat {
+	case AccessTokenOpaque, AccessTokenJWT:
+	default:
+		return nil, fmt.Errorf("unknown access token format %q", cfg.AccessTokenFormat)
+	}
+	switch cfg.JWTAlgorithm {
+	case utils.JWTAlgEdDSA, utils.JWTAlgES256:
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+	return &JWTService{
+		repo:             repo,
+		enabled:          cfg.AccessTokenFormat == AccessTokenJWT,
+		alg:              cfg.JWTAlgorithm,
+		issuer:           cfg.JWTIssuer,
+		accessTTL:        cfg.JWTAccessTTL,
+		rotationInterval: cfg.JWTKeyRotationInterval,
+		revoked:          map[string]time.Time{},
+	}, nil
+}
+
+// IsJWT сообщает, что токен из заголовка Authorization является токеном доступа в формате JWT.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Enabled сообщает, что токены доступа выдаются в формате JWT.
+func (s *JWTService) Enabled() bool {
+	return s.enabled
+}
+
+// Issue выдаёт токен доступа для сессии sess пользователя user. Токен истекает через
+// JWT_ACCESS_TTL, но не позже deadline. mfaPending — роль требует второй фактор, а сессия его
+// не прошла: токену разрешено только чтение.
+func (s *JWTService) Issue(user *models.User, sess *models.Session, mfaPending bool, now, deadline time.Time) (string, time.Time, error) {
+	key, ok := s.signingKey(now)
+	if !ok {
+		return "", time.Time{}, errNoSigningKey
+	}
+
+	scopes := models.AllScopes
+	if user.Role == models.RoleReadOnly || mfaPending {
+		scopes = []string{models.ScopeAssetsRead}
+	}
+	expiresAt := minTime(now.Add(s.accessTTL), deadline)
+	claims := AccessClaims{
+		Issuer:     s.issuer,
+		Subject:    strconv.FormatInt(user.ID, 10),
+		SessionID:  sess.PublicID,
+		Role:       user.Role,
+		Scope:      strings.Join(scopes, " "),
+		MFAPending: mfaPending,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  expiresAt.Unix(),
+	}
+	token, err := utils.SignJWT(key.kid, claims, key.signer)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Verify проверяет подпись, издателя и срок действия токена доступа, а также то, что его сессия
+// не отозвана, и возвращает утверждения токена.
+func (s *JWTService) Verify(ctx context.Context, token string) (*AccessClaims, error) {
+	if !s.enabled {
+		return nil, ErrInvalidToken
+	}
+	payload, err := utils.VerifyJWT(token, func(h *utils.JWTHeader) (crypto.PublicKey, error) {
+		return s.publicKey(ctx, h)
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims AccessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Issuer != s.issuer || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	if _, err := claims.UID(); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if now.Add(-jwtClockSkew).Unix() >= claims.ExpiresAt {
+		return nil, ErrSessionExpired
+	}
+	if now.Add(jwtClockSkew).Unix() < claims.IssuedAt {
+		return nil, ErrInvalidToken
+	}
+
+	s.mu.RLock()
+	_, revoked := s.revoked[claims.SessionID]
+	s.mu.RUnlock()
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// Deny сразу добавляет сессии publicIDs в список отозванных на этом экземпляре сервиса,
+// не дожидаясь, пока список будет перечитан из БД.
+func (s *JWTService) Deny(publicIDs ...string) {
+	if !s.enabled || len(publicIDs) == 0 {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	for _, id := range publicIDs {
+		s.revoked[id] = now
+	}
+	s.mu.Unlock()
+}
+
+// JWKS возвращает открытые ключи, которыми подписаны действующие токены доступа.
+func (s *JWTService) JWKS() *utils.JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := &utils.JWKSet{Keys: []utils.JWK{}}
+	for _, k := range s.keys {
+		jwk, err := utils.NewJWK(k.kid, k.alg, k.signer.Public())
+		if err != nil {
+			log.Printf("[ERROR] Cannot publish JWT signing key %s: %v", k.kid, err)
+			continue
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+	return set
+}
+
+// Revoked возвращает отозванные сессии, токены доступа которых ещё могут не истечь,
+// в порядке отзыва.
+func (s *JWTService) Revoked() []models.RevokedSession {
+	s.mu.RLock()
+	revoked := make([]models.RevokedSession, 0, len(s.revoked))
+	for sid, at := range s.revoked {
+		revoked = append(revoked, models.RevokedSession{SessionID: sid, RevokedAt: at})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].RevokedAt.Before(revoked[j].RevokedAt) })
+	return revoked
+}
+
+// Sync при необходимости сменяет ключ подписи, удаляет ключи, которыми подписанные токены
+// уже истекли, и перечитывает из БД ключи и список отозванных сессий.
+// Вызывается при запуске и затем периодически фоновой задачей.
+func (s *JWTService) Sync(ctx context.Context) error {
+	if !s.enabled {
+		return nil
+	}
+	now := time.Now()
+	if err := s.rotateKeys(ctx, now); err != nil {
+		return err
+	}
+	if err := s.loadKeys(ctx); err != nil {
+		return err
+	}
+	return s.loadRevoked(ctx, now)
+}
+
+// DeleteExpired удаляет записи об отозванных сессиях, токены доступа которых уже истекли.
+// Вызывается периодически фоновой задачей.
+func (s *JWTService) DeleteExpired(ctx context.Context) error {
+	n, err := s.repo.DeleteRevokedBefore(ctx, time.Now().Add(-s.accessTTL-jwtClockSkew))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired revoked session records", n)
+	}
+	return nil
+}
+
+// signingKey возвращает ключ для подписи новых токенов: самый новый из ключей, опубликованных
+// не меньше jwtKeyPublishLead назад, или самый новый, если таких ещё нет.
+func (s *JWTService) signingKey(now time.Time) (jwtKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.keys) == 0 {
+		return jwtKey{}, false
+	}
+	for _, k := range s.keys {
+		if !k.createdAt.After(now.Add(-jwtKeyPublishLead)) {
+			return k, true
+		}
+	}
+	return s.keys[0], true
+}
+
+// publicKey возвращает открытый ключ для проверки токена по kid из заголовка. Если ключ
+// неизвестен, ключи перечитываются из БД (не чаще jwtKeyReloadInterval).
+func (s *JWTService) publicKey(ctx context.Context, h *utils.JWTHeader) (crypto.PublicKey, error) {
+	if key, ok := s.findKey(h.Kid); ok && key.alg == h.Alg {
+		return key.signer.Public(), nil
+	}
+
+	s.mu.RLock()
+	stale := time.Since(s.keysLoadedAt) > jwtKeyReloadInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil, utils.ErrJWTSignature
+	}
+	if err := s.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.findKey(h.Kid); ok && key.alg == h.Alg {
+		return key.signer.Public(), nil
+	}
+	return nil, utils.ErrJWTSignature
+}
+
+// findKey ищет загруженный ключ по идентификатору.
+func (s *JWTService) findKey(kid string) (jwtKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.kid == kid {
+			return k, true
+		}
+	}
+	return jwtKey{}, false
+}
+
+// rotateKeys создаёт новый ключ подписи, если самому новому больше JWT_KEY_ROTATION_INTERVAL,
+// и удаляет ключи, которые сменены настолько давно, что подписанные ими токены истекли.
+func (s *JWTService) rotateKeys(ctx context.Context, now time.Time) error {
+	keys, err := s.repo.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 || keys[0].CreatedAt.Before(now.Add(-s.rotationInterval)) {
+		k, err := s.generateKey(now)
+		if err != nil {
+			return err
+		}
+		created, err := s.repo.CreateKeyIfStale(ctx, k, now.Add(-s.rotationInterval))
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("[INFO] New JWT signing key created: kid=%s alg=%s", k.Kid, k.Alg)
+		}
+	}
+
+	// Ключом перестают подписывать, когда следующему за ним исполняется jwtKeyPublishLead;
+	// ещё через accessTTL истекают и подписанные им токены
+	retiredBefore := now.Add(-jwtKeyPublishLead - s.accessTTL - jwtClockSkew)
+	for i, k := range keys {
+		if k.CreatedAt.Before(retiredBefore) {
+			if i+1 < len(keys) {
+				n, err := s.repo.DeleteKeysBefore(ctx, k.CreatedAt)
+				if err != nil {
+					return err
+				}
+				if n > 0 {
+					log.Printf("[INFO] Removed %d retired JWT signing keys", n)
+				}
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// generateKey создаёт новый ключ подписи для алгоритма JWT_ALGORITHM.
+func (s *JWTService) generateKey(now time.Time) (*models.JWTSigningKey, error) {
+	var priv crypto.Signer
+	var err error
+	switch s.alg {
+	case utils.JWTAlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := utils.GenerateToken(8)
+	if err != nil {
+		return nil, err
+	}
+	return &models.JWTSigningKey{Kid: kid, Alg: s.alg, PrivateKey: der, CreatedAt: now}, nil
+}
+
+// loadKeys перечитывает ключи подписи из БД. Ключи, которые не удалось разобрать, пропускаются.
+func (s *JWTService) loadKeys(ctx context.Context) error {
+	stored, err := s.repo.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]jwtKey, 0, len(stored))
+	for _, k := range stored {
+		priv, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+		if err != nil {
+			log.Printf("[ERROR] Cannot parse JWT signing key %s: %v", k.Kid, err)
+			continue
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			log.Printf("[ERROR] Cannot use JWT signing key %s: unsupported key type %T", k.Kid, priv)
+			continue
+		}
+		keys = append(keys, jwtKey{kid: k.Kid, alg: k.Alg, signer: signer, createdAt: k.CreatedAt})
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.keysLoadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// loadRevoked дочитывает из БД сессии, отозванные с прошлой загрузки, и забывает те,
+// токены доступа которых уже истекли.
+func (s *JWTService) loadRevoked(ctx context.Context, now time.Time) error {
+	s.mu.RLock()
+	since := s.revokedSince
+	s.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-jwtRevokedOverlap)
+	}
+
+	revoked, err := s.repo.ListRevoked(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	expired := now.Add(-s.accessTTL - jwtClockSkew)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range revoked {
+		s.revoked[r.SessionID] = r.RevokedAt
+		if r.RevokedAt.After(s.revokedSince) {
+			s.revokedSince = r.RevokedAt
+		}
+	}
+	for sid, at := range s.revoked {
+		if at.Before(expired) {
+			delete(s.revoked, sid)
+		}
+	}
+	return nil
+}
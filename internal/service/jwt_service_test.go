@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
+	"go-asset-service/pkg/utils"
+)
+
+// newTestJWTService создаёт JWTService без БД с ключами keys (от самого нового к старому).
+// Ключи считаются только что загруженными, поэтому неизвестный kid не вызывает перечитывания.
+func newTestJWTService(t *testing.T, keys ...jwtKey) *JWTService {
+	t.Helper()
+	s, err := NewJWTService(nil, &config.Config{
+		AccessTokenFormat:      AccessTokenJWT,
+		JWTAlgorithm:           utils.JWTAlgEdDSA,
+		JWTIssuer:              "https://assets.example",
+		JWTAccessTTL:           5 * time.Minute,
+		JWTKeyRotationInterval: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.keys = keys
+	s.keysLoadedAt = time.Now()
+	return s
+}
+
+func newTestJWTKey(t *testing.T, kid, alg string, createdAt time.Time) jwtKey {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case utils.JWTAlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwtKey{kid: kid, alg: alg, signer: signer, createdAt: createdAt}
+}
+
+func issueTestToken(t *testing.T, s *JWTService, sid string, now time.Time) string {
+	t.Helper()
+	user := &models.User{ID: 7, Role: models.RoleUser}
+	token, _, err := s.Issue(user, &models.Session{PublicID: sid}, false, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWTServiceIssueVerify(t *testing.T) {
+	now := time.Now()
+	for _, alg := range []string{utils.JWTAlgEdDSA, utils.JWTAlgES256} {
+		s := newTestJWTService(t, newTestJWTKey(t, "k1", alg, now.Add(-time.Hour)))
+		claims, err := s.Verify(context.Background(), issueTestToken(t, s, "sid-1", now))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if uid, _ := claims.UID(); uid != 7 || claims.SessionID != "sid-1" || claims.Role != models.RoleUser {
+			t.Fatalf("%s: claims = %+v", alg, claims)
+		}
+	}
+}
+
+func TestJWTServiceExpiry(t *testing.T) {
+	now := time.Now()
+	s := newTestJWTService(t, newTestJWTKey(t, "k1", utils.JWTAlgEdDSA, now.Add(-time.Hour)))
+
+	// Срок берётся меньшим из JWT_ACCESS_TTL и срока сессии
+	user := &models.User{ID: 7, Role: models.RoleUser}
+	_, expiresAt, err := s.Issue(user, &models.Session{PublicID: "sid"}, false, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expires at %s, want session deadline %s", expiresAt, now.Add(time.Minute))
+	}
+
+	// Истёкший токен отклоняется и с учётом допуска на расхождение часов
+	old := now.Add(-s.accessTTL - jwtClockSkew - time.Second)
+	if _, err := s.Verify(context.Background(), issueTestToken(t, s, "sid", old)); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expired token: err = %v, want ErrSessionExpired", err)
+	}
+	// А выданный «в будущем» — как поддельный
+	future := now.Add(jwtClockSkew + time.Minute)
+	if _, err := s.Verify(context.Background(), issueTestToken(t, s, "sid", future)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token from the future: err = %v, want ErrInvalidToken", err)
+	}
+
+	other := newTestJWTService(t, s.keys...)
+	other.issuer = "https://other.example"
+	if _, err := s.Verify(context.Background(), issueTestToken(t, other, "sid", now)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("foreign issuer: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTServiceKeyRotation(t *testing.T) {
+	now := time.Now()
+	old := newTestJWTKey(t, "old", utils.JWTAlgEdDSA, now.Add(-24*time.Hour))
+	fresh := newTestJWTKey(t, "new", utils.JWTAlgEdDSA, now)
+	s := newTestJWTService(t, fresh, old)
+
+	// Новый ключ сначала только публикуется в JWKS, а подписывает по-прежнему старый
+	if k, _ := s.signingKey(now); k.kid != "old" {
+		t.Fatalf("signing with %s right after rotation, want old", k.kid)
+	}
+	if jwks := s.JWKS(); len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(jwks.Keys))
+	}
+	oldToken := issueTestToken(t, s, "sid-old", now)
+
+	// Прошло jwtKeyPublishLead: подписывает новый ключ
+	fresh.createdAt = now.Add(-jwtKeyPublishLead)
+	s.keys = []jwtKey{fresh, old}
+	if k, _ := s.signingKey(now); k.kid != "new" {
+		t.Fatalf("signing with %s after publish lead, want new", k.kid)
+	}
+	newToken := issueTestToken(t, s, "sid-new", now)
+
+	// Токены, подписанные обоими ключами, действуют, пока ключи загружены
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := s.Verify(context.Background(), token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Удалённый при ротации ключ больше ничего не подтверждает
+	s.keys = []jwtKey{fresh}
+	if _, err := s.Verify(context.Background(), oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of a removed key: err = %v, want ErrInvalidToken", err)
+	}
+
+	// Ключ с тем же kid, но другим алгоритмом, не подходит
+	s.keys = []jwtKey{newTestJWTKey(t, "new", utils.JWTAlgES256, now)}
+	if _, err := s.Verify(context.Background(), newToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token checked with a key of another alg: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTServiceDenylist(t *testing.T) {
+	now := time.Now()
+	s := newTestJWTService(t, newTestJWTKey(t, "k1", utils.JWTAlgEdDSA, now.Add(-time.Hour)))
+	revoked := issueTestToken(t, s, "sid-revoked", now)
+	kept := issueTestToken(t, s, "sid-kept", now)
+
+	s.Deny("sid-revoked")
+	if _, err := s.Verify(context.Background(), revoked); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked session: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Verify(context.Background(), kept); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+	if list := s.Revoked(); len(list) != 1 || list[0].SessionID != "sid-revoked" {
+		t.Fatalf("Revoked() = %+v", list)
+	}
+
+	// С непрозрачными токенами список не ведётся
+	s.enabled = false
+	s.Deny("sid-kept")
+	if list := s.Revoked(); len(list) != 1 {
+		t.Fatalf("Deny with opaque tokens recorded a session: %+v", list)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/lockout"
+	"go-asset-service/pkg/utils"
+)
+
+// ErrTooManyAttempts возвращается, если попытки входа по логину или с IP-адреса временно
+// запрещены после неудачных попыток. Конкретная ошибка — *AttemptsError со временем ожидания.
+var ErrTooManyAttempts = errors.New("too many login attempts")
+
+// AttemptsError сообщает, через сколько можно повторить попытку входа.
+// errors.Is(err, ErrTooManyAttempts) для неё истинно.
+type AttemptsError struct {
+	RetryAfter time.Duration // Время до окончания задержки или блокировки
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+// Is позволяет сравнивать ошибку с ErrTooManyAttempts через errors.Is.
+func (e *AttemptsError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// LoginGuard защищает вход от перебора паролей. Неудачные попытки считаются отдельно по логину
+// и по IP-адресу клиента: после каждой следующая попытка разрешается не раньше, чем через
+// экспоненциально растущую задержку (backoff), а после maxFailures неудач подряд ключ блокируется
+// на lockoutDuration. Счёт сбрасывается успешным входом (для логина), разблокировкой
+// администратором или через failureWindow без неудач.
+type LoginGuard struct {
+	store lockout.AttemptStore // Хранилище счётчиков (Postgres или память)
+
+	maxFailures     int           // Неудач подряд по логину до блокировки
+	ipMaxFailures   int           // Неудач подряд с одного IP-адреса до блокировки
+	backoffBase     time.Duration // Задержка после первой неудачи; удваивается с каждой следующей
+	lockoutDuration time.Duration // Длительность блокировки; она же — предел задержки
+	failureWindow   time.Duration // Через это время без неудач счёт начинается заново
+
+	verifySlots chan struct{} // Семафор одновременных проверок пароля
+}
+
+// NewLoginGuard создаёт новый экземпляр LoginGuard.
+func NewLoginGuard(store lockout.AttemptStore, cfg *config.Config) *LoginGuard {
+	return &LoginGuard{
+		store:           store,
+		maxFailures:     cfg.LoginMaxFailures,
+		ipMaxFailures:   cfg.LoginIPMaxFailures,
+		backoffBase:     cfg.LoginBackoffBase,
+		lockoutDuration: cfg.LoginLockoutDuration,
+		failureWindow:   cfg.LoginFailureWindow,
+		verifySlots:     make(chan struct{}, max(cfg.LoginVerifyConcurrency, 1)),
+	}
+}
+
+// VerifyPassword проверяет пароль по хешу (utils.VerifyPassword), но не больше чем
+// LOGIN_VERIFY_CONCURRENCY проверок одновременно: каждая проверка argon2id занимает десятки МиБ
+// памяти, и поток запросов входа (в том числе с неизвестными логинами) не должен исчерпать
+// память или процессор сервиса. Остальные проверки ждут своей очереди.
+func (g *LoginGuard) VerifyPassword(ctx context.Context, hash, password string) (bool, error) {
+	select {
+	case g.verifySlots <- struct{}{}:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	defer func() { <-g.verifySlots }()
+	return utils.VerifyPassword(hash, password)
+}
+
+// Reservation — попытка входа (или ввода пароля ссылки), заранее засчитанная как неудачная.
+// Если пароль оказался верным, резерв отменяется LoginGuard.Release.
+type Reservation struct {
+	keys []reservedKey
+}
+
+// reservedKey — состояние одного ключа до и после резерва.
+type reservedKey struct {
+	key            string
+	prev, reserved lockout.Attempts
+}
+
+// guardKey — ключ счётчика и число неудач подряд до его блокировки.
+type guardKey struct {
+	key         string
+	maxFailures int
+}
+
+// Reserve проверяет, разрешена ли сейчас попытка входа под логином login с адреса ip, и заранее
+// засчитывает её как неудачную по обоим ключам, назначая задержку до следующей попытки (или
+// блокировку). Проверка и резерв атомарны, поэтому параллельные попытки, пока проверяется
+// пароль, получают *AttemptsError, а не проверяются все сразу. Если пароль верен, резерв
+// отменяется Release; если нет — попытка так и остаётся засчитанной.
+// Неизвестные логины считаются так же, как существующие: по ответам нельзя узнать,
+// есть ли учётная запись.
+func (g *LoginGuard) Reserve(ctx context.Context, login, ip string) (*Reservation, error) {
+	return g.reserve(ctx, guardKey{loginKey(login), g.maxFailures}, guardKey{ipKey(ip), g.ipMaxFailures})
+}
+
+// reserve резервирует попытку по каждому из ключей keys. Если хотя бы один из них заблокирован,
+// уже сделанные резервы отменяются и возвращается *AttemptsError.
+func (g *LoginGuard) reserve(ctx context.Context, keys ...guardKey) (*Reservation, error) {
+	now := time.Now()
+	r := &Reservation{}
+	for _, k := range keys {
+		prev, reserved, ok, err := g.store.Reserve(ctx, k.key, now, g.failureWindow, g.delay(k.maxFailures))
+		if err == nil && !ok {
+			err = &AttemptsError{RetryAfter: reserved.LockedUntil.Sub(now)}
+		}
+		if err != nil {
+			g.Release(ctx, r)
+			return nil, err
+		}
+		if reserved.Failures == k.maxFailures {
+			log.Printf("[WARN] Login locked out: key=%s failures=%d duration=%s", k.key, reserved.Failures, g.lockoutDuration)
+		}
+		r.keys = append(r.keys, reservedKey{key: k.key, prev: prev, reserved: reserved})
+	}
+	return r, nil
+}
+
+// Release отменяет резерв r после верного пароля: попытка не считается неудачной, а задержка,
+// назначенная резервом, снимается. Ошибки только логируются.
+func (g *LoginGuard) Release(ctx context.Context, r *Reservation) {
+	for _, k := range r.keys {
+		if err := g.store.Release(ctx, k.key, k.prev, k.reserved); err != nil {
+			log.Printf("[ERROR] Failed to release login attempt: key=%s err=%v", k.key, err)
+		}
+	}
+}
+
+// delay возвращает функцию задержки после очередной неудачи для ключа, блокируемого после
+// maxFailures неудач подряд.
+func (g *LoginGuard) delay(maxFailures int) func(failures int) time.Duration {
+	return func(failures int) time.Duration {
+		if failures < maxFailures {
+			return g.backoff(failures)
+		}
+		return g.lockoutDuration
+	}
+}
+
+// backoff возвращает задержку после failures неудач подряд: backoffBase * 2^(failures-1),
+// но не больше lockoutDuration.
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	delay := g.backoffBase
+	for i := 1; i < failures && delay < g.lockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > g.lockoutDuration {
+		delay = g.lockoutDuration
+	}
+	return delay
+}
+
+// Succeed сбрасывает счётчик логина после успешного входа. Счётчик IP-адреса сохраняется:
+// иначе подбирающий пароли к чужим учётным записям мог бы сбрасывать его, входя в свою.
+func (g *LoginGuard) Succeed(ctx context.Context, login string) error {
+	return g.store.Reset(ctx, loginKey(login))
+}
+
+// Unlock снимает блокировку и сбрасывает счётчик неудачных попыток логина login.
+func (g *LoginGuard) Unlock(ctx context.Context, login string) error {
+	return g.store.Reset(ctx, loginKey(login))
+}
+
+// ReserveShare проверяет и заранее засчитывает попытку ввести пароль публичной ссылки slug
+// с адреса ip (см. Reserve). Счётчики ссылок ведутся отдельно от счётчиков входа, но с теми
+// же порогами.
+func (g *LoginGuard) ReserveShare(ctx context.Context, slug, ip string) (*Reservation, error) {
+	return g.reserve(ctx, guardKey{shareKey(slug), g.maxFailures}, guardKey{shareIPKey(ip), g.ipMaxFailures})
+}
+
+// SucceedShare сбрасывает счётчик ссылки slug после верного пароля; счётчик IP-адреса
+// сохраняется по той же причине, что и в Succeed.
+func (g *LoginGuard) SucceedShare(ctx context.Context, slug string) error {
+	return g.store.Reset(ctx, shareKey(slug))
+}
+
+// DeleteExpired удаляет счётчики, по которым давно не было неудач и нет блокировки.
+// Вызывается периодически фоновой задачей.
+func (g *LoginGuard) DeleteExpired(ctx context.Context) error {
+	n, err := g.store.DeleteExpired(ctx, time.Now(), g.failureWindow)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired login attempt counters", n)
+	}
+	return nil
+}
+
+func loginKey(login string) string { return "login:" + login }
+
+func ipKey(ip string) string { return "ip:" + ip }
+
+func shareKey(slug string) string { return "share:" + slug }
+
+func shareIPKey(ip string) string { return "share-ip:" + ip }
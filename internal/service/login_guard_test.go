@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/lockout"
+	"go-asset-service/pkg/utils"
+)
+
+func newTestLoginGuard() *LoginGuard {
+	return NewLoginGuard(lockout.NewMemoryStore(), &config.Config{
+		LoginMaxFailures:       3,
+		LoginIPMaxFailures:     10,
+		LoginBackoffBase:       time.Minute,
+		LoginLockoutDuration:   time.Hour,
+		LoginFailureWindow:     time.Hour,
+		LoginVerifyConcurrency: 1,
+	})
+}
+
+func TestLoginGuardReserveIsAtomic(t *testing.T) {
+	g := newTestLoginGuard()
+	ctx := context.Background()
+
+	const n = 20
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := g.Reserve(ctx, "alice", "192.0.2.1")
+			if err != nil && !errors.Is(err, ErrTooManyAttempts) {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 1 {
+		t.Fatalf("%d concurrent attempts reserved, want 1", reserved)
+	}
+
+	// Неотменённый резерв — неудачная попытка: следующая ждёт backoff
+	_, err := g.Reserve(ctx, "alice", "192.0.2.2")
+	var attemptsErr *AttemptsError
+	if !errors.As(err, &attemptsErr) || attemptsErr.RetryAfter <= 0 || attemptsErr.RetryAfter > time.Minute {
+		t.Fatalf("err = %v, want AttemptsError with backoff up to 1m", err)
+	}
+}
+
+func TestLoginGuardReleaseUndoesReservation(t *testing.T) {
+	g := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		r, err := g.Reserve(ctx, "alice", "192.0.2.1")
+		if err != nil {
+			t.Fatalf("attempt %d after correct passwords: %v", i+1, err)
+		}
+		g.Release(ctx, r)
+	}
+
+	// Неверный пароль с адреса 198.51.100.1: адрес ждёт backoff, и попытка под другим
+	// логином с него отклоняется, не оставляя резерва по этому логину
+	if _, err := g.Reserve(ctx, "carol", "198.51.100.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Reserve(ctx, "bob", "198.51.100.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("err = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := g.Reserve(ctx, "bob", "192.0.2.9"); err != nil {
+		t.Fatalf("login key kept a reservation of a rejected attempt: %v", err)
+	}
+}
+
+func TestLoginGuardVerifyPasswordBounded(t *testing.T) {
+	g := newTestLoginGuard()
+	hash, err := utils.HashPassword("bcrypt", "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := g.VerifyPassword(context.Background(), hash, "correct horse battery")
+	if err != nil || !ok {
+		t.Fatalf("VerifyPassword = %v, %v; want true", ok, err)
+	}
+
+	// Все места заняты: проверка ждёт и прерывается вместе с контекстом
+	g.verifySlots <- struct{}{}
+	defer func() { <-g.verifySlots }()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := g.VerifyPassword(ctx, hash, "correct horse battery"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
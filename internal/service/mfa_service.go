@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// Параметры второго фактора.
+const (
+	recoveryCodeCount       = 10 // Кодов восстановления выдаётся за раз
+	mfaChallengeMaxAttempts = 5  // Неверных кодов до отмены незавершённого входа
+	totpSkew                = 1  // Допустимое расхождение часов клиента, шагов TOTP
+)
+
+var (
+	// ErrMFAAlreadyEnabled возвращается при попытке подключить уже подтверждённый второй фактор.
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	// ErrMFANotEnabled возвращается, если второй фактор не подключён (или подключение не начато).
+	ErrMFANotEnabled = errors.New("mfa not enabled")
+	// ErrInvalidMFACode возвращается для неверного, просроченного или уже использованного кода.
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	// ErrInvalidMFAToken возвращается для неизвестного или истёкшего токена незавершённого входа.
+	ErrInvalidMFAToken = errors.New("invalid mfa token")
+	// ErrMFARequired возвращается при попытке отключить второй фактор, обязательный для роли.
+	ErrMFARequired = errors.New("mfa required for role")
+)
+
+// MFAService реализует второй фактор аутентификации — одноразовые коды TOTP (RFC 6238)
+// с кодами восстановления: подключение и отключение пользователем, проверку кодов при входе
+// и политику обязательности второго фактора для ролей.
+type MFAService struct {
+	mfaRepo       *repository.MFARepository          // Секреты, коды восстановления и политика
+	challengeRepo *repository.MFAChallengeRepository // Незавершённые входы
+	userRepo      *repository.UserRepository         // Проверка пароля при отключении
+	sessionRepo   *repository.SessionRepository      // Отметка сессий, прошедших второй фактор
+	jwtService    *JWTService                        // Отзыв токенов доступа JWT завершённых сессий
+
+	issuer       string        // Название сервиса в приложении-аутентификаторе
+	challengeTTL time.Duration // Срок действия незавершённого входа
+}
+
+// NewMFAService создаёт новый экземпляр MFAService.
+func NewMFAService(mfaRepo *repository.MFARepository, challengeRepo *repository.MFAChallengeRepository, userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, jwtService *JWTService, cfg *config.Config) *MFAService {
+	return &MFAService{
+		mfaRepo:       mfaRepo,
+		challengeRepo: challengeRepo,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		jwtService:    jwtService,
+		issuer:        cfg.MFAIssuer,
+		challengeTTL:  cfg.MFAChallengeTTL,
+	}
+}
+
+// Enabled сообщает, подключён ли у пользователя uid подтверждённый второй фактор.
+func (s *MFAService) Enabled(ctx context.Context, uid int64) (bool, error) {
+	m, err := s.mfaRepo.Get(ctx, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.ConfirmedAt != nil, nil
+}
+
+// Required сообщает, обязателен ли второй фактор для роли role.
+func (s *MFAService) Required(ctx context.Context, role string) (bool, error) {
+	return s.mfaRepo.IsRequired(ctx, role)
+}
+
+// Pending сообщает, что роль пользователя требует второй фактор, а запрос выполнен без него:
+// с сессией, при входе в которую код не вводился, или с API-ключом пользователя, не подключившего
+// второй фактор. Таким запросам разрешено только чтение.
+func (s *MFAService) Pending(ctx context.Context, user *models.User, sess *models.Session) (bool, error) {
+	required, err := s.Required(ctx, user.Role)
+	if err != nil || !required {
+		return false, err
+	}
+	if sess != nil {
+		return !sess.MFAVerified, nil
+	}
+	enabled, err := s.Enabled(ctx, user.ID)
+	return !enabled, err
+}
+
+// Status возвращает состояние второго фактора пользователя.
+func (s *MFAService) Status(ctx context.Context, user *models.User) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{}
+	m, err := s.mfaRepo.Get(ctx, user.ID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case m.ConfirmedAt != nil:
+		status.Enabled = true
+		status.ConfirmedAt = m.ConfirmedAt
+		if status.RecoveryCodesLeft, err = s.mfaRepo.CountRecoveryCodes(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if status.Required, err = s.Required(ctx, user.Role); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Enroll начинает подключение второго фактора: генерирует секрет TOTP (заменяя начатое ранее
+// неподтверждённое подключение). Второй фактор начинает действовать после Confirm.
+func (s *MFAService) Enroll(ctx context.Context, user *models.User) (*models.MFAEnrollment, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.mfaRepo.Enroll(ctx, user.ID, secret, time.Now())
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.issuer, user.Login, secret),
+	}, nil
+}
+
+// Confirm завершает подключение второго фактора первым кодом из приложения и возвращает коды
+// восстановления (показываются один раз). Текущая сессия current отмечается как прошедшая
+// второй фактор, остальные сессии пользователя завершаются.
+func (s *MFAService) Confirm(ctx context.Context, current *models.Session, code string) ([]string, error) {
+	m, err := s.mfaRepo.Get(ctx, current.UID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	step, ok := utils.VerifyTOTP(m.Secret, normalizeTOTPCode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	ok, err = s.mfaRepo.Confirm(ctx, current.UID, step, hashes, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Параллельный запрос уже подтвердил подключение
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.sessionRepo.SetMFAVerified(ctx, current.PublicID); err != nil {
+		return nil, err
+	}
+	revoked, err := s.sessionRepo.DeleteOthers(ctx, current.UID, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.jwtService.Deny(revoked...)
+	return codes, nil
+}
+
+// Verify проверяет код второго фактора пользователя uid: код TOTP из приложения
+// или неиспользованный код восстановления (после проверки он гасится).
+func (s *MFAService) Verify(ctx context.Context, uid int64, code string) error {
+	m, err := s.mfaRepo.Get(ctx, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if m.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if totp := normalizeTOTPCode(code); len(totp) == utils.TOTPDigits && isDigits(totp) {
+		step, ok := utils.VerifyTOTP(m.Secret, totp, time.Now(), totpSkew)
+		if !ok || step <= m.LastUsedStep {
+			return ErrInvalidMFACode
+		}
+		fresh, err := s.mfaRepo.UseStep(ctx, uid, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, uid, utils.HashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	log.Printf("[INFO] MFA recovery code used: user=%d", uid)
+	return nil
+}
+
+// RegenerateRecoveryCodes выдаёт новые коды восстановления вместо прежних после проверки
+// кода второго фактора.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error) {
+	if err := s.Verify(ctx, uid, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable отключает второй фактор пользователя после проверки пароля и кода. Если второй
+// фактор обязателен для роли пользователя, отключить его нельзя (ErrMFARequired).
+func (s *MFAService) Disable(ctx context.Context, user *models.User, password, code string) error {
+	ok, err := utils.VerifyPassword(user.PasswordHash, password)
+	if err != nil || !ok {
+		return ErrWrongPassword
+	}
+	required, err := s.Required(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+	if err := s.Verify(ctx, user.ID, code); err != nil {
+		return err
+	}
+	_, err = s.mfaRepo.Delete(ctx, user.ID)
+	return err
+}
+
+// Reset отключает второй фактор пользователя uid администратором (например, при потере
+// устройства и кодов восстановления). Все сессии пользователя завершаются; если второй фактор
+// обязателен для его роли, до повторного подключения пользователю доступно только чтение.
+func (s *MFAService) Reset(ctx context.Context, uid int64) error {
+	ok, err := s.mfaRepo.Delete(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFANotEnabled
+	}
+	revoked, err := s.sessionRepo.DeleteByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	s.jwtService.Deny(revoked...)
+	return nil
+}
+
+// RequiredRoles возвращает роли, для которых второй фактор обязателен.
+func (s *MFAService) RequiredRoles(ctx context.Context) ([]string, error) {
+	return s.mfaRepo.RequiredRoles(ctx)
+}
+
+// SetRequiredRoles задаёт роли, для которых второй фактор обязателен.
+func (s *MFAService) SetRequiredRoles(ctx context.Context, roles []string) ([]string, error) {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, role := range roles {
+		if !validRole(role) {
+			return nil, ErrInvalidUser
+		}
+		if !seen[role] {
+			seen[role] = true
+			unique = append(unique, role)
+		}
+	}
+	if err := s.mfaRepo.SetRequiredRoles(ctx, unique); err != nil {
+		return nil, err
+	}
+	return s.mfaRepo.RequiredRoles(ctx)
+}
+
+// CreateChallenge начинает вход с вторым фактором после проверки пароля: возвращает
+// одноразовый токен, с которым код второго фактора нужно предъявить до истечения срока.
+func (s *MFAService) CreateChallenge(ctx context.Context, uid int64, device string) (string, time.Time, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c := &models.MFAChallenge{UID: uid, DeviceLabel: device, ExpiresAt: time.Now().Add(s.challengeTTL)}
+	if err := s.challengeRepo.Create(ctx, utils.HashToken(token), c); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, c.ExpiresAt, nil
+}
+
+// FindChallenge возвращает действующий незавершённый вход по токену.
+func (s *MFAService) FindChallenge(ctx context.Context, token string) (*models.MFAChallenge, error) {
+	c, err := s.challengeRepo.Find(ctx, utils.HashToken(token), time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidMFAToken
+	}
+	return c, err
+}
+
+// CompleteChallenge проверяет код второго фактора для незавершённого входа по токену и гасит
+// токен. После mfaChallengeMaxAttempts неверных кодов токен перестаёт действовать.
+func (s *MFAService) CompleteChallenge(ctx context.Context, token string, c *models.MFAChallenge, code string) error {
+	tokenHash := utils.HashToken(token)
+	if err := s.Verify(ctx, c.UID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if ferr := s.challengeRepo.Fail(ctx, tokenHash, mfaChallengeMaxAttempts); ferr != nil {
+				log.Printf("[WARN] Failed to count MFA attempt: user=%d err=%v", c.UID, ferr)
+			}
+		}
+		return err
+	}
+	ok, err := s.challengeRepo.Consume(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
+
+// DeleteExpiredChallenges удаляет истёкшие незавершённые входы.
+// Вызывается периодически фоновой задачей.
+func (s *MFAService) DeleteExpiredChallenges(ctx context.Context) error {
+	n, err := s.challengeRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired MFA challenges", n)
+	}
+	return nil
+}
+
+// generateRecoveryCodes генерирует коды восстановления вида "1a2b3-c4d5e" и их хеши для хранения.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw, err := utils.GenerateToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = utils.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTOTPCode убирает пробелы, которые приложения вставляют для удобства чтения кода.
+func normalizeTOTPCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
+
+// normalizeRecoveryCode приводит код восстановления к виду, в котором хранится его хеш.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(normalizeTOTPCode(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func isDigits(s string) bool {
+	return strings.IndexFunc(s, func(c rune) bool { return c < '0' || c > '9' }) < 0
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"go-asset-service/pkg/utils"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("%d codes and %d hashes, want %d", len(codes), len(hashes), recoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[0-9a-f]{5}-[0-9a-f]{5}$`)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeats", code)
+		}
+		seen[code] = true
+		// Хранится хеш кода без дефиса: его и ищет Verify
+		if got := utils.HashToken(normalizeRecoveryCode(code)); got != hashes[i] {
+			t.Errorf("code %q: hash of normalized code does not match the stored one", code)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := map[string]string{
+		"1a2b3-c4d5e":     "1a2b3c4d5e",
+		" 1A2B3-C4D5E \n": "1a2b3c4d5e",
+		"1a2b3 c4d5e":     "1a2b3c4d5e",
+		"1a2b3c4d5e":      "1a2b3c4d5e",
+	}
+	for in, want := range tests {
+		if got := normalizeRecoveryCode(in); got != want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
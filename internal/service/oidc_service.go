@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/oidc"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// externalPasswordHash — хеш пароля пользователей, созданных при входе через провайдера OIDC.
+// Он не соответствует ни одной схеме хеширования, поэтому вход по паролю для них невозможен,
+// пока администратор не задаст пароль явно.
+const externalPasswordHash = "!"
+
+// mfaMethods — методы аутентификации (утверждение amr, RFC 8176), при которых провайдер
+// считается подтвердившим второй фактор.
+var mfaMethods = map[string]bool{"mfa": true, "otp": true, "hwk": true}
+
+// rolePriority — порядок выбора роли, если группы пользователя соответствуют нескольким ролям.
+var rolePriority = []string{models.RoleAdmin, models.RoleUser, models.RoleReadOnly}
+
+var (
+	// ErrOIDCDisabled возвращается, если вход через провайдера OIDC не настроен.
+	ErrOIDCDisabled = errors.New("oidc login disabled")
+	// ErrInvalidOIDCState возвращается для неизвестного, истёкшего или уже использованного state.
+	ErrInvalidOIDCState = errors.New("invalid oidc state")
+	// ErrOIDCIdentity возвращается, если учётную запись провайдера не удалось сопоставить
+	// пользователю: нет логина в утверждениях, он некорректен или уже занят локальным пользователем.
+	ErrOIDCIdentity = errors.New("cannot map oidc identity to user")
+)
+
+// oidcStore — операции с начатыми входами и привязками учётных записей провайдера
+// (реализуется repository.OIDCRepository).
+type oidcStore interface {
+	CreateState(ctx context.Context, stateHash string, s *models.OIDCLoginState) error
+	ConsumeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCLoginState, error)
+	DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error)
+	FindUID(ctx context.Context, issuer, subject string) (int64, error)
+	Link(ctx context.Context, uid int64, issuer, subject string) error
+	CreateLinkedUser(ctx context.Context, u *models.User, issuer, subject string) error
+}
+
+// oidcUserStore — операции с пользователями, нужные входу через OIDC
+// (реализуется repository.UserRepository).
+type oidcUserStore interface {
+	FindByLogin(ctx context.Context, login string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	UpdateRole(ctx context.Context, id int64, role string) error
+}
+
+// oidcSessions — создание и завершение сессий пользователей (реализуется AuthService).
+type oidcSessions interface {
+	LoginExternal(ctx context.Context, user *models.User, ip, device string, mfaVerified bool) (*Tokens, *PendingMFA, error)
+	RevokeUserSessions(ctx context.Context, uid int64) error
+}
+
+// OIDCService реализует вход через провайдера OpenID Connect (authorization code с PKCE):
+// сопоставляет учётную запись провайдера локальному пользователю, при первом входе создаёт
+// его, и выдаёт те же токены сессии, что и вход по паролю.
+type OIDCService struct {
+	provider    *oidc.Provider // Клиент провайдера; nil — вход через OIDC выключен
+	oidcRepo    oidcStore      // Начатые входы и привязки учётных записей
+	userRepo    oidcUserStore  // Пользователи
+	authService oidcSessions   // Создание сессии и завершение сессий при смене роли
+
+	stateTTL     time.Duration     // Срок, за который нужно завершить вход у провайдера
+	loginClaim   string            // Утверждение с логином нового пользователя
+	roleClaim    string            // Утверждение с группами пользователя (пусто — роли не сопоставляются)
+	roleMapping  map[string]string // Группа провайдера → роль
+	defaultRole  string            // Роль новых пользователей без сопоставленных групп
+	linkExisting bool              // Привязывать учётную запись к существующему пользователю с тем же адресом
+}
+
+// emailClaim — утверждение с адресом электронной почты. Привязка к существующим пользователям
+// выполняется только по нему и только при подтверждённом провайдером адресе (email_verified):
+// остальные утверждения (например, preferred_username) пользователь часто задаёт сам.
+const emailClaim = "email"
+
+// NewOIDCService создаёт новый экземпляр OIDCService. provider == nil выключает вход через OIDC.
+func NewOIDCService(provider *oidc.Provider, oidcRepo *repository.OIDCRepository, userRepo *repository.UserRepository, authService *AuthService, cfg *config.Config) *OIDCService {
+	defaultRole := cfg.OIDCDefaultRole
+	if !validRole(defaultRole) {
+		log.Printf("[WARN] Invalid OIDC_DEFAULT_ROLE %q, using %q", defaultRole, models.RoleReadOnly)
+		defaultRole = models.RoleReadOnly
+	}
+	linkExisting := cfg.OIDCLinkExistingUsers
+	if linkExisting && cfg.OIDCLoginClaim != emailClaim {
+		log.Printf("[WARN] OIDC_LINK_EXISTING_USERS requires OIDC_LOGIN_CLAIM=%s, linking disabled (claim is %q)", emailClaim, cfg.OIDCLoginClaim)
+		linkExisting = false
+	}
+	return &OIDCService{
+		provider:     provider,
+		oidcRepo:     oidcRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+		stateTTL:     cfg.OIDCStateTTL,
+		loginClaim:   cfg.OIDCLoginClaim,
+		roleClaim:    cfg.OIDCRoleClaim,
+		roleMapping:  parseRoleMapping(cfg.OIDCRoleMapping),
+		defaultRole:  defaultRole,
+		linkExisting: linkExisting,
+	}
+}
+
+// NewOIDCProvider создаёт клиент провайдера по конфигурации. Если OIDC_ISSUER_URL не задан,
+// возвращает nil: вход через OIDC выключен.
+func NewOIDCProvider(cfg *config.Config) (*oidc.Provider, error) {
+	if cfg.OIDCIssuerURL == "" {
+		return nil, nil
+	}
+	return oidc.NewProvider(oidc.Options{
+		IssuerURL:    cfg.OIDCIssuerURL,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Scopes:       strings.Fields(cfg.OIDCScopes),
+	})
+}
+
+// Start начинает вход: сохраняет state, code verifier PKCE и nonce и возвращает адрес
+// страницы входа провайдера, на которую нужно перенаправить пользователя.
+func (s *OIDCService) Start(ctx context.Context, device string) (string, error) {
+	if s.provider == nil {
+		return "", ErrOIDCDisabled
+	}
+	state, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallengeS256(verifier))
+	if err != nil {
+		return "", err
+	}
+	st := &models.OIDCLoginState{
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		DeviceLabel:  device,
+		ExpiresAt:    time.Now().Add(s.stateTTL),
+	}
+	if err := s.oidcRepo.CreateState(ctx, utils.HashToken(state), st); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// Callback завершает вход по обратному вызову провайдера: обменивает код на ID-токен,
+// проверяет его, находит или создаёт пользователя и создаёт сессию (см. AuthService.LoginExternal).
+func (s *OIDCService) Callback(ctx context.Context, state, code, ip string) (*Tokens, *PendingMFA, error) {
+	user, st, claims, err := s.identify(ctx, state, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.authService.LoginExternal(ctx, user, ip, st.DeviceLabel, mfaAsserted(claims))
+}
+
+// identify проверяет обратный вызов провайдера: погашает одноразовый state, обменивает код
+// на ID-токен с code verifier PKCE этого входа, проверяет токен с его nonce и находит
+// (или создаёт) пользователя.
+func (s *OIDCService) identify(ctx context.Context, state, code string) (*models.User, *models.OIDCLoginState, *oidc.Claims, error) {
+	if s.provider == nil {
+		return nil, nil, nil, ErrOIDCDisabled
+	}
+	st, err := s.oidcRepo.ConsumeState(ctx, utils.HashToken(state), time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rawIDToken, err := s.provider.Exchange(ctx, code, st.CodeVerifier)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	claims, err := s.provider.VerifyIDToken(ctx, rawIDToken, st.Nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, st, claims, nil
+}
+
+// DeleteExpiredStates удаляет истёкшие начатые входы.
+// Вызывается периодически фоновой задачей.
+func (s *OIDCService) DeleteExpiredStates(ctx context.Context) error {
+	n, err := s.oidcRepo.DeleteExpiredStates(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired OIDC login states", n)
+	}
+	return nil
+}
+
+// resolveUser находит пользователя, привязанного к учётной записи провайдера. При первом входе
+// пользователь создаётся (или, если разрешено, привязывается существующий с тем же логином).
+// Привязка выполняется только по адресу электронной почты и только если провайдер подтвердил
+// адрес: иначе любой, кто укажет у провайдера чужой логин или адрес, получил бы доступ
+// к чужой учётной записи.
+// Если настроено сопоставление групп ролям, роль пользователя обновляется при каждом входе.
+func (s *OIDCService) resolveUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	issuer := s.provider.Issuer()
+	uid, err := s.oidcRepo.FindUID(ctx, issuer, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetUserByID(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		return user, s.syncRole(ctx, user, claims)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	login := claims.String(s.loginClaim)
+	if !validLogin(login) {
+		return nil, fmt.Errorf("%w: claim %s=%q is not a valid login", ErrOIDCIdentity, s.loginClaim, login)
+	}
+
+	if s.linkExisting && s.loginClaim == emailClaim {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err == nil {
+			if !claims.Bool("email_verified") {
+				log.Printf("[WARN] OIDC identity not linked, email is not verified: user=%d login=%s sub=%s", user.ID, login, claims.Subject)
+				return nil, fmt.Errorf("%w: email %q is not verified by the provider", ErrOIDCIdentity, login)
+			}
+			if err := s.oidcRepo.Link(ctx, user.ID, issuer, claims.Subject); err != nil {
+				return nil, err
+			}
+			log.Printf("[INFO] OIDC identity linked to existing user: user=%d login=%s sub=%s", user.ID, login, claims.Subject)
+			return user, s.syncRole(ctx, user, claims)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	role, _ := s.mappedRole(claims)
+	if role == "" {
+		role = s.defaultRole
+	}
+	user := &models.User{Login: login, PasswordHash: externalPasswordHash, Role: role}
+	err = s.oidcRepo.CreateLinkedUser(ctx, user, issuer, claims.Subject)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, fmt.Errorf("%w: login %q already taken", ErrOIDCIdentity, login)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] User provisioned from OIDC: id=%d login=%s role=%s sub=%s", user.ID, user.Login, user.Role, claims.Subject)
+	return user, nil
+}
+
+// syncRole обновляет роль пользователя по группам из утверждений, если сопоставление настроено.
+// Если группы пользователя не соответствуют ни одной роли, назначается роль по умолчанию.
+// При смене роли прежние сессии пользователя завершаются, как и при смене роли администратором.
+func (s *OIDCService) syncRole(ctx context.Context, user *models.User, claims *oidc.Claims) error {
+	role, ok := s.mappedRole(claims)
+	if !ok {
+		return nil
+	}
+	if role == "" {
+		role = s.defaultRole
+	}
+	if role == user.Role {
+		return nil
+	}
+	if err := s.userRepo.UpdateRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+	if err := s.authService.RevokeUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	log.Printf("[INFO] User role updated from OIDC groups: user=%d role=%s->%s", user.ID, user.Role, role)
+	user.Role = role
+	return nil
+}
+
+// mappedRole возвращает роль по группам пользователя из утверждения roleClaim: из нескольких
+// подходящих выбирается самая привилегированная, пустая строка — ни одна группа не подошла.
+// ok == false, если сопоставление групп ролям не настроено.
+func (s *OIDCService) mappedRole(claims *oidc.Claims) (role string, ok bool) {
+	if s.roleClaim == "" {
+		return "", false
+	}
+	matched := map[string]bool{}
+	for _, group := range claims.Strings(s.roleClaim) {
+		if r, ok := s.roleMapping[group]; ok {
+			matched[r] = true
+		}
+	}
+	for _, r := range rolePriority {
+		if matched[r] {
+			return r, true
+		}
+	}
+	return "", true
+}
+
+// mfaAsserted сообщает, что провайдер подтвердил использование второго фактора при входе.
+func mfaAsserted(claims *oidc.Claims) bool {
+	for _, m := range claims.Strings("amr") {
+		if mfaMethods[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRoleMapping разбирает соответствие групп ролям вида "группа=роль,группа=роль".
+// Записи с неизвестной ролью пропускаются с предупреждением.
+func parseRoleMapping(v string) map[string]string {
+	mapping := map[string]string{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(item, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !validRole(role) {
+			log.Printf("[WARN] Ignoring invalid OIDC_ROLE_MAPPING entry %q", item)
+			continue
+		}
+		mapping[group] = role
+	}
+	return mapping
+}
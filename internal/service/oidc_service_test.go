@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/oidc"
+	"go-asset-service/internal/oidc/oidctest"
+	"go-asset-service/internal/repository"
+)
+
+// fakeOIDCStore хранит начатые входы, привязки и пользователей в памяти и повторяет
+// поведение repository.OIDCRepository и repository.UserRepository.
+type fakeOIDCStore struct {
+	mu         sync.Mutex
+	states     map[string]*models.OIDCLoginState // По хешу state
+	identities map[string]int64                  // issuer + " " + subject -> uid
+	users      map[int64]*models.User
+	nextID     int64
+}
+
+func newFakeOIDCStore() *fakeOIDCStore {
+	return &fakeOIDCStore{
+		states:     map[string]*models.OIDCLoginState{},
+		identities: map[string]int64{},
+		users:      map[int64]*models.User{},
+	}
+}
+
+func (f *fakeOIDCStore) CreateState(ctx context.Context, stateHash string, s *models.OIDCLoginState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := *s
+	f.states[stateHash] = &st
+	return nil
+}
+
+func (f *fakeOIDCStore) ConsumeState(ctx context.Context, stateHash string, now time.Time) (*models.OIDCLoginState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, ok := f.states[stateHash]
+	if !ok || !st.ExpiresAt.After(now) {
+		return nil, pgx.ErrNoRows
+	}
+	delete(f.states, stateHash)
+	return st, nil
+}
+
+func (f *fakeOIDCStore) DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for h, st := range f.states {
+		if !st.ExpiresAt.After(now) {
+			delete(f.states, h)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeOIDCStore) FindUID(ctx context.Context, issuer, subject string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uid, ok := f.identities[issuer+" "+subject]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return uid, nil
+}
+
+func (f *fakeOIDCStore) Link(ctx context.Context, uid int64, issuer, subject string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.identities[issuer+" "+subject]; ok {
+		return repository.ErrAlreadyExists
+	}
+	f.identities[issuer+" "+subject] = uid
+	return nil
+}
+
+func (f *fakeOIDCStore) CreateLinkedUser(ctx context.Context, u *models.User, issuer, subject string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.users {
+		if existing.Login == u.Login {
+			return repository.ErrAlreadyExists
+		}
+	}
+	if _, ok := f.identities[issuer+" "+subject]; ok {
+		return repository.ErrAlreadyExists
+	}
+	f.nextID++
+	u.ID = f.nextID
+	u.CreatedAt = time.Now()
+	stored := *u
+	f.users[u.ID] = &stored
+	f.identities[issuer+" "+subject] = u.ID
+	return nil
+}
+
+func (f *fakeOIDCStore) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.Login == login {
+			found := *u
+			return &found, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (f *fakeOIDCStore) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	found := *u
+	return &found, nil
+}
+
+func (f *fakeOIDCStore) UpdateRole(ctx context.Context, id int64, role string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	u.Role = role
+	return nil
+}
+
+// addUser добавляет локального пользователя (созданного без OIDC).
+func (f *fakeOIDCStore) addUser(login, role string) *models.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	u := &models.User{ID: f.nextID, Login: login, Role: role}
+	f.users[u.ID] = u
+	found := *u
+	return &found
+}
+
+func (f *fakeOIDCStore) linked(issuer, subject string) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uid, ok := f.identities[issuer+" "+subject]
+	return uid, ok
+}
+
+// fakeSessions запоминает, чьи сессии завершались при смене роли.
+type fakeSessions struct {
+	mu      sync.Mutex
+	revoked []int64
+}
+
+func (f *fakeSessions) LoginExternal(ctx context.Context, user *models.User, ip, device string, mfaVerified bool) (*Tokens, *PendingMFA, error) {
+	return &Tokens{UID: user.ID}, nil, nil
+}
+
+func (f *fakeSessions) RevokeUserSessions(ctx context.Context, uid int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoked = append(f.revoked, uid)
+	return nil
+}
+
+func (f *fakeSessions) revokedUIDs() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.revoked...)
+}
+
+// oidcTestEnv — сервис входа через OIDC с провайдером oidctest и хранилищем в памяти.
+type oidcTestEnv struct {
+	iss      *oidctest.Issuer
+	store    *fakeOIDCStore
+	sessions *fakeSessions
+	svc      *OIDCService
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+	iss := oidctest.NewIssuer(t, "asset-service")
+	provider, err := oidc.NewProvider(oidc.Options{
+		IssuerURL:   iss.URL,
+		ClientID:    "asset-service",
+		RedirectURL: "https://localhost:8443/api/auth/oidc/callback",
+		Scopes:      []string{"profile", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeOIDCStore()
+	sessions := &fakeSessions{}
+	svc := &OIDCService{
+		provider:    provider,
+		oidcRepo:    store,
+		userRepo:    store,
+		authService: sessions,
+		stateTTL:    10 * time.Minute,
+		loginClaim:  "preferred_username",
+		roleMapping: map[string]string{},
+		defaultRole: models.RoleUser,
+	}
+	return &oidcTestEnv{iss: iss, store: store, sessions: sessions, svc: svc}
+}
+
+// start начинает вход и имитирует вход пользователя у провайдера с утверждениями claims.
+func (e *oidcTestEnv) start(t *testing.T, claims map[string]interface{}) (state, code string) {
+	t.Helper()
+	authURL, err := e.svc.Start(context.Background(), "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.iss.Authorize(t, authURL, claims)
+}
+
+func (e *oidcTestEnv) claims(sub, login string) map[string]interface{} {
+	c := e.iss.Claims(sub)
+	c["preferred_username"] = login
+	return c
+}
+
+func TestOIDCProvisionsUserOnFirstLogin(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	ctx := context.Background()
+
+	state, code := e.start(t, e.claims("sub-1", "bob"))
+	user, st, _, err := e.svc.identify(ctx, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == 0 || user.Login != "bob" || user.Role != models.RoleUser || user.PasswordHash != externalPasswordHash {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+	if st.DeviceLabel != "laptop" {
+		t.Fatalf("device label = %q, want laptop", st.DeviceLabel)
+	}
+	if uid, ok := e.store.linked(e.iss.URL, "sub-1"); !ok || uid != user.ID {
+		t.Fatalf("identity linked to %d (%t), want %d", uid, ok, user.ID)
+	}
+
+	// Повторный вход находит того же пользователя по iss и sub, даже если логин у провайдера изменился
+	state, code = e.start(t, e.claims("sub-1", "robert"))
+	again, _, _, err := e.svc.identify(ctx, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != user.ID || again.Login != "bob" {
+		t.Fatalf("second login resolved %+v, want user %d", again, user.ID)
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	ctx := context.Background()
+
+	state, code := e.start(t, e.claims("sub-1", "bob"))
+	if _, _, _, err := e.svc.identify(ctx, state, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("reused state: err = %v, want ErrInvalidOIDCState", err)
+	}
+	if _, _, _, err := e.svc.identify(ctx, "unknown-state", code); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("unknown state: err = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCStateExpires(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	e.svc.stateTTL = -time.Second
+	ctx := context.Background()
+
+	state, code := e.start(t, e.claims("sub-1", "bob"))
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("expired state: err = %v, want ErrInvalidOIDCState", err)
+	}
+	if n, err := e.svc.oidcRepo.DeleteExpiredStates(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("DeleteExpiredStates = %d, %v; want 1", n, err)
+	}
+}
+
+func TestOIDCCodeVerifierMismatch(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	ctx := context.Background()
+
+	state, code := e.start(t, e.claims("sub-1", "bob"))
+	// Подменяем code verifier этого входа: код, перехваченный атакующим, без него не обменять
+	e.store.mu.Lock()
+	for _, st := range e.store.states {
+		v, err := oidc.NewCodeVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.CodeVerifier = v
+	}
+	e.store.mu.Unlock()
+
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, oidc.ErrExchangeFailed) {
+		t.Fatalf("err = %v, want ErrExchangeFailed", err)
+	}
+	if _, ok := e.store.linked(e.iss.URL, "sub-1"); ok {
+		t.Fatal("identity linked despite failed exchange")
+	}
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	tests := map[string]func(c map[string]interface{}){
+		"wrong nonce":    func(c map[string]interface{}) { c["nonce"] = "replayed" },
+		"wrong issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c map[string]interface{}) { c["aud"] = "other-client" },
+		"wrong azp": func(c map[string]interface{}) {
+			c["aud"] = []string{"asset-service", "other-client"}
+			c["azp"] = "other-client"
+		},
+		"expired": func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := newOIDCTestEnv(t)
+			claims := e.claims("sub-1", "bob")
+			modify(claims)
+			state, code := e.start(t, claims)
+			if _, _, _, err := e.svc.identify(context.Background(), state, code); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+			if _, ok := e.store.linked(e.iss.URL, "sub-1"); ok {
+				t.Fatal("user provisioned from invalid token")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginTaken(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	ctx := context.Background()
+	e.store.addUser("alice", models.RoleAdmin)
+
+	state, code := e.start(t, e.claims("sub-1", "alice"))
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrOIDCIdentity) {
+		t.Fatalf("err = %v, want ErrOIDCIdentity", err)
+	}
+	if _, ok := e.store.linked(e.iss.URL, "sub-1"); ok {
+		t.Fatal("identity linked to existing user without OIDC_LINK_EXISTING_USERS")
+	}
+
+	state, code = e.start(t, e.claims("sub-2", "not a login!"))
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrOIDCIdentity) {
+		t.Fatalf("invalid login claim: err = %v, want ErrOIDCIdentity", err)
+	}
+}
+
+func TestOIDCLinkRequiresEmailClaim(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	e.svc.linkExisting = true
+	ctx := context.Background()
+	e.store.addUser("alice", models.RoleAdmin)
+
+	// preferred_username задаёт сам пользователь провайдера: привязка по нему запрещена
+	state, code := e.start(t, e.claims("sub-1", "alice"))
+	if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrOIDCIdentity) {
+		t.Fatalf("err = %v, want ErrOIDCIdentity", err)
+	}
+	if _, ok := e.store.linked(e.iss.URL, "sub-1"); ok {
+		t.Fatal("identity linked by preferred_username")
+	}
+}
+
+func TestOIDCLinkByEmailRequiresVerifiedEmail(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	e.svc.linkExisting = true
+	e.svc.loginClaim = "email"
+	ctx := context.Background()
+	alice := e.store.addUser("alice@example.com", models.RoleAdmin)
+
+	for _, verified := range []interface{}{nil, false, "false"} {
+		claims := e.iss.Claims("attacker")
+		claims["email"] = "alice@example.com"
+		if verified != nil {
+			claims["email_verified"] = verified
+		}
+		state, code := e.start(t, claims)
+		if _, _, _, err := e.svc.identify(ctx, state, code); !errors.Is(err, ErrOIDCIdentity) {
+			t.Fatalf("email_verified=%v: err = %v, want ErrOIDCIdentity", verified, err)
+		}
+		if _, ok := e.store.linked(e.iss.URL, "attacker"); ok {
+			t.Fatalf("email_verified=%v: identity linked by unverified email", verified)
+		}
+	}
+
+	claims := e.iss.Claims("sub-1")
+	claims["email"] = "alice@example.com"
+	claims["email_verified"] = true
+	state, code := e.start(t, claims)
+	user, _, _, err := e.svc.identify(ctx, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != alice.ID {
+		t.Fatalf("linked user %d, want %d", user.ID, alice.ID)
+	}
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	e := newOIDCTestEnv(t)
+	e.svc.roleClaim = "groups"
+	e.svc.roleMapping = parseRoleMapping("release-admins=admin,developers=user,auditors=readonly")
+	e.svc.defaultRole = models.RoleReadOnly
+	ctx := context.Background()
+
+	claims := e.claims("sub-1", "bob")
+	claims["groups"] = []string{"auditors", "release-admins"}
+	state, code := e.start(t, claims)
+	user, _, _, err := e.svc.identify(ctx, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Fatalf("role = %q, want admin (most privileged matching group)", user.Role)
+	}
+
+	// Роль не изменилась — сессии не трогаем
+	claims = e.claims("sub-1", "bob")
+	claims["groups"] = []string{"release-admins"}
+	state, code = e.start(t, claims)
+	if _, _, _, err := e.svc.identify(ctx, state, code); err != nil {
+		t.Fatal(err)
+	}
+	if revoked := e.sessions.revokedUIDs(); len(revoked) != 0 {
+		t.Fatalf("sessions revoked without role change: %v", revoked)
+	}
+
+	// Группа убрана у провайдера: при следующем входе роль понижается до роли по умолчанию
+	claims = e.claims("sub-1", "bob")
+	claims["groups"] = []string{"marketing"}
+	state, code = e.start(t, claims)
+	if user, _, _, err = e.svc.identify(ctx, state, code); err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleReadOnly {
+		t.Fatalf("role = %q, want readonly", user.Role)
+	}
+	if stored, _ := e.store.GetUserByID(ctx, user.ID); stored.Role != models.RoleReadOnly {
+		t.Fatalf("stored role = %q, want readonly", stored.Role)
+	}
+	// Токены доступа JWT, выданные с ролью admin, не должны действовать после понижения
+	if revoked := e.sessions.revokedUIDs(); len(revoked) != 1 || revoked[0] != user.ID {
+		t.Fatalf("revoked sessions of %v, want [%d]", revoked, user.ID)
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// defaultPresignTTL — срок действия подписанной ссылки, если он не указан при выпуске.
+const defaultPresignTTL = time.Hour
+
+var (
+	// ErrInvalidPresign возвращается при некорректных параметрах выпуска подписанной ссылки.
+	ErrInvalidPresign = errors.New("invalid presigned url parameters")
+	// ErrInvalidSignature возвращается, если параметры ссылки повреждены или подпись не совпадает.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrPresignExpired возвращается, если срок действия ссылки истёк.
+	ErrPresignExpired = errors.New("presigned url expired")
+	// ErrPresignIPMismatch возвращается, если ссылка привязана к другому IP-адресу.
+	ErrPresignIPMismatch = errors.New("presigned url bound to another ip")
+	// ErrPresignExhausted возвращается, если ссылка уже использована максимальное число раз.
+	ErrPresignExhausted = errors.New("presigned url use limit reached")
+	// ErrPresignRevoked возвращается, если выпустивший ссылку пользователь больше не имеет доступа к файлу.
+	ErrPresignRevoked = errors.New("presigned url access revoked")
+)
+
+// PresignService выпускает и проверяет подписанные ссылки на файлы. Ссылка содержит все
+// свои параметры (файл, владельца, срок действия, ограничения) и подпись HMAC-SHA256 от них
+// вместе с HTTP-методом, поэтому для её проверки не нужны ни сессия, ни запись в БД.
+// В БД хранятся только счётчики использований ссылок с ограничением числа использований.
+type PresignService struct {
+	presignRepo *repository.PresignRepository // Счётчики использований ссылок
+	aclService  *ACLService                   // Проверка доступа к чужим файлам
+	userRepo    *repository.UserRepository    // Проверка блокировки выпустившего ссылку и владельца
+	mfaService  *MFAService                   // Проверка второго фактора выпустившего ссылку на запись
+	secret      []byte                        // Ключ подписи HMAC
+	maxTTL      time.Duration                 // Максимальный срок действия ссылки
+}
+
+// NewPresignService создаёт новый экземпляр PresignService.
+func NewPresignService(presignRepo *repository.PresignRepository, aclService *ACLService, userRepo *repository.UserRepository, mfaService *MFAService, secret string, maxTTL time.Duration) *PresignService {
+	return &PresignService{
+		presignRepo: presignRepo,
+		aclService:  aclService,
+		userRepo:    userRepo,
+		mfaService:  mfaService,
+		secret:      []byte(secret),
+		maxTTL:      maxTTL,
+	}
+}
+
+// Create выпускает ссылку пользователя uid на метод method (GET или PUT) для файла name
+// владельца owner. ttl — срок действия (0 — по умолчанию, не больше настроенного максимума),
+// maxUses — число использований (0 — без ограничения), ip — разрешённый адрес клиента
+// (пусто — любой). Возвращает описание ссылки и её параметры запроса с подписью.
+func (s *PresignService) Create(uid, owner int64, name, method string, ttl time.Duration, maxUses int, ip string) (*models.PresignedURL, url.Values, error) {
+	if ttl == 0 {
+		ttl = defaultPresignTTL
+	}
+	if !ValidAssetName(name) || (method != http.MethodGet && method != http.MethodPut) ||
+		ttl < 0 || ttl > s.maxTTL || maxUses < 0 {
+		return nil, nil, ErrInvalidPresign
+	}
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, nil, ErrInvalidPresign
+		}
+		ip = parsed.String()
+	}
+
+	id, err := utils.GenerateToken(8)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &models.PresignedURL{
+		ID:        id,
+		Method:    method,
+		Name:      name,
+		UID:       uid,
+		Owner:     owner,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+		MaxUses:   maxUses,
+		IP:        ip,
+	}
+
+	q := url.Values{}
+	q.Set("id", p.ID)
+	q.Set("uid", strconv.FormatInt(p.UID, 10))
+	q.Set("owner", strconv.FormatInt(p.Owner, 10))
+	q.Set("exp", strconv.FormatInt(p.ExpiresAt.Unix(), 10))
+	if p.MaxUses > 0 {
+		q.Set("max_uses", strconv.Itoa(p.MaxUses))
+	}
+	if p.IP != "" {
+		q.Set("ip", p.IP)
+	}
+	q.Set("sig", s.sign(p))
+	return p, q, nil
+}
+
+// Verify проверяет ссылку, по которой пришёл запрос method к файлу name с параметрами q
+// от клиента с адресом clientIP: подпись, срок действия, привязку к IP, сохранность доступа
+// у выпустившего её пользователя (для PUT — и право на запись: роль не readonly и второй
+// фактор, если роль его требует) и, в последнюю очередь, число использований
+// (успешная проверка засчитывается как использование). HEAD проверяется как GET.
+func (s *PresignService) Verify(ctx context.Context, method, name string, q url.Values, clientIP string) (*models.PresignedURL, error) {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	p := &models.PresignedURL{ID: q.Get("id"), Method: method, Name: name, IP: q.Get("ip")}
+	var err error
+	if p.UID, err = strconv.ParseInt(q.Get("uid"), 10, 64); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if p.Owner, err = strconv.ParseInt(q.Get("owner"), 10, 64); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	p.ExpiresAt = time.Unix(exp, 0)
+	if v := q.Get("max_uses"); v != "" {
+		if p.MaxUses, err = strconv.Atoi(v); err != nil || p.MaxUses <= 0 {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	sig, err := hex.DecodeString(q.Get("sig"))
+	if err != nil || p.ID == "" || !hmac.Equal(sig, s.mac(p)) {
+		return nil, ErrInvalidSignature
+	}
+	if !time.Now().Before(p.ExpiresAt) {
+		return nil, ErrPresignExpired
+	}
+	if p.IP != "" && p.IP != normalizeIP(clientIP) {
+		return nil, ErrPresignIPMismatch
+	}
+
+	// Владельцу ACL разрешает всё, поэтому блокировку учётных записей проверяем отдельно
+	active, err := usersActive(ctx, s.userRepo, p.UID, p.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrPresignRevoked
+	}
+
+	permission := models.PermissionRead
+	if method == http.MethodPut {
+		permission = models.PermissionWrite
+		// Запись по ссылке разрешена, пока выпустивший её может писать сам (как при выпуске)
+		canWrite, err := s.canWrite(ctx, p.UID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite {
+			return nil, ErrPresignRevoked
+		}
+	}
+	allowed, err := s.aclService.Allowed(ctx, p.UID, p.Owner, p.Name, permission)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrPresignRevoked
+	}
+
+	if p.MaxUses > 0 {
+		ok, err := s.presignRepo.Use(ctx, p.ID, p.MaxUses, p.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrPresignExhausted
+		}
+	}
+	return p, nil
+}
+
+// canWrite сообщает, может ли пользователь uid сейчас изменять файлы: его роль не readonly,
+// а если роль требует второй фактор, он подключён. Те же ограничения действуют при выпуске
+// ссылки (models.Principal.HasScope), но роль и политика могли измениться после него.
+func (s *PresignService) canWrite(ctx context.Context, uid int64) (bool, error) {
+	user, err := s.userRepo.GetUserByID(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	if user.Role == models.RoleReadOnly {
+		return false, nil
+	}
+	pending, err := s.mfaService.Pending(ctx, user, nil)
+	return !pending, err
+}
+
+// DeleteExpiredUses удаляет счётчики использований просроченных ссылок.
+// Вызывается периодически фоновой задачей.
+func (s *PresignService) DeleteExpiredUses(ctx context.Context) error {
+	n, err := s.presignRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[INFO] Removed %d expired presigned url counters", n)
+	}
+	return nil
+}
+
+// sign возвращает подпись ссылки в hex.
+func (s *PresignService) sign(p *models.PresignedURL) string {
+	return hex.EncodeToString(s.mac(p))
+}
+
+// mac вычисляет HMAC-SHA256 от канонического представления параметров ссылки.
+func (s *PresignService) mac(p *models.PresignedURL) []byte {
+	canonical := strings.Join([]string{
+		p.ID,
+		p.Method,
+		p.Name,
+		strconv.FormatInt(p.UID, 10),
+		strconv.FormatInt(p.Owner, 10),
+		strconv.FormatInt(p.ExpiresAt.Unix(), 10),
+		strconv.Itoa(p.MaxUses),
+		p.IP,
+	}, "\n")
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(canonical))
+	return m.Sum(nil)
+}
+
+// normalizeIP приводит IP-адрес к каноническому виду (пустая строка, если адрес некорректен).
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+)
+
+var (
+	// ErrQuotaExceeded возвращается, если загрузка превысила бы квоту пользователя
+	// на общий объём или количество файлов.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrFileTooLarge возвращается, если размер файла превышает допустимый для пользователя.
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrInvalidQuota возвращается при отрицательных ограничениях квоты или неизвестной роли.
+	ErrInvalidQuota = errors.New("invalid quota")
+)
+
+// QuotaService реализует просмотр потребления хранилища и управление квотами ролей
+// и отдельных пользователей. Сами квоты проверяются AssetService при загрузке файлов.
+type QuotaService struct {
+	quotaRepo *repository.QuotaRepository // Репозиторий квот и счётчиков потребления
+}
+
+// NewQuotaService создаёт новый экземпляр QuotaService.
+func NewQuotaService(quotaRepo *repository.QuotaRepository) *QuotaService {
+	return &QuotaService{quotaRepo: quotaRepo}
+}
+
+// Usage возвращает потребление пользователя uid и действующую для него квоту.
+func (s *QuotaService) Usage(ctx context.Context, uid int64) (*models.Usage, error) {
+	u, err := s.quotaRepo.Usage(ctx, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+// SetUserQuota задаёт персональную квоту пользователя uid. Поля, равные nil, берутся
+// из квоты роли пользователя. Уже загруженные файлы не удаляются, даже если новая квота
+// меньше текущего потребления: пользователь лишь не сможет загружать новые.
+func (s *QuotaService) SetUserQuota(ctx context.Context, uid int64, q models.Quota) (*models.Usage, error) {
+	if !validQuota(q) {
+		return nil, ErrInvalidQuota
+	}
+	if _, err := s.Usage(ctx, uid); err != nil {
+		return nil, err
+	}
+	if err := s.quotaRepo.SetUserQuota(ctx, uid, q); err != nil {
+		return nil, err
+	}
+	return s.Usage(ctx, uid)
+}
+
+// ResetUserQuota удаляет персональную квоту пользователя uid: снова действует квота его роли.
+func (s *QuotaService) ResetUserQuota(ctx context.Context, uid int64) (*models.Usage, error) {
+	if _, err := s.Usage(ctx, uid); err != nil {
+		return nil, err
+	}
+	if err := s.quotaRepo.DeleteUserQuota(ctx, uid); err != nil {
+		return nil, err
+	}
+	return s.Usage(ctx, uid)
+}
+
+// ListRoleQuotas возвращает квоты всех ролей.
+func (s *QuotaService) ListRoleQuotas(ctx context.Context) ([]models.RoleQuota, error) {
+	return s.quotaRepo.ListRoleQuotas(ctx)
+}
+
+// SetRoleQuota задаёт квоту роли role. Поля, равные nil, означают отсутствие ограничения.
+func (s *QuotaService) SetRoleQuota(ctx context.Context, role string, q models.Quota) (*models.RoleQuota, error) {
+	if !validRole(role) || !validQuota(q) {
+		return nil, ErrInvalidQuota
+	}
+	if err := s.quotaRepo.SetRoleQuota(ctx, role, q); err != nil {
+		return nil, err
+	}
+	return &models.RoleQuota{Role: role, Quota: q}, nil
+}
+
+// validQuota проверяет, что заданные ограничения квоты неотрицательны.
+func validQuota(q models.Quota) bool {
+	for _, v := range []*int64{q.MaxBytes, q.MaxObjects, q.MaxFileSize} {
+		if v != nil && *v < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go-asset-service/internal/config"
+	"go-asset-service/internal/lockout"
+	"go-asset-service/internal/notify"
+	"go-asset-service/internal/oidc"
+	"go-asset-service/internal/repository"
+	"go-asset-service/internal/storage"
+)
+
+// Services — сервисы приложения. Создаются один раз при запуске и используются
+// как HTTP-обработчиками, так и фоновыми задачами обслуживания.
+type Services struct {
+	LoginGuard *LoginGuard
+	JWT        *JWTService
+	MFA        *MFAService
+	Auth       *AuthService
+	Asset      *AssetService
+	Upload     *UploadService
+	APIKey     *APIKeyService
+	ACL        *ACLService
+	Presign    *PresignService
+	Share      *ShareService
+	User       *UserService
+	Account    *AccountService
+	Quota      *QuotaService
+	OIDC       *OIDCService
+	ClientCert *ClientCertService
+	Audit      *AuditService
+}
+
+// NewServices создаёт репозитории и сервисы приложения.
+// store — хранилище содержимого файлов (локальный диск или S3-совместимый сервис),
+// notifier — канал уведомлений пользователям (токены сброса пароля),
+// attempts — счётчики неудачных попыток входа,
+// oidcProvider — клиент провайдера OpenID Connect (nil — вход через OIDC выключен),
+// jwtSrv — выдача и проверка токенов доступа в формате JWT.
+func NewServices(pool *pgxpool.Pool, store storage.BlobStore, notifier notify.Notifier, attempts lockout.AttemptStore, oidcProvider *oidc.Provider, jwtSrv *JWTService, cfg *config.Config) *Services {
+	// Создаем репозитории для работы с пользователями, сессиями, файлами и загрузками.
+	userRepo := repository.NewUserRepository(pool)
+	sessionRepo := repository.NewSessionRepository(pool)
+	assetRepo := repository.NewAssetRepository(pool)
+	uploadRepo := repository.NewUploadRepository(pool)
+	apiKeyRepo := repository.NewAPIKeyRepository(pool)
+	aclRepo := repository.NewACLRepository(pool)
+	presignRepo := repository.NewPresignRepository(pool)
+	shareRepo := repository.NewShareRepository(pool)
+	resetRepo := repository.NewPasswordResetRepository(pool)
+	quotaRepo := repository.NewQuotaRepository(pool)
+	mfaRepo := repository.NewMFARepository(pool)
+	mfaChallengeRepo := repository.NewMFAChallengeRepository(pool)
+	oidcRepo := repository.NewOIDCRepository(pool)
+	clientCertRepo := repository.NewClientCertRepository(pool)
+	auditRepo := repository.NewAuditRepository(pool)
+
+	// Инициализируем сервисы авторизации, работы с файлами и возобновляемых загрузок.
+	s := &Services{JWT: jwtSrv}
+	s.LoginGuard = NewLoginGuard(attempts, cfg)
+	s.MFA = NewMFAService(mfaRepo, mfaChallengeRepo, userRepo, sessionRepo, jwtSrv, cfg)
+	s.Auth = NewAuthService(userRepo, sessionRepo, s.LoginGuard, s.MFA, jwtSrv, cfg)
+	s.Asset = NewAssetService(assetRepo, quotaRepo, store)
+	s.Upload = NewUploadService(uploadRepo, s.Asset, store, cfg.UploadTTL, cfg.UploadMaxPending)
+	s.APIKey = NewAPIKeyService(apiKeyRepo)
+	s.ACL = NewACLService(aclRepo, userRepo)
+	s.Presign = NewPresignService(presignRepo, s.ACL, userRepo, s.MFA, cfg.PresignSecret, cfg.PresignMaxTTL)
+	s.Share = NewShareService(shareRepo, s.ACL, userRepo, s.LoginGuard, cfg.PasswordHashScheme)
+	s.User = NewUserService(userRepo, sessionRepo, jwtSrv, s.Asset, s.Upload, s.LoginGuard, cfg.PasswordHashScheme)
+	s.Account = NewAccountService(userRepo, sessionRepo, jwtSrv, resetRepo, s.LoginGuard, notifier, cfg)
+	s.Quota = NewQuotaService(quotaRepo)
+	s.OIDC = NewOIDCService(oidcProvider, oidcRepo, userRepo, s.Auth, cfg)
+	s.ClientCert = NewClientCertService(clientCertRepo, userRepo)
+	s.Audit = NewAuditService(auditRepo)
+	return s
+}
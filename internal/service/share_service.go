@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// Длина случайного slug в байтах (12 символов base64url) и допустимая длина пользовательского slug.
+const (
+	shareSlugBytes  = 9
+	minShareSlugLen = 4
+	maxShareSlugLen = 64
+)
+
+var (
+	// ErrInvalidShare возвращается при некорректных параметрах создания ссылки.
+	ErrInvalidShare = errors.New("invalid share link parameters")
+	// ErrShareExists возвращается, если ссылка с указанным slug уже существует.
+	ErrShareExists = errors.New("share link already exists")
+	// ErrShareNotFound возвращается, если ссылки нет или она отозвана.
+	ErrShareNotFound = errors.New("share link not found")
+	// ErrShareExpired возвращается, если срок действия ссылки истёк.
+	ErrShareExpired = errors.New("share link expired")
+	// ErrSharePassword возвращается, если ссылка защищена паролем, а пароль не указан или неверен.
+	ErrSharePassword = errors.New("share link password required")
+	// ErrShareLimitReached возвращается, если лимит скачиваний по ссылке исчерпан.
+	ErrShareLimitReached = errors.New("share link download limit reached")
+)
+
+// ShareService реализует постоянные публичные ссылки /s/{slug} на файлы и «папки»:
+// выпуск, просмотр со счётчиками обращений, отзыв и проверку при обращении без токена.
+type ShareService struct {
+	shareRepo      *repository.ShareRepository // Репозиторий ссылок
+	aclService     *ACLService                 // Проверка доступа к чужим файлам
+	userRepo       *repository.UserRepository  // Проверка блокировки выпустившего ссылку и владельца
+	loginGuard     *LoginGuard                 // Защита паролей ссылок от перебора
+	passwordScheme string                      // Схема хеширования паролей ссылок
+}
+
+// NewShareService создаёт новый экземпляр ShareService.
+func NewShareService(shareRepo *repository.ShareRepository, aclService *ACLService, userRepo *repository.UserRepository, loginGuard *LoginGuard, passwordScheme string) *ShareService {
+	return &ShareService{
+		shareRepo:      shareRepo,
+		aclService:     aclService,
+		userRepo:       userRepo,
+		loginGuard:     loginGuard,
+		passwordScheme: passwordScheme,
+	}
+}
+
+// Create выпускает ссылку пользователя uid на файл name (или на все файлы с префиксом, если
+// name заканчивается на «/») владельца owner. slug — желаемый идентификатор (пусто — случайный);
+// password, expiresAt и maxDownloads необязательны. Если у пользователя нет права read на чужой
+// файл, возвращается ErrAccessDenied.
+func (s *ShareService) Create(ctx context.Context, uid, owner int64, name, slug, password string, expiresAt *time.Time, maxDownloads *int) (*models.ShareLink, error) {
+	if !ValidAssetName(strings.TrimSuffix(name, "/")) {
+		return nil, ErrInvalidShare
+	}
+	if (expiresAt != nil && !expiresAt.After(time.Now())) || (maxDownloads != nil && *maxDownloads <= 0) {
+		return nil, ErrInvalidShare
+	}
+	if slug == "" {
+		var err error
+		if slug, err = generateSlug(); err != nil {
+			return nil, err
+		}
+	} else if !validSlug(slug) {
+		return nil, ErrInvalidShare
+	}
+
+	allowed, err := s.aclService.Allowed(ctx, uid, owner, name, models.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrAccessDenied
+	}
+
+	link := &models.ShareLink{
+		Slug:         slug,
+		UID:          uid,
+		Owner:        owner,
+		Name:         name,
+		ExpiresAt:    expiresAt,
+		MaxDownloads: maxDownloads,
+	}
+	if password != "" {
+		if link.PasswordHash, err = utils.HashPassword(s.passwordScheme, password); err != nil {
+			return nil, err
+		}
+		link.HasPassword = true
+	}
+
+	err = s.shareRepo.Create(ctx, link)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrShareExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// List возвращает ссылки, выпущенные пользователем, со счётчиками обращений и скачиваний.
+func (s *ShareService) List(ctx context.Context, uid int64) ([]models.ShareLink, error) {
+	return s.shareRepo.ListByUID(ctx, uid)
+}
+
+// Revoke отзывает ссылку пользователя; после этого она перестаёт открываться,
+// но остаётся в списке со своими счётчиками.
+func (s *ShareService) Revoke(ctx context.Context, uid int64, slug string) error {
+	ok, err := s.shareRepo.Revoke(ctx, uid, slug)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// Open проверяет ссылку slug при обращении без токена: что она существует и не отозвана,
+// не истекла, пароль (если он задан) верен, а у выпустившего её пользователя всё ещё есть
+// доступ к файлам. Обращение при этом не засчитывается — см. RecordAccess.
+// Неверные пароли считаются по ссылке и по адресу клиента ip: после неудач пароль не
+// проверяется до окончания задержки, и возвращается *AttemptsError.
+func (s *ShareService) Open(ctx context.Context, slug, password, ip string) (*models.ShareLink, error) {
+	link, err := s.shareRepo.FindBySlug(ctx, slug)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != nil {
+		return nil, ErrShareNotFound
+	}
+	if link.ExpiresAt != nil && !time.Now().Before(*link.ExpiresAt) {
+		return nil, ErrShareExpired
+	}
+	// Ссылки заблокированного пользователя (или на файлы заблокированного владельца)
+	// не работают, даже если пароль известен
+	active, err := usersActive(ctx, s.userRepo, link.UID, link.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrShareNotFound
+	}
+	if link.HasPassword {
+		if password == "" {
+			return nil, ErrSharePassword
+		}
+		attempt, err := s.loginGuard.ReserveShare(ctx, slug, ip)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := s.loginGuard.VerifyPassword(ctx, link.PasswordHash, password)
+		if err != nil || !ok {
+			return nil, ErrSharePassword
+		}
+		s.loginGuard.Release(ctx, attempt)
+		if err := s.loginGuard.SucceedShare(ctx, slug); err != nil {
+			log.Printf("[WARN] Failed to reset share password attempts: slug=%s err=%v", slug, err)
+		}
+	}
+
+	allowed, err := s.aclService.Allowed(ctx, link.UID, link.Owner, link.Name, models.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrShareNotFound
+	}
+	return link, nil
+}
+
+// RecordAccess засчитывает обращение по ссылке, а если download — и скачивание.
+// Если лимит скачиваний исчерпан, возвращается ErrShareLimitReached.
+func (s *ShareService) RecordAccess(ctx context.Context, slug string, download bool) error {
+	err := s.shareRepo.RecordAccess(ctx, slug, download)
+	if errors.Is(err, repository.ErrLimitReached) {
+		return ErrShareLimitReached
+	}
+	return err
+}
+
+// generateSlug генерирует случайный slug из символов base64url.
+func generateSlug() (string, error) {
+	b := make([]byte, shareSlugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validSlug проверяет пользовательский slug: латинские буквы, цифры, '-' и '_'.
+func validSlug(slug string) bool {
+	if len(slug) < minShareSlugLen || len(slug) > maxShareSlugLen {
+		return false
+	}
+	return strings.IndexFunc(slug, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_')
+	}) < 0
+}
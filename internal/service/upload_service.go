@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/internal/storage"
+	"go-asset-service/pkg/utils"
+)
+
+var (
+	// ErrUploadNotFound возвращается, если загрузки нет или она принадлежит другому пользователю.
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch возвращается, если часть пришла не с текущего смещения загрузки.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrChunkTooLarge возвращается, если часть выходит за объявленный размер загрузки.
+	ErrChunkTooLarge = errors.New("chunk exceeds upload length")
+	// ErrInvalidUpload возвращается при некорректных параметрах создания загрузки.
+	ErrInvalidUpload = errors.New("invalid upload parameters")
+	// ErrTooManyUploads возвращается, если у пользователя уже максимальное число незавершённых загрузок.
+	ErrTooManyUploads = errors.New("too many pending uploads")
+)
+
+// UploadService реализует протокол возобновляемой загрузки (по мотивам tus):
+// создание загрузки, дозапись частей по смещению, запрос текущего смещения и сборка
+// итогового файла. Каждая часть хранится в BlobStore отдельным объектом, а состояние
+// загрузки — в Postgres, поэтому прерванную загрузку можно продолжить после обрыва связи.
+type UploadService struct {
+	uploadRepo   *repository.UploadRepository // Репозиторий незавершённых загрузок
+	assetService *AssetService                // Сервис, в который собирается итоговый файл
+	store        storage.BlobStore            // Хранилище частей загрузки
+	ttl          time.Duration                // Время жизни загрузки без активности
+	maxPending   int                          // Максимум незавершённых загрузок пользователя (0 — без ограничения)
+}
+
+// NewUploadService создаёт новый экземпляр UploadService.
+func NewUploadService(uploadRepo *repository.UploadRepository, assetService *AssetService, store storage.BlobStore, ttl time.Duration, maxPending int) *UploadService {
+	return &UploadService{
+		uploadRepo:   uploadRepo,
+		assetService: assetService,
+		store:        store,
+		ttl:          ttl,
+		maxPending:   maxPending,
+	}
+}
+
+// Create создаёт новую загрузку файла name итоговым размером length байт.
+// contentType и metadata будут записаны в итоговый файл; пустой contentType
+// определяется по содержимому при сборке.
+// Объявленный размер незавершённых загрузок учитывается в квоте, поэтому принятые, но ещё
+// не собранные части не могут её превысить. Если файл такого размера не поместится в квоту
+// пользователя, загрузка не создаётся (ErrFileTooLarge или ErrQuotaExceeded); при сборке
+// квота проверяется повторно. Если у пользователя уже maxPending незавершённых загрузок,
+// возвращается ErrTooManyUploads.
+func (s *UploadService) Create(ctx context.Context, uid int64, name string, length int64, contentType string, metadata map[string]string) (*models.Upload, error) {
+	if !ValidAssetName(name) || length < 0 {
+		return nil, ErrInvalidUpload
+	}
+	if err := s.assetService.CheckQuota(ctx, uid, name, length); err != nil {
+		return nil, err
+	}
+	id, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	u := &models.Upload{
+		ID:          id,
+		UID:         uid,
+		Name:        name,
+		Length:      length,
+		ContentType: contentType,
+		Metadata:    metadata,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	err = s.uploadRepo.Create(ctx, u, s.maxPending)
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		return nil, ErrQuotaExceeded
+	}
+	if errors.Is(err, repository.ErrTooManyUploads) {
+		return nil, ErrTooManyUploads
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get возвращает состояние загрузки (в том числе текущее смещение).
+func (s *UploadService) Get(ctx context.Context, uid int64, id string) (*models.Upload, error) {
+	u, err := s.uploadRepo.Get(ctx, id, uid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUploadNotFound
+	}
+	return u, err
+}
+
+// WriteChunk дописывает часть данных, начиная со смещения offset.
+// Часть потоково записывается в хранилище и только затем регистрируется в БД, поэтому
+// оборванная на середине часть не сдвигает смещение — клиент просто повторяет её.
+// Когда загрузка получает все байты, она собирается в Asset, который и возвращается.
+func (s *UploadService) WriteChunk(ctx context.Context, uid int64, id string, offset int64, body io.Reader, size int64) (*models.Upload, *models.Asset, error) {
+	u, err := s.Get(ctx, uid, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset != u.Offset {
+		return u, nil, ErrOffsetMismatch
+	}
+	remaining := u.Length - u.Offset
+	if size > remaining {
+		return u, nil, ErrChunkTooLarge
+	}
+
+	key, err := utils.GenerateToken(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	written, err := s.store.Put(ctx, key, io.LimitReader(body, remaining), size)
+	if err != nil {
+		s.deleteBlob(key)
+		return nil, nil, err
+	}
+	// Данные сверх объявленного размера не принимаются
+	if written == remaining {
+		if n, _ := body.Read(make([]byte, 1)); n > 0 {
+			s.deleteBlob(key)
+			return u, nil, ErrChunkTooLarge
+		}
+	}
+
+	if written > 0 {
+		chunk := &models.UploadChunk{UploadID: u.ID, Offset: offset, Size: written, StorageKey: key}
+		u, err = s.uploadRepo.AddChunk(ctx, chunk, time.Now().Add(s.ttl))
+		if err != nil {
+			s.deleteBlob(key)
+			if errors.Is(err, repository.ErrOffsetMismatch) {
+				return nil, nil, ErrOffsetMismatch
+			}
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, nil, ErrUploadNotFound
+			}
+			return nil, nil, err
+		}
+	} else {
+		s.deleteBlob(key)
+	}
+
+	if u.Offset < u.Length {
+		return u, nil, nil
+	}
+	asset, err := s.finalize(ctx, u)
+	if err != nil {
+		return u, nil, err
+	}
+	return u, asset, nil
+}
+
+// Abort отменяет загрузку и удаляет все принятые части.
+func (s *UploadService) Abort(ctx context.Context, uid int64, id string) error {
+	if _, err := s.Get(ctx, uid, id); err != nil {
+		return err
+	}
+	return s.remove(ctx, id)
+}
+
+// AbortAll отменяет все незавершённые загрузки пользователя (например, перед удалением
+// его учётной записи) и удаляет их части из хранилища.
+func (s *UploadService) AbortAll(ctx context.Context, uid int64) error {
+	ids, err := s.uploadRepo.ListIDsByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.remove(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CollectGarbage удаляет загрузки, в которые давно не поступали данные, вместе с их частями.
+func (s *UploadService) CollectGarbage(ctx context.Context) error {
+	ids, err := s.uploadRepo.ListExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.remove(ctx, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("[INFO] Removed %d abandoned uploads", len(ids))
+	}
+	return nil
+}
+
+// finalize последовательно читает части загрузки из хранилища, собирает из них Asset
+// и удаляет саму загрузку.
+func (s *UploadService) finalize(ctx context.Context, u *models.Upload) (*models.Asset, error) {
+	chunks, err := s.uploadRepo.ListChunks(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	content := &chunkReader{ctx: ctx, store: s.store, chunks: chunks}
+	defer content.Close()
+
+	asset, err := s.assetService.upload(ctx, u.UID, u.Name, content, u.Length, u.ContentType, u.Metadata, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.remove(ctx, u.ID); err != nil {
+		log.Printf("[WARN] Failed to remove finalized upload id=%s: %v", u.ID, err)
+	}
+	return asset, nil
+}
+
+// remove удаляет загрузку из БД, а затем её части из хранилища.
+func (s *UploadService) remove(ctx context.Context, id string) error {
+	keys, err := s.uploadRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+	return nil
+}
+
+// deleteBlob удаляет объект из хранилища, ошибка только логируется.
+func (s *UploadService) deleteBlob(key string) {
+	if err := s.store.Delete(context.Background(), key); err != nil {
+		log.Printf("[WARN] Failed to delete upload chunk key=%s: %v", key, err)
+	}
+}
+
+// chunkReader последовательно читает части загрузки, открывая каждую только при переходе к ней.
+type chunkReader struct {
+	ctx     context.Context
+	store   storage.BlobStore
+	chunks  []models.UploadChunk
+	current io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.chunks) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := c.store.Get(c.ctx, c.chunks[0].StorageKey)
+			if err != nil {
+				return 0, err
+			}
+			c.current = rc
+			c.chunks = c.chunks[1:]
+		}
+		n, err := c.current.Read(p)
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.current != nil {
+		return c.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"go-asset-service/internal/models"
+	"go-asset-service/internal/repository"
+	"go-asset-service/pkg/utils"
+)
+
+// Ограничения на логин и пароль пользователей, создаваемых через API.
+// Пароль ограничен сверху 72 байтами — пределом bcrypt.
+const (
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 10
+	maxPasswordLen = 72
+)
+
+// commonPasswords — самые распространённые пароли достаточной длины, которые отклоняются
+// независимо от остальных правил.
+var commonPasswords = map[string]bool{
+	"1234567890": true, "0123456789": true, "1111111111": true, "0000000000": true,
+	"qwertyuiop": true, "password12": true, "password123": true, "password1234": true,
+	"qwerty1234": true, "qwerty12345": true, "1q2w3e4r5t": true, "iloveyou12": true,
+	"letmein123": true, "welcome123": true, "admin12345": true, "abcdefghij": true,
+}
+
+var (
+	// ErrInvalidUser возвращается при недопустимом логине или роли.
+	ErrInvalidUser = errors.New("invalid user parameters")
+	// ErrWeakPassword возвращается, если пароль не удовлетворяет требованиям к сложности.
+	ErrWeakPassword = errors.New("password too weak")
+	// ErrUserExists возвращается, если пользователь с таким логином уже существует.
+	ErrUserExists = errors.New("user already exists")
+	// ErrAccountDisabled возвращается, если учётная запись заблокирована администратором.
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrSelfModification возвращается, если администратор пытается удалить, заблокировать
+	// свою учётную запись или лишить себя роли администратора.
+	ErrSelfModification = errors.New("cannot modify own account")
+)
+
+// UserService реализует управление учётными записями администратором: создание, смену роли,
+// блокировку, сброс пароля, принудительное завершение сессий, снятие блокировки после неудачных
+// попыток входа и удаление пользователей.
+type UserService struct {
+	userRepo       *repository.UserRepository    // Репозиторий пользователей
+	sessionRepo    *repository.SessionRepository // Репозиторий сессий (принудительный выход)
+	jwtService     *JWTService                   // Отзыв токенов доступа JWT завершённых сессий
+	assetService   *AssetService                 // Удаление файлов пользователя
+	uploadService  *UploadService                // Отмена незавершённых загрузок пользователя
+	loginGuard     *LoginGuard                   // Снятие блокировки после неудачных попыток входа
+	passwordScheme string                        // Схема хеширования паролей
+}
+
+// NewUserService создаёт новый экземпляр UserService.
+func NewUserService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, jwtService *JWTService, assetService *AssetService, uploadService *UploadService, loginGuard *LoginGuard, passwordScheme string) *UserService {
+	return &UserService{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		jwtService:     jwtService,
+		assetService:   assetService,
+		uploadService:  uploadService,
+		loginGuard:     loginGuard,
+		passwordScheme: passwordScheme,
+	}
+}
+
+// Active возвращает пользователя uid, если его учётная запись существует и не заблокирована.
+// Вызывается при каждой аутентификации запроса, чтобы блокировка действовала сразу,
+// в том числе для API-ключей пользователя.
+func (s *UserService) Active(ctx context.Context, uid int64) (*models.User, error) {
+	u, err := s.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if u.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	return u, nil
+}
+
+// Get возвращает пользователя по идентификатору.
+func (s *UserService) Get(ctx context.Context, id int64) (*models.User, error) {
+	u, err := s.userRepo.GetUserByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return u, err
+}
+
+// List возвращает всех пользователей.
+func (s *UserService) List(ctx context.Context) ([]models.User, error) {
+	return s.userRepo.ListUsers(ctx)
+}
+
+// Create создаёт пользователя с ролью role (по умолчанию — user).
+func (s *UserService) Create(ctx context.Context, login, password, role string) (*models.User, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+	if !validLogin(login) || !validRole(role) {
+		return nil, ErrInvalidUser
+	}
+	if err := checkPassword(login, password); err != nil {
+		return nil, err
+	}
+	hash, err := utils.HashPassword(s.passwordScheme, password)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &models.User{Login: login, PasswordHash: hash, Role: role}
+	err = s.userRepo.CreateUser(ctx, u)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Bootstrap создаёт администратора login с паролем password, если пользователя с таким логином
+// ещё нет. Вызывается при запуске; пустой пароль отключает создание. Существующая учётная
+// запись не меняется: пароль из окружения не должен перезаписывать сменённый пароль.
+func (s *UserService) Bootstrap(ctx context.Context, login, password string) error {
+	if password == "" {
+		return nil
+	}
+	_, err := s.userRepo.FindByLogin(ctx, login)
+	if err == nil {
+		log.Printf("[INFO] Admin bootstrap skipped: user %q already exists", login)
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	u, err := s.Create(ctx, login, password, models.RoleAdmin)
+	if errors.Is(err, ErrUserExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Created admin user %q (id %d)", u.Login, u.ID)
+	return nil
+}
+
+// Update меняет роль пользователя id и/или блокирует его (disabled = true) либо снимает
+// блокировку. Параметры, равные nil, не меняются. При смене роли и при блокировке все сессии
+// пользователя завершаются: токены доступа в формате JWT несут роль, с которой они выданы,
+// и иначе действовали бы с прежней ролью до истечения срока. adminUID — администратор,
+// выполняющий действие: себя он заблокировать или лишить роли администратора не может.
+func (s *UserService) Update(ctx context.Context, adminUID, id int64, role *string, disabled *bool) (*models.User, error) {
+	if role != nil && !validRole(*role) {
+		return nil, ErrInvalidUser
+	}
+	if id == adminUID && ((role != nil && *role != models.RoleAdmin) || (disabled != nil && *disabled)) {
+		return nil, ErrSelfModification
+	}
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != nil && *role != u.Role {
+		if err := s.userRepo.UpdateRole(ctx, id, *role); err != nil {
+			return nil, err
+		}
+		if err := s.revokeSessions(ctx, id); err != nil {
+			return nil, err
+		}
+		u.Role = *role
+	}
+	if disabled != nil && *disabled != (u.DisabledAt != nil) {
+		if *disabled {
+			err = s.disable(ctx, id)
+			now := time.Now()
+			u.DisabledAt = &now
+		} else {
+			err = s.userRepo.SetDisabled(ctx, id, nil)
+			u.DisabledAt = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// ResetPassword задаёт пользователю новый пароль и завершает все его сессии.
+func (s *UserService) ResetPassword(ctx context.Context, id int64, password string) error {
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(u.Login, password); err != nil {
+		return err
+	}
+	hash, err := utils.HashPassword(s.passwordScheme, password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePasswordHash(ctx, id, hash); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, id)
+}
+
+// RevokeSessions принудительно завершает все сессии пользователя.
+func (s *UserService) RevokeSessions(ctx context.Context, id int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, id)
+}
+
+// Unlock снимает временную блокировку входа пользователя id после неудачных попыток
+// и сбрасывает их счётчик. Блокировку по IP-адресу клиента это не снимает.
+func (s *UserService) Unlock(ctx context.Context, id int64) error {
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.loginGuard.Unlock(ctx, u.Login)
+}
+
+// Delete удаляет пользователя id. Сначала учётная запись блокируется, чтобы пользователь не мог
+// загружать новые файлы, затем отменяются его загрузки и удаляются файлы (с освобождением
+// содержимого в хранилище), и только после этого — сама запись со всеми связанными данными.
+func (s *UserService) Delete(ctx context.Context, adminUID, id int64) error {
+	if id == adminUID {
+		return ErrSelfModification
+	}
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.DisabledAt == nil {
+		if err := s.disable(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.uploadService.AbortAll(ctx, id); err != nil {
+		return err
+	}
+	n, err := s.assetService.DeleteAll(ctx, id)
+	if err != nil {
+		return err
+	}
+	ok, err := s.userRepo.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserNotFound
+	}
+	log.Printf("[INFO] User deleted: id=%d login=%s assets=%d", id, u.Login, n)
+	return nil
+}
+
+// disable блокирует учётную запись и завершает все сессии пользователя.
+func (s *UserService) disable(ctx context.Context, id int64) error {
+	now := time.Now()
+	if err := s.userRepo.SetDisabled(ctx, id, &now); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, id)
+}
+
+// revokeSessions завершает все сессии пользователя и сразу отзывает их токены доступа JWT
+// на этом экземпляре сервиса.
+func (s *UserService) revokeSessions(ctx context.Context, id int64) error {
+	revoked, err := s.sessionRepo.DeleteByUID(ctx, id)
+	if err != nil {
+		return err
+	}
+	s.jwtService.Deny(revoked...)
+	return nil
+}
+
+// usersActive проверяет, что все пользователи ids существуют и не заблокированы.
+// Используется для ссылок, действующих без сессии: они перестают работать вместе
+// с учётной записью выпустившего их пользователя или владельца файла.
+func usersActive(ctx context.Context, userRepo *repository.UserRepository, ids ...int64) (bool, error) {
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		u, err := userRepo.GetUserByID(ctx, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if u.DisabledAt != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validLogin проверяет логин: от minLoginLen до maxLoginLen символов — латинские буквы, цифры,
+// '.', '_', '-' и '@', начинается с буквы или цифры.
+func validLogin(login string) bool {
+	if len(login) < minLoginLen || len(login) > maxLoginLen {
+		return false
+	}
+	if !isAlnum(rune(login[0])) {
+		return false
+	}
+	return strings.IndexFunc(login, func(c rune) bool {
+		return !(isAlnum(c) || c == '.' || c == '_' || c == '-' || c == '@')
+	}) < 0
+}
+
+func isAlnum(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// checkPassword проверяет сложность пароля пользователя login: длина от minPasswordLen
+// до maxPasswordLen байт, хотя бы два разных класса символов (буквы, цифры, прочие),
+// пароль не содержит логин и не входит в список распространённых.
+// Иначе возвращается ErrWeakPassword.
+func checkPassword(login, password string) error {
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return ErrWeakPassword
+	}
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] || strings.Contains(lower, strings.ToLower(login)) {
+		return ErrWeakPassword
+	}
+
+	var letters, digits, others bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			letters = true
+		case unicode.IsDigit(c):
+			digits = true
+		default:
+			others = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{letters, digits, others} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < 2 {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+func validRole(role string) bool {
+	for _, r := range models.AllRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
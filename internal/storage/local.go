@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore хранит объекты в виде файлов в каталоге на локальной файловой системе.
+type LocalStore struct {
+	root string // Корневой каталог хранилища
+}
+
+// NewLocalStore создаёт LocalStore с корнем в каталоге root (каталог создаётся при необходимости).
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, errors.New("local storage path is empty")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put потоково записывает данные во временный файл и атомарно переименовывает его в итоговый,
+// чтобы читатели никогда не видели частично записанный объект.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	// Если что-то пошло не так, временный файл удаляется
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, readerWithContext(ctx, r))
+	if err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if size >= 0 && n != size {
+		tmp.Close()
+		return n, fmt.Errorf("short write: expected %d bytes, got %d", size, n)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Get открывает файл объекта для чтения.
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete удаляет файл объекта.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path преобразует ключ объекта в путь на диске, не позволяя выйти за пределы корневого каталога.
+// Объекты раскладываются по подкаталогам по первым двум символам ключа.
+func (s *LocalStore) path(key string) (string, error) {
+	if len(key) < 3 || strings.ContainsAny(key, `/\`) || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, key[:2], key), nil
+}
+
+// readerWithContext прерывает чтение, если контекст отменён (например, клиент оборвал соединение).
+func readerWithContext(ctx context.Context, r io.Reader) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return r.Read(p)
+	})
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3MaxSinglePut — максимальный размер объекта, который S3 принимает одним PUT-запросом.
+	s3MaxSinglePut = 5 << 30
+	// s3PartSize — размер части при multipart-загрузке. Именно столько данных
+	// одновременно держится в памяти при загрузке потока неизвестной длины.
+	s3PartSize = 8 << 20
+	// s3UnsignedPayload — значение x-amz-content-sha256, при котором тело запроса не подписывается,
+	// что позволяет передавать его потоково.
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Options содержит параметры подключения к S3-совместимому хранилищу (AWS S3, MinIO и т.п.).
+type S3Options struct {
+	Endpoint     string // Адрес сервиса, например http://localhost:9000
+	Region       string // Регион, участвующий в подписи запросов
+	Bucket       string // Имя бакета
+	AccessKey    string // Ключ доступа
+	SecretKey    string // Секретный ключ
+	UsePathStyle bool   // Адресация вида endpoint/bucket/key вместо bucket.endpoint/key
+}
+
+// S3Store хранит объекты в S3-совместимом хранилище.
+// Запросы подписываются по схеме AWS Signature Version 4 без использования внешних SDK.
+type S3Store struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Store создаёт S3Store и проверяет корректность параметров.
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse s3 endpoint: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint must be an absolute URL, got %q", opts.Endpoint)
+	}
+	return &S3Store{opts: opts, endpoint: u, client: &http.Client{}}, nil
+}
+
+// Put загружает объект. Если размер известен и не превышает лимит одиночного PUT,
+// тело запроса передаётся потоком; иначе используется multipart-загрузка частями по s3PartSize.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	if size >= 0 && size <= s3MaxSinglePut {
+		req, err := s.newRequest(ctx, http.MethodPut, key, nil, r)
+		if err != nil {
+			return 0, err
+		}
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return size, nil
+	}
+	return s.putMultipart(ctx, key, r)
+}
+
+// Get открывает объект для чтения. Размер объекта запрашивается через HEAD, а данные
+// загружаются лениво ranged GET-запросами начиная с текущей позиции, поэтому Seek не требует
+// скачивания пропущенной части объекта.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &s3Object{store: s, ctx: ctx, key: key, size: resp.ContentLength}, nil
+}
+
+// Delete удаляет объект. S3 отвечает успехом и для несуществующих ключей.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3Object — поток чтения объекта S3 с поддержкой Seek.
+type s3Object struct {
+	store *S3Store
+	ctx   context.Context
+	key   string
+	size  int64
+	pos   int64         // Текущая позиция чтения
+	body  io.ReadCloser // Тело текущего GET-запроса (nil, если запрос ещё не выполнен)
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		req, err := o.store.newRequest(o.ctx, http.MethodGet, o.key, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.pos))
+		resp, err := o.store.do(req)
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		pos = o.size + offset
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+	if pos != o.pos && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
+
+// putMultipart загружает поток неизвестной (или слишком большой) длины через S3 multipart upload.
+// При ошибке незавершённая загрузка отменяется, чтобы не оставлять «висящие» части в бакете.
+func (s *S3Store) putMultipart(ctx context.Context, key string, r io.Reader) (int64, error) {
+	var initResult struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.doXML(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, &initResult); err != nil {
+		return 0, fmt.Errorf("initiate multipart upload: %w", err)
+	}
+	uploadID := initResult.UploadID
+
+	type completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []completedPart
+	var total int64
+	buf := make([]byte, s3PartSize)
+
+	abort := func(cause error) (int64, error) {
+		req, err := s.newRequest(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+		if err == nil {
+			if resp, err := s.do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		return total, cause
+	}
+
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return abort(readErr)
+		}
+		// Пустую часть отправляем только если весь объект пустой
+		if n > 0 || partNumber == 1 {
+			q := url.Values{
+				"partNumber": {strconv.Itoa(partNumber)},
+				"uploadId":   {uploadID},
+			}
+			req, err := s.newRequest(ctx, http.MethodPut, key, q, bytes.NewReader(buf[:n]))
+			if err != nil {
+				return abort(err)
+			}
+			resp, err := s.do(req)
+			if err != nil {
+				return abort(fmt.Errorf("upload part %d: %w", partNumber, err))
+			}
+			resp.Body.Close()
+			parts = append(parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+			total += int64(n)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return abort(err)
+	}
+	if err := s.doXML(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, nil); err != nil {
+		return abort(fmt.Errorf("complete multipart upload: %w", err))
+	}
+	return total, nil
+}
+
+// doXML выполняет запрос с (необязательным) телом и разбирает XML-ответ в out.
+func (s *S3Store) doXML(ctx context.Context, method, key string, query url.Values, body []byte, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := s.newRequest(ctx, method, key, query, r)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// CompleteMultipartUpload может вернуть ошибку внутри ответа со статусом 200
+	if bytes.Contains(data, []byte("<Error>")) {
+		return fmt.Errorf("s3 error: %s", data)
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
+
+// do отправляет подписанный запрос и преобразует ошибочные статусы в ошибки.
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+// newRequest формирует запрос к объекту key с учётом стиля адресации бакета.
+func (s *S3Store) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	if s.opts.UsePathStyle {
+		u.Path = "/" + s.opts.Bucket + "/" + key
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-amz-content-sha256", s3UnsignedPayload)
+	return req, nil
+}
+
+// sign добавляет к запросу заголовок Authorization по схеме AWS Signature Version 4.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + req.Header.Get("x-amz-content-sha256") + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := day + "/" + s.opts.Region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), day)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// s3EscapePath кодирует путь по правилам SigV4: каждый сегмент отдельно, «/» сохраняется.
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = s3Escape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery строит отсортированную строку запроса в каноническом для SigV4 виде.
+func s3CanonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape выполняет URI-кодирование, требуемое SigV4 (незарезервированные символы по RFC 3986 не кодируются).
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 — минимальная замена S3 в памяти: PUT, HEAD, GET с Range и DELETE объектов
+// и multipart upload. Запоминает запросы, чтобы тесты могли проверить, как ходит клиент.
+type fakeS3 struct {
+	bucket string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte // uploadId -> номер части -> данные
+	nextID   int
+	requests []string // "METHOD key?query Range"
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Store) {
+	t.Helper()
+	f := &fakeS3{bucket: "assets", objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:     srv.URL,
+		Bucket:       f.bucket,
+		AccessKey:    "AKIDEXAMPLE",
+		SecretKey:    "secret",
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "Signature=") ||
+		r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") != s3UnsignedPayload {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+	prefix := "/" + f.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+	q := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fmt.Sprintf("%s %s?%s %s", r.Method, key, r.URL.RawQuery, r.Header.Get("Range")))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		var complete struct {
+			Parts []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for i, p := range complete.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"etag-%d"`, p.PartNumber) {
+				// Как и настоящий S3, сообщаем об ошибке внутри ответа со статусом 200
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code></Error>")
+				return
+			}
+			data = append(data, parts[p.PartNumber]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		if int64(len(body)) != r.ContentLength {
+			http.Error(w, "<Error><Code>IncompleteBody</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil || start >= len(data) {
+				http.Error(w, "<Error><Code>InvalidRange</Code></Error>", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start:])
+			return
+		}
+		w.Write(data)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "<Error><Code>NotImplemented</Code></Error>", http.StatusNotImplemented)
+	}
+}
+
+// lastRequests возвращает запросы, полученные после первых skip.
+func (f *fakeS3) lastRequests(skip int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests[skip:]...)
+}
+
+func (f *fakeS3) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func TestS3PutSingle(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+
+	data := []byte("hello, s3")
+	n, err := store.Put(ctx, "blobs/ab/cd ef", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("Put returned %d, want %d", n, len(data))
+	}
+	if got := f.objects["blobs/ab/cd ef"]; !bytes.Equal(got, data) {
+		t.Fatalf("stored %q, want %q", got, data)
+	}
+	if reqs := f.lastRequests(0); len(reqs) != 1 || !strings.HasPrefix(reqs[0], "PUT blobs/ab/cd ef? ") {
+		t.Fatalf("requests = %q, want a single PUT", reqs)
+	}
+
+	if _, err := store.Put(ctx, "empty", bytes.NewReader(nil), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := f.objects["empty"]; !ok || len(got) != 0 {
+		t.Fatalf("empty object not stored: %q, %t", got, ok)
+	}
+}
+
+func TestS3PutMultipart(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+
+	// Размер неизвестен: две полные части и неполная третья
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*s3PartSize+1000)/16)
+	n, err := store.Put(ctx, "big", bytes.NewReader(data), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("Put returned %d, want %d", n, len(data))
+	}
+	if !bytes.Equal(f.objects["big"], data) {
+		t.Fatal("multipart object content mismatch")
+	}
+	if len(f.uploads) != 0 {
+		t.Fatalf("%d multipart uploads left open", len(f.uploads))
+	}
+
+	var parts []string
+	for _, r := range f.lastRequests(0) {
+		if strings.HasPrefix(r, "PUT big?partNumber=") {
+			parts = append(parts, r)
+		}
+	}
+	if len(parts) != 3 {
+		t.Fatalf("uploaded %d parts, want 3: %q", len(parts), parts)
+	}
+}
+
+func TestS3PutMultipartAbortsOnError(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+
+	readErr := errors.New("client went away")
+	r := io.MultiReader(bytes.NewReader(make([]byte, s3PartSize+10)), &failingReader{err: readErr})
+	if _, err := store.Put(ctx, "broken", r, -1); !errors.Is(err, readErr) {
+		t.Fatalf("Put error = %v, want %v", err, readErr)
+	}
+	if len(f.uploads) != 0 {
+		t.Fatalf("%d multipart uploads left open after error", len(f.uploads))
+	}
+	if _, ok := f.objects["broken"]; ok {
+		t.Fatal("object stored despite read error")
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestS3GetRangeAndSeek(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+	f.objects["doc"] = []byte("0123456789")
+
+	obj, err := store.Get(ctx, "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+
+	// Get делает только HEAD: данные запрашиваются при первом чтении
+	if reqs := f.lastRequests(0); len(reqs) != 1 || !strings.HasPrefix(reqs[0], "HEAD doc") {
+		t.Fatalf("requests after Get = %q, want a single HEAD", reqs)
+	}
+
+	if size, err := obj.Seek(0, io.SeekEnd); err != nil || size != 10 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v; want 10", size, err)
+	}
+	if _, err := obj.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(obj, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "456" {
+		t.Fatalf("read %q at offset 4, want %q", buf, "456")
+	}
+
+	// Последовательное чтение продолжает тот же запрос
+	skip := f.requestCount()
+	if _, err := io.ReadFull(obj, buf[:2]); err != nil || string(buf[:2]) != "78" {
+		t.Fatalf("sequential read = %q, %v; want %q", buf[:2], err, "78")
+	}
+	if reqs := f.lastRequests(skip); len(reqs) != 0 {
+		t.Fatalf("sequential read issued new requests: %q", reqs)
+	}
+
+	// Seek назад открывает новый ranged GET с нужной позиции
+	if pos, err := obj.Seek(-8, io.SeekCurrent); err != nil || pos != 1 {
+		t.Fatalf("Seek(-8, SeekCurrent) = %d, %v; want 1", pos, err)
+	}
+	rest, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "123456789" {
+		t.Fatalf("read %q after seek, want %q", rest, "123456789")
+	}
+	reqs := f.lastRequests(skip)
+	if len(reqs) != 1 || !strings.HasSuffix(reqs[0], " bytes=1-") {
+		t.Fatalf("requests after seek = %q, want GET with Range bytes=1-", reqs)
+	}
+
+	if _, err := obj.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("Seek to negative position succeeded")
+	}
+}
+
+func TestS3GetNotFound(t *testing.T) {
+	_, store := newFakeS3(t)
+	if _, err := store.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	f, store := newFakeS3(t)
+	ctx := context.Background()
+	f.objects["doc"] = []byte("data")
+
+	if err := store.Delete(ctx, "doc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.objects["doc"]; ok {
+		t.Fatal("object still stored after Delete")
+	}
+	// Удаление отсутствующего объекта ошибкой не считается
+	if err := store.Delete(ctx, "doc"); err != nil {
+		t.Fatalf("Delete of missing object: %v", err)
+	}
+}
+
+func TestS3ErrorStatus(t *testing.T) {
+	_, store := newFakeS3(t)
+	store.opts.AccessKey = "WRONG"
+	_, err := store.Put(context.Background(), "doc", strings.NewReader("x"), 1)
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put error = %v, want 403 error", err)
+	}
+}
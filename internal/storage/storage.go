@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go-asset-service/internal/config"
+)
+
+// ErrNotFound возвращается, если объект с указанным ключом отсутствует в хранилище.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore описывает хранилище содержимого файлов (blob'ов).
+// В Postgres хранятся только метаданные и ключ объекта, а сами данные
+// записываются в BlobStore потоково, без буферизации всего файла в памяти.
+type BlobStore interface {
+	// Put записывает данные из r под ключом key и возвращает количество записанных байт.
+	// size — ожидаемый размер данных, либо -1, если он неизвестен заранее.
+	Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error)
+	// Get открывает объект с ключом key для чтения. Возвращаемый поток поддерживает Seek,
+	// что позволяет отдавать произвольные диапазоны байт (HTTP Range) без чтения всего объекта.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete удаляет объект с ключом key. Отсутствие объекта ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore создаёт хранилище в соответствии с параметром STORAGE_BACKEND из конфигурации.
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local", "":
+		return NewLocalStore(cfg.StorageLocalPath)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,170 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-asset-service/internal/config"
+)
+
+// certFiles — пути к файлам сертификата и ключа одной пары.
+type certFiles struct {
+	certPath string
+	keyPath  string
+}
+
+// fileStamp — время изменения и размер файла: по ним определяется, что файл заменили.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertStore хранит сертификаты сервера и отдаёт их через tls.Config.GetCertificate.
+// Первая пара (TLS_CERT_PATH/TLS_KEY_PATH) используется по умолчанию, дополнительные
+// выбираются по имени сервера (SNI). Файлы перечитываются при изменении (ReloadIfChanged)
+// или по запросу (Reload, например по SIGHUP); если новые файлы не загружаются,
+// продолжают действовать прежние сертификаты.
+type CertStore struct {
+	files []certFiles // Пары файлов в порядке конфигурации
+
+	mu     sync.RWMutex
+	certs  []*tls.Certificate // Загруженные сертификаты, в том же порядке, что и files
+	stamps []fileStamp        // Отметки файлов на момент загрузки: по две на пару
+}
+
+// NewCertStore загружает сертификаты сервера. При TLS_SELF_SIGNED=true и отсутствии
+// файлов TLS_CERT_PATH и TLS_KEY_PATH сначала создаёт самоподписанный сертификат.
+func NewCertStore(cfg *config.Config) (*CertStore, error) {
+	files, err := parseCertFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TLSSelfSigned && missing(cfg.TLSCertPath) && missing(cfg.TLSKeyPath) {
+		if err := writeSelfSigned(cfg.TLSCertPath, cfg.TLSKeyPath); err != nil {
+			return nil, fmt.Errorf("generate self-signed certificate: %w", err)
+		}
+		log.Printf("[WARN] Generated self-signed TLS certificate %s, do not use it in production", cfg.TLSCertPath)
+	}
+
+	s := &CertStore{files: files}
+	if err := s.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate выбирает сертификат для соединения: первый, подходящий клиенту по имени
+// сервера и алгоритмам подписи, иначе сертификат по умолчанию.
+func (s *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, cert := range s.certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	return s.certs[0], nil
+}
+
+// Reload перечитывает все пары сертификатов. Если хотя бы одна не загружается,
+// возвращает ошибку и оставляет прежние сертификаты.
+func (s *CertStore) Reload(ctx context.Context) error {
+	stamps, err := s.stat()
+	if err != nil {
+		return err
+	}
+	certs := make([]*tls.Certificate, 0, len(s.files))
+	for _, f := range s.files {
+		cert, err := tls.LoadX509KeyPair(f.certPath, f.keyPath)
+		if err != nil {
+			return fmt.Errorf("load certificate %s: %w", f.certPath, err)
+		}
+		certs = append(certs, &cert)
+	}
+
+	s.mu.Lock()
+	reloaded := s.certs != nil
+	s.certs, s.stamps = certs, stamps
+	s.mu.Unlock()
+
+	if reloaded {
+		log.Printf("[INFO] TLS certificates reloaded: %d pair(s)", len(certs))
+	}
+	return nil
+}
+
+// ReloadIfChanged перечитывает сертификаты, если какой-либо из файлов изменился
+// с момента последней загрузки. Предназначен для периодического запуска.
+func (s *CertStore) ReloadIfChanged(ctx context.Context) error {
+	stamps, err := s.stat()
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	changed := false
+	for i, st := range stamps {
+		if st != s.stamps[i] {
+			changed = true
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return s.Reload(ctx)
+}
+
+// stat возвращает отметки всех файлов сертификатов и ключей.
+func (s *CertStore) stat() ([]fileStamp, error) {
+	stamps := make([]fileStamp, 0, 2*len(s.files))
+	for _, f := range s.files {
+		for _, path := range []string{f.certPath, f.keyPath} {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("stat %s: %w", path, err)
+			}
+			stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+		}
+	}
+	return stamps, nil
+}
+
+// parseCertFiles собирает пары файлов: основную и дополнительные из TLS_EXTRA_CERT_PATHS
+// и TLS_EXTRA_KEY_PATHS, которые должны перечислять одинаковое число путей.
+func parseCertFiles(cfg *config.Config) ([]certFiles, error) {
+	certPaths, keyPaths := splitPaths(cfg.TLSExtraCertPaths), splitPaths(cfg.TLSExtraKeyPaths)
+	if len(certPaths) != len(keyPaths) {
+		return nil, fmt.Errorf("TLS_EXTRA_CERT_PATHS has %d entries, TLS_EXTRA_KEY_PATHS has %d", len(certPaths), len(keyPaths))
+	}
+	files := []certFiles{{certPath: cfg.TLSCertPath, keyPath: cfg.TLSKeyPath}}
+	for i := range certPaths {
+		files = append(files, certFiles{certPath: certPaths[i], keyPath: keyPaths[i]})
+	}
+	return files, nil
+}
+
+// splitPaths разбирает список путей через запятую, пропуская пустые элементы.
+func splitPaths(v string) []string {
+	var paths []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			paths = append(paths, item)
+		}
+	}
+	return paths
+}
+
+// missing сообщает, что файла path не существует.
+func missing(path string) bool {
+	_, err := os.Stat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
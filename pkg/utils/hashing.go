@@ -3,11 +3,13 @@ package utils
 import (
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"io"
 )
 
 // Md5Hash принимает строку и возвращает её MD5-хеш в виде шестнадцатеричной строки.
+// Для хеширования паролей не используется: нужен только для проверки устаревших хешей (см. VerifyPassword).
 func Md5Hash(s string) string {
 	hash := md5.Sum([]byte(s))
 	return hex.EncodeToString(hash[:])
@@ -24,3 +26,11 @@ func GenerateToken(length int) (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+// HashToken возвращает SHA-256 токена в виде шестнадцатеричной строки.
+// Используется для хранения случайных высокоэнтропийных токенов (refresh-токенов и т.п.)
+// в БД без возможности восстановить сам токен; соль и медленный хеш здесь не нужны.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Поддерживаемые алгоритмы подписи JWT (RFC 7518, RFC 8037). Алгоритм "none" и симметричные HS*
+// не принимаются: токен должен проверяться открытым ключом без общего секрета.
+const (
+	JWTAlgRS256 = "RS256" // RSASSA-PKCS1-v1_5 с SHA-256
+	JWTAlgES256 = "ES256" // ECDSA P-256 с SHA-256
+	JWTAlgEdDSA = "EdDSA" // Ed25519
+)
+
+var (
+	// ErrInvalidJWT возвращается для токена, который не удалось разобрать.
+	ErrInvalidJWT = errors.New("invalid jwt")
+	// ErrJWTSignature возвращается, если подпись токена не совпала или алгоритм не поддерживается.
+	ErrJWTSignature = errors.New("invalid jwt signature")
+)
+
+// JWTHeader — заголовок JWS в компактной сериализации.
+type JWTHeader struct {
+	Alg string `json:"alg"`           // Алгоритм подписи
+	Kid string `json:"kid,omitempty"` // Идентификатор ключа подписи
+	Typ string `json:"typ,omitempty"` // Тип токена
+}
+
+// JWK — открытый ключ в формате JSON Web Key (RFC 7517); поддерживаются RSA, EC P-256 и OKP Ed25519.
+type JWK struct {
+	Kty string `json:"kty"`           // Тип ключа: RSA, EC или OKP
+	Kid string `json:"kid,omitempty"` // Идентификатор ключа
+	Use string `json:"use,omitempty"` // Назначение: sig — подпись
+	Alg string `json:"alg,omitempty"` // Алгоритм, для которого предназначен ключ
+	N   string `json:"n,omitempty"`   // RSA: модуль (base64url)
+	E   string `json:"e,omitempty"`   // RSA: открытая экспонента (base64url)
+	Crv string `json:"crv,omitempty"` // EC, OKP: кривая
+	X   string `json:"x,omitempty"`   // EC: координата X, OKP: открытый ключ (base64url)
+	Y   string `json:"y,omitempty"`   // EC: координата Y (base64url)
+}
+
+// JWKSet — набор открытых ключей (RFC 7517, раздел 5), публикуемый по адресу jwks_uri.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK описывает открытый ключ pub (*ecdsa.PublicKey P-256 или ed25519.PublicKey) в формате JWK
+// с идентификатором kid для подписи алгоритмом alg.
+func NewJWK(kid, alg string, pub crypto.PublicKey) (*JWK, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("jwk %q: unsupported curve", kid)
+		}
+		return &JWK{
+			Kty: "EC", Kid: kid, Use: "sig", Alg: alg, Crv: "P-256",
+			X: base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+			Y: base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{Kty: "OKP", Kid: kid, Use: "sig", Alg: alg, Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}, nil
+	default:
+		return nil, fmt.Errorf("jwk %q: unsupported key type %T", kid, pub)
+	}
+}
+
+// PublicKey возвращает открытый ключ JWK (*rsa.PublicKey, *ecdsa.PublicKey или ed25519.PublicKey).
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwk %q: invalid RSA exponent", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwk %q: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("jwk %q: point is not on curve", k.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwk %q: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %q: invalid Ed25519 key", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("jwk %q: unsupported key type %q", k.Kid, k.Kty)
+	}
+}
+
+// ParseJWT разбирает токен в компактной сериализации header.payload.signature без проверки
+// подписи и возвращает заголовок и JSON полезной нагрузки.
+func ParseJWT(token string) (*JWTHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrInvalidJWT
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalidJWT
+	}
+	var header JWTHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, nil, ErrInvalidJWT
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, ErrInvalidJWT
+	}
+	return &header, payload, nil
+}
+
+// VerifyJWT проверяет подпись токена ключом, который keyFunc выбирает по заголовку
+// (обычно по kid), и возвращает JSON полезной нагрузки. Алгоритм из заголовка должен
+// соответствовать типу ключа: RS256 — RSA, ES256 — EC P-256, EdDSA — Ed25519.
+func VerifyJWT(token string, keyFunc func(*JWTHeader) (crypto.PublicKey, error)) ([]byte, error) {
+	header, payload, err := ParseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keyFunc(header)
+	if err != nil {
+		return nil, err
+	}
+
+	dot := strings.LastIndexByte(token, '.')
+	sig, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, ErrInvalidJWT
+	}
+	digest := sha256.Sum256([]byte(token[:dot]))
+
+	switch header.Alg {
+	case JWTAlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return nil, ErrJWTSignature
+		}
+	case JWTAlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() || len(sig) != 64 {
+			return nil, ErrJWTSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, ErrJWTSignature
+		}
+	case JWTAlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, []byte(token[:dot]), sig) {
+			return nil, ErrJWTSignature
+		}
+	default:
+		return nil, ErrJWTSignature
+	}
+	return payload, nil
+}
+
+// SignJWT подписывает полезную нагрузку claims (сериализуется в JSON) ключом key
+// и возвращает токен в компактной сериализации. Алгоритм выбирается по типу ключа:
+// *ecdsa.PrivateKey P-256 — ES256, ed25519.PrivateKey — EdDSA.
+func SignJWT(kid string, claims any, key crypto.Signer) (string, error) {
+	header := JWTHeader{Kid: kid, Typ: "JWT"}
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("jwt: unsupported curve")
+		}
+		header.Alg = JWTAlgES256
+	case ed25519.PrivateKey:
+		header.Alg = JWTAlgEdDSA
+	default:
+		return "", fmt.Errorf("jwt: unsupported key type %T", key)
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(rawHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		// ES256 использует подпись фиксированной длины r||s, а не ASN.1 DER
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", err
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signingInput))
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// decodeJWKInt декодирует целое число JWK в base64url без выравнивания (big-endian).
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid jwk number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testClaims struct {
+	Subject string `json:"sub"`
+	Role    string `json:"role"`
+}
+
+func newTestSigners(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{JWTAlgES256: ec, JWTAlgEdDSA: ed}
+}
+
+// keyOf возвращает keyFunc, всегда отдающую открытый ключ pub.
+func keyOf(pub crypto.PublicKey) func(*JWTHeader) (crypto.PublicKey, error) {
+	return func(*JWTHeader) (crypto.PublicKey, error) { return pub, nil }
+}
+
+func TestJWTRoundTrip(t *testing.T) {
+	for alg, key := range newTestSigners(t) {
+		t.Run(alg, func(t *testing.T) {
+			token, err := SignJWT("kid-1", testClaims{Subject: "42", Role: "user"}, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header, _, err := ParseJWT(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Alg != alg || header.Kid != "kid-1" || header.Typ != "JWT" {
+				t.Fatalf("header = %+v, want alg %s and kid kid-1", header, alg)
+			}
+
+			// Ключ проверки выбирается по заголовку — как это делает JWTService по kid
+			payload, err := VerifyJWT(token, func(h *JWTHeader) (crypto.PublicKey, error) {
+				if h.Kid != "kid-1" {
+					t.Fatalf("keyFunc got kid %q", h.Kid)
+				}
+				return key.Public(), nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var claims testClaims
+			if err := json.Unmarshal(payload, &claims); err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "42" || claims.Role != "user" {
+				t.Fatalf("claims = %+v", claims)
+			}
+
+			// Открытый ключ, опубликованный в JWKS, проверяет тот же токен
+			jwk, err := NewJWK("kid-1", alg, key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := VerifyJWT(token, keyOf(pub)); err != nil {
+				t.Fatalf("verify with JWK: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTRejects(t *testing.T) {
+	signers := newTestSigners(t)
+	es, ed := signers[JWTAlgES256], signers[JWTAlgEdDSA]
+	esToken, err := SignJWT("es", testClaims{Subject: "1"}, es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edToken, err := SignJWT("ed", testClaims{Subject: "1"}, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEC, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reheader заменяет заголовок токена, сохраняя полезную нагрузку и подпись
+	reheader := func(token, alg string) string {
+		h, _ := json.Marshal(JWTHeader{Alg: alg, Kid: "es", Typ: "JWT"})
+		parts := strings.Split(token, ".")
+		return base64.RawURLEncoding.EncodeToString(h) + "." + parts[1] + "." + parts[2]
+	}
+	// repayload подменяет полезную нагрузку, сохраняя подпись
+	repayload := func(token string) string {
+		parts := strings.Split(token, ".")
+		p := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"2"}`))
+		return parts[0] + "." + p + "." + parts[2]
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		key   crypto.PublicKey
+		want  error
+	}{
+		{"ES256 token, Ed25519 key", esToken, ed.Public(), ErrJWTSignature},
+		{"EdDSA token, EC key", edToken, es.Public(), ErrJWTSignature},
+		{"ES256 token, RSA key", esToken, &rsaKey.PublicKey, ErrJWTSignature},
+		{"ES256 token, another EC key", esToken, otherEC.Public(), ErrJWTSignature},
+		{"alg none", reheader(esToken, "none"), es.Public(), ErrJWTSignature},
+		{"alg HS256", reheader(esToken, "HS256"), es.Public(), ErrJWTSignature},
+		{"alg switched to EdDSA", reheader(esToken, JWTAlgEdDSA), es.Public(), ErrJWTSignature},
+		{"tampered ES256 payload", repayload(esToken), es.Public(), ErrJWTSignature},
+		{"tampered EdDSA payload", repayload(edToken), ed.Public(), ErrJWTSignature},
+		{"no signature", esToken[:strings.LastIndexByte(esToken, '.')], es.Public(), ErrInvalidJWT},
+		{"empty signature", esToken[:strings.LastIndexByte(esToken, '.')+1], es.Public(), ErrJWTSignature},
+		{"garbage", "a.b.c", es.Public(), ErrInvalidJWT},
+	}
+	for _, tt := range tests {
+		if _, err := VerifyJWT(tt.token, keyOf(tt.key)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	keyErr := errors.New("unknown kid")
+	_, err = VerifyJWT(esToken, func(*JWTHeader) (crypto.PublicKey, error) { return nil, keyErr })
+	if !errors.Is(err, keyErr) {
+		t.Errorf("keyFunc error: err = %v, want %v", err, keyErr)
+	}
+}
+
+func TestSignJWTUnsupportedKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]crypto.Signer{"RSA": rsaKey, "P-384": p384} {
+		if _, err := SignJWT("kid", testClaims{}, key); err == nil {
+			t.Errorf("%s key accepted", name)
+		}
+	}
+	if _, err := NewJWK("kid", JWTAlgES256, p384.Public()); err == nil {
+		t.Error("P-384 JWK accepted")
+	}
+}
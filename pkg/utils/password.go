@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Поддерживаемые схемы хеширования паролей.
+const (
+	PasswordSchemeArgon2id = "argon2id" // $argon2id$v=19$m=...,t=...,p=...$<salt>$<hash> (формат PHC)
+	PasswordSchemeBcrypt   = "bcrypt"   // $2a$<cost>$<salt+hash>
+	PasswordSchemeMD5      = "md5"      // Устаревшая схема: hex MD5 без соли, только для проверки
+)
+
+// Параметры argon2id по умолчанию (рекомендации OWASP с запасом).
+const (
+	argon2Memory  = 64 * 1024 // Память в КиБ
+	argon2Time    = 3         // Число проходов
+	argon2Threads = 2         // Степень параллелизма
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+
+	bcryptCost = 12
+)
+
+// ErrUnknownPasswordScheme возвращается, если хеш пароля записан в неизвестном формате.
+var ErrUnknownPasswordScheme = errors.New("unknown password hash scheme")
+
+// HashPassword хеширует пароль по схеме scheme (argon2id или bcrypt) и возвращает
+// строку, в которой закодированы схема, её параметры и соль.
+func HashPassword(scheme, password string) (string, error) {
+	switch scheme {
+	case PasswordSchemeArgon2id, "":
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	case PasswordSchemeBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		return string(hash), err
+	default:
+		return "", ErrUnknownPasswordScheme
+	}
+}
+
+// VerifyPassword проверяет пароль по хешу любой поддерживаемой схемы, включая устаревший MD5.
+func VerifyPassword(encoded, password string) (bool, error) {
+	switch PasswordHashScheme(encoded) {
+	case PasswordSchemeArgon2id:
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1, nil
+	case PasswordSchemeBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case PasswordSchemeMD5:
+		return subtle.ConstantTimeCompare([]byte(Md5Hash(password)), []byte(strings.ToLower(encoded))) == 1, nil
+	default:
+		return false, ErrUnknownPasswordScheme
+	}
+}
+
+// PasswordNeedsRehash сообщает, что хеш записан не по схеме scheme или с параметрами
+// слабее текущих, и после успешной проверки пароль следует перехешировать.
+func PasswordNeedsRehash(encoded, scheme string) bool {
+	if scheme == "" {
+		scheme = PasswordSchemeArgon2id
+	}
+	current := PasswordHashScheme(encoded)
+	if current != scheme {
+		return true
+	}
+	switch current {
+	case PasswordSchemeArgon2id:
+		params, _, key, err := decodeArgon2id(encoded)
+		return err != nil || params.memory < argon2Memory || params.time < argon2Time || len(key) < argon2KeyLen
+	case PasswordSchemeBcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost < bcryptCost
+	}
+	return false
+}
+
+// PasswordHashScheme определяет схему, которой записан хеш пароля.
+func PasswordHashScheme(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return PasswordSchemeArgon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return PasswordSchemeBcrypt
+	case len(encoded) == 32 && isHex(encoded):
+		return PasswordSchemeMD5
+	default:
+		return ""
+	}
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// decodeArgon2id разбирает строку формата PHC: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+func decodeArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, errors.New("malformed argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	return p, salt, key, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret — ключ тестовых векторов RFC 6238 (ASCII "12345678901234567890") в base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Векторы RFC 6238, приложение B (SHA-1): в RFC коды 8-значные, у нас — последние 6 цифр.
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("T=%d: code = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+
+	// Секрет из приложения вводят и строчными буквами
+	if code, _ := TOTPCode(strings.ToLower(rfc6238Secret), 1); code != "287082" {
+		t.Errorf("lower-case secret: code = %s, want 287082", code)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name string
+		step int64
+		skew int
+		ok   bool
+	}{
+		{"current step", step, 0, true},
+		{"previous step without skew", step - 1, 0, false},
+		{"previous step", step - 1, 1, true},
+		{"next step", step + 1, 1, true},
+		{"two steps behind", step - 2, 1, false},
+		{"two steps ahead", step + 2, 1, false},
+	}
+	for _, tt := range tests {
+		got, ok := VerifyTOTP(rfc6238Secret, code(tt.step), now, tt.skew)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %t, want %t", tt.name, ok, tt.ok)
+			continue
+		}
+		// Возвращается шаг, на котором код совпал, а не текущий
+		if ok && got != tt.step {
+			t.Errorf("%s: step = %d, want %d", tt.name, got, tt.step)
+		}
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := VerifyTOTP(rfc6238Secret, bad, now, 1); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+}
+
+// Повтор кода отсекает вызывающий по номеру шага: тот же код в пределах допуска
+// возвращает тот же шаг, а следующий код — больший.
+func TestVerifyTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	first, err := TOTPCode(rfc6238Secret, TOTPStep(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUsed, ok := VerifyTOTP(rfc6238Secret, first, now, 1)
+	if !ok {
+		t.Fatal("valid code rejected")
+	}
+
+	later := now.Add(TOTPPeriod * time.Second)
+	step, ok := VerifyTOTP(rfc6238Secret, first, later, 1)
+	if !ok || step > lastUsed {
+		t.Fatalf("replayed code: step = %d (%t), want %d, which the caller rejects", step, ok, lastUsed)
+	}
+
+	next, err := TOTPCode(rfc6238Secret, TOTPStep(later))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := VerifyTOTP(rfc6238Secret, next, later, 1); !ok || step <= lastUsed {
+		t.Fatalf("next code: step = %d (%t), want > %d", step, ok, lastUsed)
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	a, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateTOTPSecret()
+	if a == b {
+		t.Fatal("secrets repeat")
+	}
+	key, err := totpEncoding.DecodeString(a)
+	if err != nil || len(key) != totpSecretLen {
+		t.Fatalf("secret %q: %d bytes, err = %v", a, len(key), err)
+	}
+}
@@ -1,27 +1,378 @@
 create extension if not exists pgcrypto;
 
+-- role — роль пользователя: admin, user или readonly.
+-- disabled_at — время блокировки учётной записи администратором (null — активна).
 create table if not exists users (
      id            bigserial primary key,
      login         text not null unique,
      password_hash text not null,
+     role          text not null default 'user' check (role in ('admin', 'user', 'readonly')),
+     disabled_at   timestamptz,
      created_at    timestamptz not null default now()
 );
 
+-- У пользователя может быть несколько сессий (по одной на устройство).
+-- public_id — несекретный идентификатор для просмотра и отзыва сессии через API.
+-- refresh_token_hash — SHA-256 текущего refresh-токена (сам токен не хранится).
 create table if not exists sessions (
-    id         text primary key default encode(gen_random_bytes(16),'hex'),
-    uid        bigint not null,
-    ip_address text,
-    created_at timestamptz not null default now()
+    id                 text primary key default encode(gen_random_bytes(16),'hex'),
+    public_id          text not null unique default encode(gen_random_bytes(8),'hex'),
+    uid                bigint not null,
+    device_label       text,
+    ip_address         text,
+    created_at         timestamptz not null default now(),
+    last_used_at       timestamptz not null default now(),
+    mfa_verified       boolean not null default false,
+    refresh_token_hash text unique,
+    refresh_expires_at timestamptz not null default now()
 );
 
+create index if not exists sessions_uid_idx on sessions (uid);
+
+-- Уже обменянные refresh-токены: повторное предъявление такого токена отзывает всю сессию.
+create table if not exists used_refresh_tokens (
+    token_hash        text primary key,
+    session_public_id text not null references sessions(public_id) on delete cascade,
+    used_at           timestamptz not null default now()
+);
+
+-- Логический файл пользователя и номер его текущей версии.
+-- Имя может быть иерархическим: сегменты через «/» (например, builds/v1/app.tar).
 create table if not exists assets (
-    name       text not null,
-    uid        bigint not null,
-    data       bytea not null,
-    created_at timestamptz not null default now(),
+    name            text not null,
+    uid             bigint not null,
+    current_version integer not null default 0,
+    created_at      timestamptz not null default now(),
+    updated_at      timestamptz not null default now(),
     primary key (name, uid)
 );
 
+-- Содержимое файлов, адресуемое по SHA-256: одинаковое содержимое хранится один раз.
+-- Сами данные лежат в BlobStore (локальный диск или S3) под ключом storage_key;
+-- refcount — число версий файлов, ссылающихся на содержимое. Когда он доходит до нуля,
+-- запись и объект в хранилище удаляются.
+create table if not exists blobs (
+    sha256      text primary key,
+    storage_key text not null unique,
+    size        bigint not null default 0,
+    refcount    integer not null default 0,
+    created_at  timestamptz not null default now()
+);
+
+-- Версии файлов: каждая загрузка под тем же именем добавляет новую версию.
+-- metadata — пользовательские метаданные из заголовков X-Asset-Meta-*.
+create table if not exists asset_versions (
+    name         text not null,
+    uid          bigint not null,
+    version      integer not null,
+    sha256       text not null references blobs(sha256),
+    content_type text not null default 'application/octet-stream',
+    metadata     jsonb not null default '{}',
+    created_at   timestamptz not null default now(),
+    primary key (name, uid, version),
+    foreign key (name, uid) references assets(name, uid) on delete cascade on update cascade
+);
+
+create index if not exists asset_versions_sha256_idx on asset_versions (sha256);
+create index if not exists asset_versions_uid_created_at_idx on asset_versions (uid, created_at);
+
+-- Незавершённые возобновляемые загрузки и их части (каждая часть — отдельный объект в BlobStore).
+create table if not exists uploads (
+    id            text primary key,
+    uid           bigint not null references users(id) on delete cascade,
+    name          text not null,
+    length        bigint not null,
+    upload_offset bigint not null default 0,
+    content_type  text not null default '',
+    metadata      jsonb not null default '{}',
+    created_at    timestamptz not null default now(),
+    updated_at    timestamptz not null default now(),
+    expires_at    timestamptz not null
+);
+
+create index if not exists uploads_expires_at_idx on uploads (expires_at);
+
+create table if not exists upload_chunks (
+    upload_id    text not null references uploads(id) on delete cascade,
+    chunk_offset bigint not null,
+    size         bigint not null,
+    storage_key  text not null,
+    primary key (upload_id, chunk_offset)
+);
+
+-- API-ключи машинных клиентов. Хранится только SHA-256 ключа; key_prefix — его начало для опознания.
+-- scopes — разрешённые операции (assets:read, assets:write, assets:delete),
+-- name_prefixes — разрешённые префиксы имён файлов (пустой массив — любые).
+create table if not exists api_keys (
+    id            bigserial primary key,
+    uid           bigint not null references users(id) on delete cascade,
+    name          text not null,
+    key_prefix    text not null,
+    key_hash      text not null unique,
+    scopes        text[] not null,
+    name_prefixes text[] not null default '{}',
+    expires_at    timestamptz,
+    last_used_at  timestamptz,
+    created_at    timestamptz not null default now()
+);
+
+create index if not exists api_keys_uid_idx on api_keys (uid);
+
+-- Сопоставления клиентских сертификатов mTLS пользователям (для внутренних сервисов).
+-- match_type — поле сертификата: subject (DN субъекта), dns, email или uri (Subject Alternative Name);
+-- права ограничиваются так же, как у API-ключей.
+create table if not exists client_cert_mappings (
+    id            bigserial primary key,
+    match_type    text not null check (match_type in ('subject', 'dns', 'email', 'uri')),
+    value         text not null,
+    uid           bigint not null references users(id) on delete cascade,
+    scopes        text[] not null,
+    name_prefixes text[] not null default '{}',
+    last_used_at  timestamptz,
+    created_at    timestamptz not null default now(),
+    unique (match_type, value)
+);
+
+-- Группы пользователей, которым можно выдавать доступ к файлам. Составом управляет владелец группы.
+create table if not exists groups (
+    id         bigserial primary key,
+    name       text not null unique,
+    owner_uid  bigint not null references users(id) on delete cascade,
+    created_at timestamptz not null default now()
+);
+
+create table if not exists group_members (
+    group_id bigint not null references groups(id) on delete cascade,
+    uid      bigint not null references users(id) on delete cascade,
+    primary key (group_id, uid)
+);
+
+create index if not exists group_members_uid_idx on group_members (uid);
+
+-- Списки доступа (ACL): владелец выдаёт право read, write или delete на свой файл
+-- (или на все файлы с префиксом, если name заканчивается на «/») пользователю или группе.
+create table if not exists asset_grants (
+    id          bigserial primary key,
+    owner_uid   bigint not null references users(id) on delete cascade,
+    name        text not null,
+    grantee_uid bigint references users(id) on delete cascade,
+    group_id    bigint references groups(id) on delete cascade,
+    permission  text not null check (permission in ('read', 'write', 'delete')),
+    created_at  timestamptz not null default now(),
+    check ((grantee_uid is null) <> (group_id is null))
+);
+
+create unique index if not exists asset_grants_unique_idx
+    on asset_grants (owner_uid, name, permission, coalesce(grantee_uid, 0), coalesce(group_id, 0));
+create index if not exists asset_grants_grantee_uid_idx on asset_grants (grantee_uid);
+create index if not exists asset_grants_group_id_idx on asset_grants (group_id);
+
+-- Счётчики использований подписанных ссылок с ограничением числа использований.
+-- Сами ссылки не хранятся: все их параметры защищены подписью HMAC.
+create table if not exists presigned_url_uses (
+    id         text primary key,
+    uses       integer not null,
+    expires_at timestamptz not null
+);
+
+create index if not exists presigned_url_uses_expires_at_idx on presigned_url_uses (expires_at);
+
+-- Постоянные публичные ссылки /s/{slug} на файл или «папку» (name с «/» на конце).
+-- uid — кто выпустил ссылку, owner_uid — владелец файлов (отличается, если файл доступен по ACL).
+-- password_hash — хеш пароля ссылки (null — без пароля); отозванные ссылки хранятся ради статистики.
+create table if not exists share_links (
+    slug             text primary key,
+    uid              bigint not null references users(id) on delete cascade,
+    owner_uid        bigint not null references users(id) on delete cascade,
+    name             text not null,
+    password_hash    text,
+    expires_at       timestamptz,
+    max_downloads    integer,
+    download_count   integer not null default 0,
+    access_count     integer not null default 0,
+    last_accessed_at timestamptz,
+    revoked_at       timestamptz,
+    created_at       timestamptz not null default now()
+);
+
+create index if not exists share_links_uid_idx on share_links (uid);
+
+-- Токены сброса пароля: хранится только SHA-256 токена, у пользователя не больше одного токена.
+create table if not exists password_reset_tokens (
+    token_hash text primary key,
+    uid        bigint not null references users(id) on delete cascade,
+    expires_at timestamptz not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists password_reset_tokens_uid_idx on password_reset_tokens (uid);
+
+-- Квоты на хранилище по ролям (null — без ограничения): суммарный размер всех версий файлов,
+-- число файлов и размер одного файла в байтах.
+create table if not exists role_quotas (
+    role          text primary key check (role in ('admin', 'user', 'readonly')),
+    max_bytes     bigint check (max_bytes >= 0),
+    max_objects   bigint check (max_objects >= 0),
+    max_file_size bigint check (max_file_size >= 0)
+);
+
+insert into role_quotas (role, max_bytes, max_objects, max_file_size)
+values ('admin', null, null, null),
+       ('user', 10737418240, 100000, 2147483648),
+       ('readonly', 0, 0, 0)
+    on conflict do nothing;
+
+-- Персональные квоты: заданное (не null) поле заменяет ограничение роли пользователя.
+create table if not exists user_quotas (
+    uid           bigint primary key references users(id) on delete cascade,
+    max_bytes     bigint check (max_bytes >= 0),
+    max_objects   bigint check (max_objects >= 0),
+    max_file_size bigint check (max_file_size >= 0)
+);
+
+-- Счётчики потребления: суммарный размер всех версий файлов пользователя и число файлов.
+-- Меняются в тех же транзакциях, что создают и удаляют версии, и проверяются там же по квоте.
+create table if not exists user_usage (
+    uid     bigint primary key references users(id) on delete cascade,
+    bytes   bigint not null default 0,
+    objects bigint not null default 0
+);
+
+-- Заполняем счётчики для уже загруженных файлов.
+insert into user_usage (uid, bytes, objects)
+select u.id,
+       coalesce((select sum(b.size) from asset_versions v join blobs b on b.sha256 = v.sha256 where v.uid = u.id), 0),
+       (select count(*) from assets a where a.uid = u.id)
+from users u
+    on conflict (uid) do nothing;
+
+-- Счётчики неудачных попыток входа (LOGIN_ATTEMPT_STORE=postgres): ключ — "login:<логин>"
+-- или "ip:<адрес>"; до locked_until попытки входа по ключу отклоняются.
+create table if not exists login_attempts (
+    key             text primary key,
+    failures        integer     not null,
+    last_failure_at timestamptz not null,
+    locked_until    timestamptz not null
+);
+
+-- Второй фактор (TOTP, RFC 6238): секрет пользователя; до подтверждения первым кодом
+-- (confirmed_at is null) при входе не запрашивается. last_used_step защищает от повторного
+-- использования одного и того же кода.
+create table if not exists user_mfa (
+    uid            bigint primary key references users(id) on delete cascade,
+    secret         text        not null,
+    confirmed_at   timestamptz,
+    last_used_step bigint      not null default 0,
+    created_at     timestamptz not null default now()
+);
+
+-- Одноразовые коды восстановления на случай потери устройства (хранятся только хеши).
+create table if not exists mfa_recovery_codes (
+    uid       bigint not null references users(id) on delete cascade,
+    code_hash text   not null,
+    used_at   timestamptz,
+    primary key (uid, code_hash)
+);
+
+-- Незавершённые входы: пароль проверен, ожидается код второго фактора (хранится хеш токена).
+create table if not exists mfa_challenges (
+    token_hash   text primary key,
+    uid          bigint      not null references users(id) on delete cascade,
+    device_label text        not null default '',
+    attempts     integer     not null default 0,
+    expires_at   timestamptz not null,
+    created_at   timestamptz not null default now()
+);
+
+create index if not exists mfa_challenges_uid_idx on mfa_challenges (uid);
+
+-- Роли, пользователям которых второй фактор обязателен: без него им доступно только чтение.
+create table if not exists mfa_required_roles (
+    role text primary key check (role in ('admin', 'user', 'readonly'))
+);
+
+-- Учётные записи пользователей у провайдера OpenID Connect: издатель и постоянный идентификатор
+-- пользователя у него (sub). Логин у провайдера может меняться, поэтому вход сопоставляется по sub.
+create table if not exists user_identities (
+    issuer     text        not null,
+    subject    text        not null,
+    uid        bigint      not null references users(id) on delete cascade,
+    created_at timestamptz not null default now(),
+    primary key (issuer, subject)
+);
+
+create index if not exists user_identities_uid_idx on user_identities (uid);
+
+-- Начатые входы через OIDC: хеш параметра state, секретный code verifier PKCE и nonce ID-токена.
+create table if not exists oidc_login_states (
+    state_hash    text primary key,
+    code_verifier text        not null,
+    nonce         text        not null,
+    device_label  text        not null default '',
+    expires_at    timestamptz not null,
+    created_at    timestamptz not null default now()
+);
+
+-- Ключи подписи токенов доступа в формате JWT (ACCESS_TOKEN_FORMAT=jwt), общие для всех
+-- экземпляров сервиса. private_key — закрытый ключ в PKCS#8 DER. Ключ периодически сменяется;
+-- прежние остаются опубликованными в JWKS, пока не истекут подписанные ими токены.
+create table if not exists jwt_signing_keys (
+    kid         text primary key,
+    alg         text        not null check (alg in ('EdDSA', 'ES256')),
+    private_key bytea       not null,
+    created_at  timestamptz not null default now()
+);
+
+-- Отозванные сессии (выход, отзыв, смена пароля, блокировка): токены доступа в формате JWT
+-- проверяются без обращения к таблице sessions, поэтому их отзыв идёт через этот список.
+-- Запись нужна, пока не истекут выданные сессии токены доступа.
+create table if not exists revoked_sessions (
+    public_id  text primary key,
+    revoked_at timestamptz not null default now()
+);
+
+create index if not exists revoked_sessions_revoked_at_idx on revoked_sessions (revoked_at);
+
+-- Журнал аудита: вход, операции с файлами и действия администраторов. Таблица только
+-- дополняется (изменение и удаление запрещены триггерами), а каждая запись содержит хеш
+-- предыдущей (prev_hash) и свой хеш (hash), поэтому подмена или удаление записи обнаруживается
+-- проверкой цепочки. uid не ссылается на users: записи переживают удаление пользователя.
+create table if not exists audit_log (
+    id         bigserial primary key,
+    created_at timestamptz not null,
+    uid        bigint,
+    action     text not null,
+    method     text not null,
+    target     text not null default '',
+    owner      bigint,
+    ip         text not null,
+    result     text not null check (result in ('success', 'denied', 'failure')),
+    status     integer not null,
+    details    jsonb not null default '{}',
+    prev_hash  text not null,
+    hash       text not null unique
+);
+
+create index if not exists audit_log_uid_id_idx on audit_log (uid, id);
+create index if not exists audit_log_action_id_idx on audit_log (action, id);
+create index if not exists audit_log_created_at_idx on audit_log (created_at);
+
+create or replace function audit_log_append_only() returns trigger as $$
+begin
+    raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists audit_log_no_update on audit_log;
+create trigger audit_log_no_update
+    before update or delete on audit_log
+    for each row execute function audit_log_append_only();
+
+drop trigger if exists audit_log_no_truncate on audit_log;
+create trigger audit_log_no_truncate
+    before truncate on audit_log
+    for each statement execute function audit_log_append_only();
+
 -- Добавляем внешние ключи (FK), чтобы при удалении пользователя удалялись его сессии/файлы (on delete cascade).
 alter table sessions
     add constraint sessions_uid_fk
@@ -33,7 +384,10 @@ alter table assets
     foreign key (uid) references users(id)
     on delete cascade;
 
--- Тестовый пользователь (login='alice', password='secret')
+-- Тестовый пользователь (login='alice', password='secret') с ролью user. Администратор
+-- создаётся при запуске сервиса из переменных ADMIN_LOGIN и ADMIN_PASSWORD.
+-- Хеш bcrypt формируется средствами pgcrypto; при первом входе пароль прозрачно
+-- перехешируется по схеме PASSWORD_HASH_SCHEME (по умолчанию argon2id).
 insert into users (login, password_hash)
-values ('alice', encode(digest('secret','md5'),'hex'))
+values ('alice', crypt('secret', gen_salt('bf', 12)))
     on conflict do nothing;